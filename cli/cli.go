@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/OpticalFlyer/FiberForge/editor"
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+const cliUsage = `Usage: fiberforge <command> [options] <files>
+
+Commands:
+  convert  Convert KML/KMZ/GPX/CSV files to CSV or GeoJSON
+  report   Report point counts, line lengths and polygon areas of KML/KMZ/GPX/CSV files
+  script   Run a script of FiberForge commands without opening a window
+  help     Show this help
+
+Run fiberforge <command> -h for the options of a command.
+`
+
+// IsCommand reports whether an argument names a subcommand rather than a
+// file to open, e.g. fiberforge report drops.kml
+func IsCommand(arg string) bool {
+	switch arg {
+	case "convert", "report", "script", "help", "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// Run runs a headless subcommand and returns the process exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	var err error
+	switch args[0] {
+	case "convert":
+		err = runConvert(args[1:], stdout, stderr)
+	case "report":
+		err = runReport(args[1:], stdout, stderr)
+	case "script":
+		err = runScript(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+	default:
+		fmt.Fprintf(stderr, "fiberforge: unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "fiberforge %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// loadInputs loads every input file into a new document
+func loadInputs(files []string, importCRS string) (*model.Document, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no input files")
+	}
+
+	doc := model.NewDocument()
+	doc.LoadIcons = false
+
+	var err error
+	if doc.ImportCRS, err = model.ParseCRSArgument(importCRS); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := model.LoadMapFile(file, doc); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return doc, nil
+}
+
+func runConvert(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "-", "output `file`, .csv or .geojson, - for CSV on standard output")
+	format := flags.String("format", "", "output format, csv or geojson, instead of going by the file extension")
+	crsCode := flags.String("crs", "", "EPSG `code` of the CSV output coordinates, lon/lat when empty")
+	importCRS := flags.String("importcrs", "", "EPSG `code` of x,y coordinates in CSV input")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fiberforge convert [options] <file.kml|file.kmz|file.gpx|file.csv>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	doc, err := loadInputs(flags.Args(), *importCRS)
+	if err != nil {
+		return err
+	}
+	crs, err := model.ParseCRSArgument(*crsCode)
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = "csv"
+		switch strings.ToLower(filepath.Ext(*output)) {
+		case ".geojson", ".json":
+			*format = "geojson"
+		}
+	}
+	*format = strings.ToLower(*format)
+	switch *format {
+	case "csv", "geojson", "json":
+	default:
+		return fmt.Errorf("unknown format %q, use csv or geojson", *format)
+	}
+	if *format != "csv" && crs != nil {
+		return fmt.Errorf("GeoJSON is always lon/lat, leave off -crs")
+	}
+
+	w := stdout
+	var file *os.File
+	if *output != "-" {
+		if file, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *format == "csv" {
+		err = model.ExportCSV(w, doc, crs)
+	} else {
+		err = model.ExportGeoJSON(w, doc)
+	}
+	if err != nil {
+		return err
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "Wrote %d points, %d lines and %d polygons to %s\n", len(doc.Points), len(doc.Lines), len(doc.Polygons), *output)
+	}
+	return nil
+}
+
+func runReport(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	unitName := flags.String("units", "ft", "display `unit`, FT, M, MI or KM")
+	format := flags.String("format", "text", "report format, text or csv")
+	importCRS := flags.String("importcrs", "", "EPSG `code` of x,y coordinates in CSV input")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fiberforge report [options] <file.kml|file.kmz|file.gpx|file.csv>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	units, err := model.ParseDistanceUnit(*unitName)
+	if err != nil {
+		return err
+	}
+	doc, err := loadInputs(flags.Args(), *importCRS)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(*format) {
+	case "text":
+		return writeTextReport(stdout, doc, units)
+	case "csv":
+		return writeCSVReport(stdout, doc, units)
+	}
+	return fmt.Errorf("unknown format %q, use text or csv", *format)
+}
+
+func writeTextReport(w io.Writer, doc *model.Document, units model.DistanceUnit) error {
+	totalLength := 0.0
+	segments := 0
+	for _, line := range doc.Lines {
+		totalLength += model.LineLength(line.Points)
+		segments += len(line.Points) - 1
+	}
+	totalArea := 0.0
+	for _, polygon := range doc.Polygons {
+		totalArea += model.GeodesicPolygonArea(polygon.Points)
+	}
+
+	fmt.Fprintf(w, "Points: %d\n", len(doc.Points))
+	fmt.Fprintf(w, "Lines: %d (%d segments), total length %s\n", len(doc.Lines), segments, units.FormatDistance(totalLength))
+	for i, line := range doc.Lines {
+		fmt.Fprintf(w, "  Line %d: %s (%d segments)\n", i, units.FormatDistance(model.LineLength(line.Points)), len(line.Points)-1)
+	}
+	fmt.Fprintf(w, "Polygons: %d, total area %s\n", len(doc.Polygons), units.FormatArea(totalArea))
+	for i, polygon := range doc.Polygons {
+		fmt.Fprintf(w, "  Polygon %d: %s, perimeter %s\n", i, units.FormatArea(model.GeodesicPolygonArea(polygon.Points)), units.FormatDistance(model.GeodesicPerimeter(polygon.Points)))
+	}
+	return nil
+}
+
+// writeCSVReport writes one row per line and polygon with lengths in the
+// display unit and areas in its square
+func writeCSVReport(w io.Writer, doc *model.Document, units model.DistanceUnit) error {
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 3, 64)
+	}
+	perUnit := units.ToMeters(1)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"feature", "id", "vertices", "length", "area", "unit"})
+	for i, line := range doc.Lines {
+		writer.Write([]string{"line", strconv.Itoa(i), strconv.Itoa(len(line.Points)), format(units.FromMeters(model.LineLength(line.Points))), "", units.String()})
+	}
+	for i, polygon := range doc.Polygons {
+		area := model.GeodesicPolygonArea(polygon.Points) / (perUnit * perUnit)
+		writer.Write([]string{"polygon", strconv.Itoa(i), strconv.Itoa(len(polygon.Points)), format(units.FromMeters(model.GeodesicPerimeter(polygon.Points))), format(area), units.String()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func runScript(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("script", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: fiberforge script <file.scr>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no script files")
+	}
+
+	// Scripts run in an editing session without a window
+	e := editor.New()
+	e.Doc.LoadIcons = false
+	e.Stdout, e.Stderr = stdout, stderr
+	for _, file := range flags.Args() {
+		if err := e.RunScript(file); err != nil {
+			return err
+		}
+	}
+	e.FinishActiveCommand()
+	return nil
+}
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+// testdata/equator.kml has two points, a two segment line and a one segment
+// line along the equator, each 0.01° long, and a 0.01° square polygon. Along
+// the equator 0.01° is exactly a·π/18000 meters.
+const (
+	equatorKML      = "testdata/equator.kml"
+	equatorDropM    = 6378137 * math.Pi / 18000
+	equatorDropText = "1113.195"
+)
+
+func TestIsCommand(t *testing.T) {
+	for _, arg := range []string{"convert", "report", "script", "help"} {
+		if !IsCommand(arg) {
+			t.Errorf("%s should be a command", arg)
+		}
+	}
+	// Anything else is a file for the window to open
+	for _, arg := range []string{"drawing.kml", "-psn_0_12345", "REPORT"} {
+		if IsCommand(arg) {
+			t.Errorf("%s should not be a command", arg)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "daily.scr")
+	output := filepath.Join(dir, "drops.csv")
+	lines := []string{
+		"; Draw a line and export it",
+		"UNITS M",
+		"PL",
+		"35.156072,-90.051911",
+		"@100<90",
+		"",
+		"EXPORT " + output,
+	}
+	if err := os.WriteFile(script, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"script", script}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+
+	// Command output goes to the writer given, not the process's stdout
+	if !strings.Contains(stdout.String(), "> UNITS M") || !strings.Contains(stdout.String(), "> PL") {
+		t.Errorf("stdout is missing the commands run: %q", stdout.String())
+	}
+	rows := readCSVFile(t, output)
+	want := [][]string{
+		{"feature", "id", "vertex", "x", "y", "crs"},
+		{"line", "0", "0", "-90.05191100", "35.15607200", "EPSG:4326"},
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[:2], want) {
+		t.Fatalf("script exported %q, want %q and the second vertex", rows, want)
+	}
+	// The second vertex is 100 m east of the first
+	lon, _ := strconv.ParseFloat(rows[2][3], 64)
+	lat, _ := strconv.ParseFloat(rows[2][4], 64)
+	if dist := model.GeodesicDistance(35.156072, -90.051911, lat, lon); math.Abs(dist-100) > 0.01 {
+		t.Errorf("second vertex %.3f m from the first, want 100 m", dist)
+	}
+}
+
+func readCSVFile(t *testing.T, filename string) [][]string {
+	t.Helper()
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestRunConvertCSV(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "equator.csv")
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"convert", "-o", output, equatorKML}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Wrote 2 points, 2 lines and 1 polygons") {
+		t.Errorf("stderr %q does not sum up what was written", stderr.String())
+	}
+
+	want := [][]string{
+		{"feature", "id", "vertex", "x", "y", "crs"},
+		{"point", "0", "0", "0.00000000", "0.00000000", "EPSG:4326"},
+		{"point", "1", "0", "0.02000000", "0.00000000", "EPSG:4326"},
+		{"line", "0", "0", "0.00000000", "0.00000000", "EPSG:4326"},
+		{"line", "0", "1", "0.00500000", "0.00000000", "EPSG:4326"},
+		{"line", "0", "2", "0.01000000", "0.00000000", "EPSG:4326"},
+		{"line", "1", "0", "0.01000000", "0.00000000", "EPSG:4326"},
+		{"line", "1", "1", "0.02000000", "0.00000000", "EPSG:4326"},
+		{"polygon", "0", "0", "0.02000000", "0.00000000", "EPSG:4326"},
+		{"polygon", "0", "1", "0.03000000", "0.00000000", "EPSG:4326"},
+		{"polygon", "0", "2", "0.03000000", "0.01000000", "EPSG:4326"},
+		{"polygon", "0", "3", "0.02000000", "0.01000000", "EPSG:4326"},
+	}
+	if rows := readCSVFile(t, output); !reflect.DeepEqual(rows, want) {
+		t.Errorf("converted to\n%q\nwant\n%q", rows, want)
+	}
+}
+
+func TestRunConvertCSVProjected(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"convert", "-crs", "32631", equatorKML}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// UTM zone 31N is centred on 3°E, so the first point, on the equator at
+	// 0°, has northing 0 and an easting west of the false easting
+	if len(rows) != 12 {
+		t.Fatalf("got %d rows, want the header and 11 vertices", len(rows))
+	}
+	if first := rows[1]; first[4] != "0.000" || first[5] != "EPSG:32631" {
+		t.Errorf("first point written as %q", first)
+	}
+	x0, _ := strconv.ParseFloat(rows[1][3], 64)
+	x1, _ := strconv.ParseFloat(rows[2][3], 64)
+	if x0 > 500000-333000 || x0 < 500000-334500 {
+		t.Errorf("first point easting %.3f, want about 166,000", x0)
+	}
+	// Grid distances are the geodesic ones times the scale factor, over 0.9996
+	// this far from the central meridian
+	if dx := x1 - x0; dx < 2*equatorDropM || dx > 2*equatorDropM*1.001 {
+		t.Errorf("points %.3f m apart on the grid, want a little over %.3f m", dx, 2*equatorDropM)
+	}
+}
+
+func TestRunConvertGeoJSON(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "equator.geojson")
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"convert", "-o", output, equatorKML}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 5 {
+		t.Fatalf("got a %s of %d features, want a FeatureCollection of 5", collection.Type, len(collection.Features))
+	}
+
+	wantTypes := []string{"Point", "Point", "LineString", "LineString", "Polygon"}
+	for i, feature := range collection.Features {
+		if feature.Geometry.Type != wantTypes[i] {
+			t.Errorf("feature %d is a %s, want a %s", i, feature.Geometry.Type, wantTypes[i])
+		}
+		if layer := feature.Properties["layer"]; layer != "equator" {
+			t.Errorf("feature %d on layer %v, want equator after the file", i, layer)
+		}
+	}
+
+	if name := collection.Features[1].Properties["name"]; name != "HH-2" {
+		t.Errorf("second point named %v, want HH-2", name)
+	}
+	var point [2]float64
+	if err := json.Unmarshal(collection.Features[1].Geometry.Coordinates, &point); err != nil || point != [2]float64{0.02, 0} {
+		t.Errorf("second point at %v, %v, want lon,lat 0.02,0", point, err)
+	}
+	for _, i := range []int{2, 3} {
+		if length := collection.Features[i].Properties["length_m"].(float64); math.Abs(length-equatorDropM) > 0.001 {
+			t.Errorf("line %d length %.4f m, want %.4f m", i-2, length, equatorDropM)
+		}
+	}
+
+	// Polygon rings are closed
+	var rings [][][2]float64
+	if err := json.Unmarshal(collection.Features[4].Geometry.Coordinates, &rings); err != nil {
+		t.Fatal(err)
+	}
+	if len(rings) != 1 || len(rings[0]) != 5 || rings[0][0] != rings[0][4] {
+		t.Errorf("polygon rings %v, want one closed ring of 5 positions", rings)
+	}
+	if area := collection.Features[4].Properties["area_m2"].(float64); area < 1.22e6 || area > 1.24e6 {
+		t.Errorf("polygon area %.0f m², want about 1,230,900 m²", area)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"report", "-units", "m", equatorKML}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+
+	lot := []model.PolyPoint{{Lat: 0, Lon: 0.02}, {Lat: 0, Lon: 0.03}, {Lat: 0.01, Lon: 0.03}, {Lat: 0.01, Lon: 0.02}}
+	want := strings.Join([]string{
+		"Points: 2",
+		"Lines: 2 (3 segments), total length 2226.4 m",
+		"  Line 0: 1113.2 m (2 segments)",
+		"  Line 1: 1113.2 m (1 segments)",
+		"Polygons: 1, total area " + model.UnitMeters.FormatArea(model.GeodesicPolygonArea(lot)),
+		"  Polygon 0: " + model.UnitMeters.FormatArea(model.GeodesicPolygonArea(lot)) + ", perimeter 4437.9 m",
+		"",
+	}, "\n")
+	if stdout.String() != want {
+		t.Errorf("report\n%s\nwant\n%s", stdout.String(), want)
+	}
+	if !strings.Contains(want, "(123.") {
+		t.Errorf("lot area %s, want about 123 ha", model.UnitMeters.FormatArea(model.GeodesicPolygonArea(lot)))
+	}
+}
+
+func TestRunReportCSV(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"report", "-format", "csv", "-units", "m", equatorKML}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"feature", "id", "vertices", "length", "area", "unit"},
+		{"line", "0", "3", equatorDropText, "", "m"},
+		{"line", "1", "2", equatorDropText, "", "m"},
+	}
+	if len(rows) != 4 || !reflect.DeepEqual(rows[:3], want) {
+		t.Fatalf("report\n%q\nwant\n%q and the polygon", rows, want)
+	}
+	polygon := rows[3]
+	area, _ := strconv.ParseFloat(polygon[4], 64)
+	if polygon[0] != "polygon" || polygon[2] != "4" || polygon[3] != "4437.875" || area < 1.22e6 || area > 1.24e6 {
+		t.Errorf("polygon row %q", polygon)
+	}
+}
+
+func TestRunReportErrors(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.geojson")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"report"}, "no input files"},
+		{[]string{"report", "-units", "yd", equatorKML}, "unknown"},
+		{[]string{"report", "-format", "xml", equatorKML}, "unknown format"},
+		{[]string{"report", "testdata/missing.kml"}, "missing.kml"},
+		{[]string{"convert", "-o", output, "-crs", "2274", equatorKML}, "GeoJSON is always lon/lat"},
+		{[]string{"convert", "-o", output, "-format", "kml", equatorKML}, "unknown format"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := Run(test.args, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), test.want) {
+			t.Errorf("%q: exit code %d, stderr %q, want 1 and %q", test.args, code, stderr.String(), test.want)
+		}
+	}
+	// Bad options are caught before the output file is created
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("a failed convert created %s", output)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"frobnicate"}, &stdout, &stderr); code != 2 {
+		t.Errorf("exit code %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "unknown command") {
+		t.Errorf("stderr %q does not report the unknown command", stderr.String())
+	}
+}
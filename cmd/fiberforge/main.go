@@ -0,0 +1,18 @@
+// Command fiberforge converts, reports on and runs scripts against map files
+// without opening a window, for servers and build machines with no display.
+// The FiberForge window runs the same subcommands when given one.
+package main
+
+import (
+	"os"
+
+	"github.com/OpticalFlyer/FiberForge/cli"
+)
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"help"}
+	}
+	os.Exit(cli.Run(args, os.Stdout, os.Stderr))
+}
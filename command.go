@@ -0,0 +1,67 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/editor"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	commandOutputLines = 24               // Lines shown above the command line
+	commandOutputTime  = 15 * time.Second // How long output stays on screen
+)
+
+// submitCommandLine executes the line typed in the command box
+func (g *Game) submitCommandLine() {
+	line := strings.TrimSpace(g.TextBoxText)
+	g.TextBoxText = ""
+	g.commandHistory.Add(line)
+
+	if err := g.RunCommandLine(line); err != nil {
+		g.PrintError(err)
+	}
+}
+
+// completeCommand completes the command name being typed, listing the
+// candidates when more than one command matches
+func (g *Game) completeCommand() {
+	completed, candidates := editor.CompleteCommand(g.TextBoxText)
+	g.TextBoxText = completed
+	if len(candidates) > 0 {
+		g.PrintMessage("%s", strings.Join(candidates, "  "))
+	}
+}
+
+// drawCommandOutput draws recent command output above the command line
+func (g *Game) drawCommandOutput(screen *ebiten.Image, boxX, boxY, boxWidth int) {
+	var lines []editor.Message
+	for i := len(g.Output) - 1; i >= 0 && len(lines) < commandOutputLines; i-- {
+		if time.Since(g.Output[i].Time) > commandOutputTime {
+			break
+		}
+		lines = append(lines, g.Output[i])
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	lineHeight := 16
+	height := len(lines)*lineHeight + 8
+	vector.DrawFilledRect(screen, float32(boxX), float32(boxY-height-4), float32(boxWidth), float32(height), color.RGBA{50, 50, 50, 200}, false)
+
+	fontFace := basicfont.Face7x13
+	for i, line := range lines {
+		clr := color.Color(color.White)
+		if line.IsError {
+			clr = color.RGBA{255, 96, 96, 255}
+		}
+		y := boxY - 12 - i*lineHeight
+		text.Draw(screen, line.Text, fontFace, boxX+10, y, clr)
+	}
+}
@@ -0,0 +1,618 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/gps"
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/atotto/clipboard"
+)
+
+// Command is a command line command
+type Command struct {
+	Name    string
+	Aliases []string
+	Args    string // Usage of the arguments, e.g. "<epsg>" or "[file]"
+	MinArgs int
+	MaxArgs int // -1 for any number
+	Help    string
+	Repeat  bool // Space on an empty command line runs it again
+	Run     func(e *Editor, args []string) error
+}
+
+var commands []*Command
+var commandsByName map[string]*Command
+
+func init() {
+	commands = []*Command{
+		{Name: "PL", Aliases: []string{"PLINE"}, Help: "Draw poly line", Repeat: true, Run: func(e *Editor, args []string) error {
+			e.FinishActiveCommand()
+			e.PL_activated = true
+			return nil
+		}},
+		{Name: "PO", Aliases: []string{"POINT"}, Args: "[type [key=value...]]", MaxArgs: -1, Help: "Draw point, or a symbol such as PO HANDHOLE tier=22, see SYMBOLS", Repeat: true, Run: func(e *Editor, args []string) error {
+			var symbol *SymbolType
+			var attrs map[string]string
+			if len(args) > 0 {
+				if symbol = lookupSymbolType(args[0]); symbol == nil {
+					return fmt.Errorf("unknown symbol %q, use %s", args[0], symbolTypeNames())
+				}
+				var err error
+				if attrs, err = symbol.ParseAttributes(args[1:]); err != nil {
+					return err
+				}
+			}
+
+			e.FinishActiveCommand()
+			e.PO_activated = true
+			e.PointSymbol, e.pointAttrs = symbol, attrs
+			if symbol != nil {
+				e.PrintMessage("Placing %s: %s", symbol.Label, FormatAttributes(attrs))
+			}
+			return nil
+		}},
+		{Name: "SYMBOLS", Help: "List the symbols PO can place and their attributes", Run: func(e *Editor, args []string) error {
+			for _, symbol := range SymbolTypes {
+				name := symbol.Name
+				if len(symbol.Aliases) > 0 {
+					name += " (" + strings.Join(symbol.Aliases, ", ") + ")"
+				}
+				e.PrintMessage("%s - %s: %s", name, symbol.Label, symbol.attributeKeys())
+			}
+			return nil
+		}},
+		{Name: "POL", Aliases: []string{"POLYGON"}, Help: "Draw polygon", Repeat: true, Run: func(e *Editor, args []string) error {
+			e.FinishActiveCommand()
+			e.POL_activated = true
+			e.PolygonObject.Style = model.DefaultPolygonStyle()
+			return nil
+		}},
+		{Name: "DIST", Aliases: []string{"DI"}, Help: "Measure the running total distance of clicked points", Repeat: true, Run: func(e *Editor, args []string) error {
+			e.FinishActiveCommand()
+			e.DIST_activated = true
+			return nil
+		}},
+		{Name: "AREA", Aliases: []string{"AA"}, Help: "Measure the area and perimeter of clicked points", Repeat: true, Run: func(e *Editor, args []string) error {
+			e.FinishActiveCommand()
+			e.AREA_activated = true
+			return nil
+		}},
+		{Name: "BEARING", Aliases: []string{"BRG"}, Help: "Measure the bearing and distance between two clicked points", Repeat: true, Run: func(e *Editor, args []string) error {
+			e.FinishActiveCommand()
+			e.BEARING_activated = true
+			return nil
+		}},
+		{Name: "OSNAP", Aliases: []string{"OS"}, Args: "[END|VER|MID|INT|NEA|ALL|NONE]...", MaxArgs: -1, Help: "Turn object snap on or off, or toggle snap modes", Run: func(e *Editor, args []string) error {
+			if len(args) == 0 {
+				return e.ToggleOsnap("")
+			}
+			for _, arg := range args {
+				if err := e.ToggleOsnap(arg); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{Name: "UNITS", Args: "<FT|M|MI|KM>", MinArgs: 1, MaxArgs: 1, Help: "Display distances and areas in feet, meters, miles or kilometers", Run: func(e *Editor, args []string) error {
+			units, err := model.ParseDistanceUnit(args[0])
+			if err != nil {
+				return err
+			}
+			e.Units = units
+			e.PrintMessage("Units %s", units)
+			return nil
+		}},
+		{Name: "CRS", Args: "[epsg]", MaxArgs: 1, Help: "Show cursor coordinates in a projected coordinate system, none for lat/lon", Run: func(e *Editor, args []string) error {
+			crs, err := model.ParseCRSArgument(strings.Join(args, ""))
+			if err != nil {
+				return err
+			}
+			e.DisplayCRS = crs
+			e.PrintMessage("Display CRS %s", crsDescription(crs))
+			return nil
+		}},
+		{Name: "IMPORTCRS", Args: "[epsg]", MaxArgs: 1, Help: "Read imported CSV coordinates as x,y in a projected coordinate system", Run: func(e *Editor, args []string) error {
+			crs, err := model.ParseCRSArgument(strings.Join(args, ""))
+			if err != nil {
+				return err
+			}
+			e.Doc.ImportCRS = crs
+			e.PrintMessage("Import CRS %s", crsDescription(crs))
+			return nil
+		}},
+		{Name: "EXPORTCRS", Args: "[epsg]", MaxArgs: 1, Help: "Write exported coordinates in a projected coordinate system", Run: func(e *Editor, args []string) error {
+			crs, err := model.ParseCRSArgument(strings.Join(args, ""))
+			if err != nil {
+				return err
+			}
+			e.ExportCRS = crs
+			e.PrintMessage("Export CRS %s", crsDescription(crs))
+			return nil
+		}},
+		{Name: "MAPIMPORT", Aliases: []string{"IMPORT"}, Args: "[file]", MaxArgs: -1, Help: "Load KML, KMZ, GPX or CSV from a file, or the file path on the clipboard", Run: func(e *Editor, args []string) error {
+			filename, err := fileArgument(args)
+			if err != nil {
+				return err
+			}
+			if err := model.LoadMapFile(filename, e.Doc); err != nil {
+				return err
+			}
+			e.PrintMessage("Imported %s", filename)
+			return nil
+		}},
+		{Name: "MAPEXPORT", Aliases: []string{"EXPORT"}, Args: "[file]", MaxArgs: -1, Help: "Save all features as CSV to a file, or the file path on the clipboard", Run: func(e *Editor, args []string) error {
+			filename, err := fileArgument(args)
+			if err != nil {
+				return err
+			}
+			if err := model.ExportCSVFile(filename, e.Doc, e.ExportCRS); err != nil {
+				return err
+			}
+			e.PrintMessage("Exported %s", filename)
+			return nil
+		}},
+		{Name: "LAYER", Aliases: []string{"LA"}, Args: "[name]", MaxArgs: -1, Help: "Draw new features on a layer, adding it if there is none, or show the current layer", Run: func(e *Editor, args []string) error {
+			if len(args) > 0 {
+				e.Doc.CurrentLayer = e.Doc.Layer(strings.Join(args, " "))
+				e.Doc.Layers[e.Doc.CurrentLayer].Visible = true
+				e.Doc.Changed()
+			}
+			e.PrintMessage("Current layer %s", e.Doc.Layers[e.Doc.CurrentLayer].Name)
+			return nil
+		}},
+		{Name: "LAYERS", Help: "List the layers, whether each is shown and how many features it has", Run: func(e *Editor, args []string) error {
+			for i, layer := range e.Doc.Layers {
+				e.PrintMessage("%s", e.layerDescription(i, layer))
+			}
+			return nil
+		}},
+		{Name: "LAYERON", Args: "<name>", MinArgs: 1, MaxArgs: -1, Help: "Show the features on a layer", Run: func(e *Editor, args []string) error {
+			return e.Doc.SetLayerVisible(strings.Join(args, " "), true)
+		}},
+		{Name: "LAYEROFF", Args: "<name>", MinArgs: 1, MaxArgs: -1, Help: "Hide the features on a layer", Run: func(e *Editor, args []string) error {
+			name := strings.Join(args, " ")
+			if index, exists := e.Doc.LayerIndex(name); exists && index == e.Doc.CurrentLayer {
+				return fmt.Errorf("%s is the current layer, change layers with LAYER first", e.Doc.Layers[index].Name)
+			}
+			return e.Doc.SetLayerVisible(name, false)
+		}},
+		{Name: "OSM", Help: "OpenStreetMap base map", Run: basemapCommand(OSM)},
+		{Name: "GOOGLEAERIAL", Help: "Google aerial base map", Run: basemapCommand(GOOGLEAERIAL)},
+		{Name: "GOOGLEHYBRID", Help: "Google hybrid base map", Run: basemapCommand(GOOGLEHYBRID)},
+		{Name: "BINGAERIAL", Help: "Bing aerial base map", Run: basemapCommand(BINGAERIAL)},
+		{Name: "BINGHYBRID", Help: "Bing hybrid base map", Run: basemapCommand(BINGHYBRID)},
+		{Name: "STARTGPS", Args: "[port [baud] | tcp://host:port | udp://:port | gpsd://host[:port] | replay://file [speed]]", MaxArgs: 2, Help: "Start reading positions from a serial GPS, NMEA over TCP or UDP, gpsd, or a recorded NMEA log", Run: func(e *Editor, args []string) error {
+			if e.GPS.Running() {
+				return fmt.Errorf("GPS already reading from %s, STOPGPS first", e.GPS.Source())
+			}
+			source, err := gps.ParseGPSSource(args)
+			if err != nil {
+				return err
+			}
+			if err := e.GPS.StartGPS(source); err != nil {
+				return fmt.Errorf("%s: %v", source, err)
+			}
+			e.PrintMessage("GPS reading from %s", source)
+			return nil
+		}},
+		{Name: "GPSPORTS", Help: "List serial ports a GPS could be connected to", Run: func(e *Editor, args []string) error {
+			ports := gps.SerialPorts()
+			if len(ports) == 0 {
+				e.PrintMessage("No serial ports found")
+			}
+			for _, port := range ports {
+				e.PrintMessage("%s", port)
+			}
+			return nil
+		}},
+		{Name: "STOPGPS", Help: "Stop reading positions from the GPS", Run: func(e *Editor, args []string) error {
+			e.GPS.StopGPS()
+			return nil
+		}},
+		{Name: "GPSSPEED", Args: "<factor>", MinArgs: 1, MaxArgs: 1, Help: "Set the speed of an NMEA log replay, e.g. 4 or 0.5", Run: func(e *Editor, args []string) error {
+			replay := e.GPS.Replay()
+			if replay == nil {
+				return fmt.Errorf("not replaying an NMEA log")
+			}
+			speed, err := gps.ParseReplaySpeed(args[0])
+			if err != nil {
+				return err
+			}
+			replay.SetSpeed(speed)
+			e.PrintMessage("Replaying at %gx", speed)
+			return nil
+		}},
+		{Name: "GPSPAUSE", Help: "Pause or resume an NMEA log replay", Run: func(e *Editor, args []string) error {
+			replay := e.GPS.Replay()
+			if replay == nil {
+				return fmt.Errorf("not replaying an NMEA log")
+			}
+			if replay.TogglePause() {
+				e.PrintMessage("Replay paused")
+			} else {
+				e.PrintMessage("Replay resumed")
+			}
+			return nil
+		}},
+		{Name: "GPSLOG", Args: "[file]", MaxArgs: -1, Help: "Save the NMEA sentences read from the GPS to a file, again with no file to stop", Run: func(e *Editor, args []string) error {
+			if len(args) == 0 {
+				if err := e.GPS.StopNMEALog(); err != nil {
+					return err
+				}
+				e.PrintMessage("Stopped NMEA log")
+				return nil
+			}
+			filename := strings.Join(args, " ")
+			if err := e.GPS.StartNMEALog(filename); err != nil {
+				return err
+			}
+			e.PrintMessage("Logging NMEA to %s", filename)
+			return nil
+		}},
+		{Name: "FOLLOW", Aliases: []string{"GPSFOLLOW"}, Help: "Keep the map centered on the GPS position, again to stop", Run: func(e *Editor, args []string) error {
+			if !e.GPSFollow && !e.GPS.Running() {
+				return fmt.Errorf("GPS is not running, STARTGPS first")
+			}
+			e.ToggleGPSFollow()
+			return nil
+		}},
+		{Name: "GPSPOINT", Args: "[seconds [maxhdop]]", MaxArgs: 2, Help: "Place a point at the average of the GPS fixes over some seconds, leaving out fixes with a high HDOP", Run: func(e *Editor, args []string) error {
+			seconds, maxHDOP := float64(DefaultAverageSeconds), DefaultAverageMaxHDOP
+			if len(args) > 0 {
+				value, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || value <= 0 {
+					return fmt.Errorf("invalid seconds %q", args[0])
+				}
+				seconds = value
+			}
+			if len(args) > 1 {
+				value, err := strconv.ParseFloat(args[1], 64)
+				if err != nil || value <= 0 {
+					return fmt.Errorf("invalid HDOP %q", args[1])
+				}
+				maxHDOP = value
+			}
+			return e.startGPSAverage(seconds, maxHDOP)
+		}},
+		{Name: "TRACKSTART", Args: "[distance [seconds]]", MaxArgs: 2, Help: "Record the GPS path as a track, adding a point every distance in display units and seconds, or resume a paused track", Run: func(e *Editor, args []string) error {
+			if !e.GPS.Running() {
+				return fmt.Errorf("GPS is not running, STARTGPS first")
+			}
+			if e.Track != nil && e.Track.Recording() {
+				if len(args) == 0 && e.Track.Paused() {
+					e.Track.TogglePause()
+					e.PrintMessage("Track resumed")
+					return nil
+				}
+				return fmt.Errorf("already recording a track, TRACKSTOP first")
+			}
+			// Starting over throws the last track away, so don't lose one that
+			// was never saved without asking
+			if e.Track != nil && e.Track.Unsaved() && !e.discardTrack {
+				e.discardTrack = true
+				return fmt.Errorf("the last track of %d points is not saved, TRACKEXPORT or TRACKLINE it, or TRACKSTART again to discard it", len(e.Track.Line.Points))
+			}
+
+			minDistance, minInterval := gps.DefaultTrackDistance, gps.DefaultTrackInterval
+			if len(args) > 0 {
+				distance, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || distance < 0 {
+					return fmt.Errorf("invalid distance %q", args[0])
+				}
+				minDistance = e.Units.ToMeters(distance)
+			}
+			if len(args) > 1 {
+				seconds, err := strconv.ParseFloat(args[1], 64)
+				if err != nil || seconds < 0 {
+					return fmt.Errorf("invalid interval %q", args[1])
+				}
+				minInterval = time.Duration(seconds * float64(time.Second))
+			}
+
+			e.Track = gps.NewTrack(minDistance, minInterval)
+			e.discardTrack = false
+			e.PrintMessage("Recording track, a point every %s and %s", e.Units.FormatDistance(minDistance), minInterval)
+			return nil
+		}},
+		{Name: "TRACKPAUSE", Help: "Pause or resume the track being recorded", Run: func(e *Editor, args []string) error {
+			if e.Track == nil || !e.Track.Recording() {
+				return fmt.Errorf("not recording a track")
+			}
+			if e.Track.TogglePause() {
+				e.PrintMessage("Track paused")
+			} else {
+				e.PrintMessage("Track resumed")
+			}
+			return nil
+		}},
+		{Name: "TRACKSTOP", Help: "Stop recording the track", Run: func(e *Editor, args []string) error {
+			if e.Track == nil || !e.Track.Recording() {
+				return fmt.Errorf("not recording a track")
+			}
+			e.Track.Stop()
+			e.PrintMessage("%s", e.Track.Status(e.Units))
+			return nil
+		}},
+		{Name: "TRACKEXPORT", Args: "<file.gpx>", MinArgs: 1, MaxArgs: -1, Help: "Save the recorded track as GPX 1.1", Run: func(e *Editor, args []string) error {
+			if e.Track == nil || len(e.Track.Line.Points) == 0 {
+				return fmt.Errorf("no track recorded")
+			}
+			filename := strings.Join(args, " ")
+			if err := e.Track.WriteGPXFile(filename); err != nil {
+				return err
+			}
+			e.Track.MarkSaved()
+			e.PrintMessage("Exported %d track points to %s", len(e.Track.Line.Points), filename)
+			return nil
+		}},
+		{Name: "TRACKLINE", Help: "Add the recorded track to the map as design lines, one for each stretch between pauses", Run: func(e *Editor, args []string) error {
+			if e.Track == nil || len(e.Track.Line.Points) < 2 {
+				return fmt.Errorf("no track recorded")
+			}
+			// Each segment becomes its own line, rather than joining across pauses
+			for _, points := range e.Track.Segments() {
+				if len(points) < 2 {
+					continue
+				}
+				line := model.PolyLine{Points: append([]model.LinePoint(nil), points...), Color: e.Line.Color, Width: e.Line.Width, Layer: e.Doc.CurrentLayer}
+				line.Points[0].Dist = 0
+				e.Doc.AddLine(line)
+				e.PrintMessage("Added a line of %d points, %s", len(line.Points), e.Units.FormatDistance(model.LineLength(line.Points)))
+			}
+			e.Track.MarkSaved()
+			return nil
+		}},
+		{Name: "SCRIPT", Aliases: []string{"SCR"}, Args: "<file>", MinArgs: 1, MaxArgs: -1, Help: "Run the commands and coordinates in a script file", Run: func(e *Editor, args []string) error {
+			return e.RunScript(strings.Join(args, " "))
+		}},
+		{Name: "RECORD", Args: "[file]", MaxArgs: -1, Help: "Record commands and clicked points to a script file, again with no file to stop", Run: func(e *Editor, args []string) error {
+			if len(args) == 0 {
+				return e.stopRecording()
+			}
+			return e.startRecording(strings.Join(args, " "))
+		}},
+		{Name: "HELP", Aliases: []string{"?"}, Args: "[command]", MaxArgs: 1, Help: "List commands, or describe one", Run: func(e *Editor, args []string) error {
+			if len(args) == 1 {
+				command := LookupCommand(args[0])
+				if command == nil {
+					return fmt.Errorf("unknown command %q", args[0])
+				}
+				e.PrintMessage("%s - %s", commandUsage(command), command.Help)
+				if len(command.Aliases) > 0 {
+					e.PrintMessage("  Aliases: %s", strings.Join(command.Aliases, ", "))
+				}
+				return nil
+			}
+			for _, command := range commands {
+				e.PrintMessage("%s - %s", commandUsage(command), command.Help)
+			}
+			return nil
+		}},
+	}
+
+	commandsByName = make(map[string]*Command)
+	for _, command := range commands {
+		commandsByName[command.Name] = command
+		for _, alias := range command.Aliases {
+			commandsByName[alias] = command
+		}
+	}
+}
+
+func basemapCommand(basemap string) func(e *Editor, args []string) error {
+	return func(e *Editor, args []string) error {
+		e.Basemap = basemap
+		return nil
+	}
+}
+
+// fileArgument joins the arguments back into a path, which may contain
+// spaces, falling back to the path on the clipboard
+func fileArgument(args []string) (string, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), nil
+	}
+	clipboardContent, err := clipboard.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("error reading clipboard: %v", err)
+	}
+	filename := strings.TrimSpace(clipboardContent)
+	if filename == "" {
+		return "", fmt.Errorf("no file given and no file path on the clipboard")
+	}
+	return filename, nil
+}
+
+// layerDescription describes a layer for LAYERS
+func (e *Editor) layerDescription(index int, layer model.Layer) string {
+	points, lines, polygons := 0, 0, 0
+	for _, point := range e.Doc.Points {
+		if point.Layer == index {
+			points++
+		}
+	}
+	for _, line := range e.Doc.Lines {
+		if line.Layer == index {
+			lines++
+		}
+	}
+	for _, polygon := range e.Doc.Polygons {
+		if polygon.Layer == index {
+			polygons++
+		}
+	}
+
+	state := "on"
+	if !layer.Visible {
+		state = "off"
+	}
+	if index == e.Doc.CurrentLayer {
+		state += ", current"
+	}
+	return fmt.Sprintf("%s (%s): %d points, %d lines, %d polygons", layer.Name, state, points, lines, polygons)
+}
+
+func crsDescription(crs *model.CRS) string {
+	if crs == nil {
+		return "lat/lon"
+	}
+	return crs.String()
+}
+
+func LookupCommand(name string) *Command {
+	return commandsByName[strings.ToUpper(name)]
+}
+
+// CommandNames lists the names and aliases of every command, for completion
+func CommandNames() []string {
+	names := make([]string, 0, len(commandsByName))
+	for name := range commandsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompleteCommand completes the command name typed on a command line. A single
+// match, or a whole command name, is completed followed by a space when the
+// command takes arguments, which is how optional arguments are started as
+// space runs the command. Several matches are completed as far as they agree,
+// or returned for listing when that adds nothing to what was typed.
+func CompleteCommand(line string) (string, []string) {
+	if line == "" || strings.Contains(line, " ") {
+		return line, nil
+	}
+	prefix := strings.ToUpper(line)
+
+	var matches []string
+	for _, name := range CommandNames() {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	if command := LookupCommand(prefix); command != nil && command.MaxArgs != 0 {
+		return prefix + " ", nil
+	}
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		if LookupCommand(matches[0]).MaxArgs != 0 {
+			return matches[0] + " ", nil
+		}
+		return matches[0], nil
+	}
+
+	// Extend to the longest prefix the matches share
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		return common, nil
+	}
+	return line, matches
+}
+
+func commandUsage(command *Command) string {
+	if command.Args == "" {
+		return command.Name
+	}
+	return command.Name + " " + command.Args
+}
+
+// SpaceSeparatesArgs reports whether space on the command line separates
+// command arguments rather than executing the command. Space runs a command
+// typed on its own unless it requires arguments, so PO or LAYER run as they
+// are while UNITS waits for its unit, and once arguments are started the rest
+// of the line is left to return.
+func SpaceSeparatesArgs(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	command := LookupCommand(fields[0])
+	if command == nil || command.MaxArgs == 0 {
+		return false
+	}
+	return len(fields) > 1 || command.MinArgs > 0
+}
+
+// executeCommand runs one command line
+func (e *Editor) executeCommand(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	command := LookupCommand(fields[0])
+	if command == nil {
+		return fmt.Errorf("unknown command %q, type HELP for a list of commands", fields[0])
+	}
+	args := fields[1:]
+	if len(args) < command.MinArgs || command.MaxArgs >= 0 && len(args) > command.MaxArgs {
+		return fmt.Errorf("usage: %s", commandUsage(command))
+	}
+	return command.Run(e, args)
+}
+
+// FinishActiveCommand saves the line or polygon being drawn, or finishes the
+// active measurement, reporting false when no command was active
+func (e *Editor) FinishActiveCommand() bool {
+	switch {
+	case e.PL_activated: // Save new line
+		e.PL_activated = false
+		if len(e.Line.Points) > 0 {
+			e.Line.Layer = e.Doc.CurrentLayer
+			e.Doc.AddLine(e.Line)
+			e.Line.Points = nil
+		}
+	case e.POL_activated: // Save new polygon
+		e.POL_activated = false
+		if len(e.PolygonObject.Points) > 2 {
+			e.PolygonObject.Layer = e.Doc.CurrentLayer
+			e.Doc.AddPolygon(e.PolygonObject)
+		}
+		e.PolygonObject.Points = nil
+	case e.Measuring(): // Finish measurement
+		e.finishMeasurement()
+	case e.PO_activated: // End point mode
+		e.PO_activated = false
+	default:
+		return false
+	}
+	return true
+}
+
+// RunCommandLine executes one command line. An empty line finishes the active
+// command or repeats the last one, and coordinates are added to the active
+// drawing or measurement.
+func (e *Editor) RunCommandLine(line string) error {
+	e.recordCommandLine(line)
+
+	if line == "" {
+		if e.FinishActiveCommand() || e.LastCmdText == "" {
+			return nil
+		}
+		line = e.LastCmdText
+	}
+
+	if (e.Drawing() || e.Measuring()) && isCoordinateEntry(line) { // Typed coordinates
+		return e.enterCoordinates(line)
+	}
+
+	e.PrintMessage("> %s", line)
+	if err := e.executeCommand(line); err != nil {
+		return err
+	}
+	if fields := strings.Fields(line); LookupCommand(fields[0]).Repeat {
+		e.LastCmdText = line
+	}
+	return nil
+}
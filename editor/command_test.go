@@ -0,0 +1,270 @@
+package editor
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+func newTestEditor() *Editor {
+	e := New()
+	e.Stdout, e.Stderr = io.Discard, io.Discard
+	return e
+}
+
+func TestCommandRegistry(t *testing.T) {
+	seen := make(map[string]string)
+	for _, command := range commands {
+		names := append([]string{command.Name}, command.Aliases...)
+		for _, name := range names {
+			if name != strings.ToUpper(name) {
+				t.Errorf("%s: name %q is not upper case", command.Name, name)
+			}
+			if other, ok := seen[name]; ok {
+				t.Errorf("%s: name %q is already taken by %s", command.Name, name, other)
+			}
+			seen[name] = command.Name
+			if LookupCommand(name) != command {
+				t.Errorf("%s: LookupCommand(%q) finds another command", command.Name, name)
+			}
+		}
+
+		if command.Run == nil || command.Help == "" {
+			t.Errorf("%s: missing Run or Help", command.Name)
+		}
+		// The usage shows required arguments in <> and optional ones in []
+		switch {
+		case command.MaxArgs == 0 && command.Args != "":
+			t.Errorf("%s: takes no arguments but its usage is %q", command.Name, command.Args)
+		case command.MaxArgs != 0 && command.Args == "":
+			t.Errorf("%s: takes arguments but has no usage", command.Name)
+		case command.MinArgs > 0 && !strings.HasPrefix(command.Args, "<"):
+			t.Errorf("%s: requires arguments but its usage %q shows them optional", command.Name, command.Args)
+		case command.MinArgs == 0 && strings.HasPrefix(command.Args, "<"):
+			t.Errorf("%s: usage %q shows optional arguments as required", command.Name, command.Args)
+		case command.MaxArgs >= 0 && command.MinArgs > command.MaxArgs:
+			t.Errorf("%s: MinArgs %d over MaxArgs %d", command.Name, command.MinArgs, command.MaxArgs)
+		}
+	}
+
+	if names := CommandNames(); len(names) != len(seen) {
+		t.Errorf("CommandNames lists %d names, want %d", len(names), len(seen))
+	}
+}
+
+func TestLookupCommandAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"PL", "PL"},
+		{"pline", "PL"},
+		{"Point", "PO"},
+		{"polygon", "POL"},
+		{"DI", "DIST"},
+		{"aa", "AREA"},
+		{"BRG", "BEARING"},
+		{"os", "OSNAP"},
+		{"IMPORT", "MAPIMPORT"},
+		{"export", "MAPEXPORT"},
+		{"LA", "LAYER"},
+		{"GPSFOLLOW", "FOLLOW"},
+		{"scr", "SCRIPT"},
+		{"?", "HELP"},
+	}
+	for _, test := range tests {
+		command := LookupCommand(test.name)
+		if command == nil || command.Name != test.want {
+			t.Errorf("LookupCommand(%q) = %v, want %s", test.name, command, test.want)
+		}
+	}
+	for _, name := range []string{"", "P", "PLINES", "LAYER ON"} {
+		if command := LookupCommand(name); command != nil {
+			t.Errorf("LookupCommand(%q) = %s, want none", name, command.Name)
+		}
+	}
+
+	// An alias runs the command it stands for
+	e := newTestEditor()
+	if err := e.RunCommandLine("di"); err != nil {
+		t.Fatal(err)
+	}
+	if !e.DIST_activated {
+		t.Error("DI did not start DIST")
+	}
+}
+
+func TestCompleteCommand(t *testing.T) {
+	tests := []struct {
+		line       string
+		want       string
+		candidates []string
+	}{
+		{"uni", "UNITS ", nil},       // Takes arguments, so a space follows
+		{"TRACKL", "TRACKLINE", nil}, // Takes none
+		{"GPSSP", "GPSSPEED ", nil},  // Requires them
+		{"BI", "BING", nil},          // Extended as far as the matches agree
+		{"LAYE", "LAYER", nil},
+		{"BING", "BING", []string{"BINGAERIAL", "BINGHYBRID"}},
+		{"layero", "layero", []string{"LAYEROFF", "LAYERON"}},
+		{"PO", "PO ", nil}, // A whole name taking arguments, though longer ones match
+		{"layer", "LAYER ", nil},
+		{"la", "LA ", nil},
+		{"POL", "POL", []string{"POL", "POLYGON"}},
+		{"POI", "POINT ", nil},
+		{"XYZ", "XYZ", nil},
+		{"PO HAND", "PO HAND", nil}, // Only the command name completes
+		{"", "", nil},
+	}
+	for _, test := range tests {
+		got, candidates := CompleteCommand(test.line)
+		if got != test.want || !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("CompleteCommand(%q) = %q, %v, want %q, %v", test.line, got, candidates, test.want, test.candidates)
+		}
+	}
+}
+
+func TestSpaceSeparatesArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		// Space runs commands typed on their own whose arguments are optional
+		{"PO ", false},
+		{"LAYER ", false},
+		{"LA ", false},
+		{"HELP ", false},
+		{"GPSPOINT ", false},
+		{"TRACKSTART ", false},
+		{"STARTGPS ", false},
+		{"PL ", false},
+		// And separates arguments once they are required or started
+		{"UNITS ", true},
+		{"TRACKEXPORT ", true},
+		{"PO HANDHOLE ", true},
+		{"LAYER Fiber ", true},
+		{"STARTGPS COM3 ", true},
+		{"PO  ", false}, // Space again after Tab added one
+		{"", false},
+		{"35.1,-90.05 ", false},
+		{"FLY ", false},
+	}
+	for _, test := range tests {
+		if got := SpaceSeparatesArgs(test.line); got != test.want {
+			t.Errorf("SpaceSeparatesArgs(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestCommandHistory(t *testing.T) {
+	var h CommandHistory
+	if got := h.Browse(-1, "typing"); got != "typing" {
+		t.Errorf("browsing an empty history shows %q", got)
+	}
+
+	for _, line := range []string{"PL", "", "UNITS M", "UNITS M", "CRS 2274"} {
+		h.Add(line)
+	}
+
+	// Empty lines and repeats aren't kept, and the line being typed comes back
+	// after the newest entry
+	steps := []struct {
+		step int
+		want string
+	}{
+		{-1, "CRS 2274"},
+		{-1, "UNITS M"},
+		{-1, "PL"},
+		{-1, "PL"}, // Stays at the oldest
+		{1, "UNITS M"},
+		{1, "CRS 2274"},
+		{1, "LAY"},
+		{1, "LAY"},
+	}
+	current := "LAY"
+	for i, step := range steps {
+		current = h.Browse(step.step, current)
+		if current != step.want {
+			t.Errorf("step %d: showing %q, want %q", i, current, step.want)
+		}
+	}
+
+	// Entering a line goes back to the end of the history
+	h.Browse(-1, "")
+	h.Browse(-1, "")
+	h.Add("OSNAP")
+	if got := h.Browse(-1, ""); got != "OSNAP" {
+		t.Errorf("after adding, browsing back shows %q, want OSNAP", got)
+	}
+
+	for i := 0; i < maxCommandHistory+10; i++ {
+		h.Add(strings.Repeat("A", i+1))
+	}
+	if len(h.lines) != maxCommandHistory {
+		t.Errorf("history keeps %d lines, want %d", len(h.lines), maxCommandHistory)
+	}
+}
+
+func TestExecuteCommandArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want string // Part of the error, empty for none
+	}{
+		{"UNITS", "usage: UNITS <FT|M|MI|KM>"},
+		{"UNITS M FT", "usage: UNITS <FT|M|MI|KM>"},
+		{"UNITS M", ""},
+		{"units km", ""},
+		{"LAYERON", "usage: LAYERON <name>"},
+		{"LAYER Fiber Drops", ""}, // Any number of arguments
+		{"CRS 2274 4326", "usage: CRS [epsg]"},
+		{"CRS", ""},
+		{"GPSSPEED", "usage: GPSSPEED <factor>"},
+		{"TRACKEXPORT", "usage: TRACKEXPORT <file.gpx>"},
+		{"PL now", "usage: PL"},
+		{"HELP UNITS PL", "usage: HELP [command]"},
+		{"HELP", ""},
+		{"FLY", `unknown command "FLY"`},
+		{"UNITS YD", "unknown"},
+	}
+
+	for _, test := range tests {
+		e := newTestEditor()
+		err := e.executeCommand(test.line)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%q: %v", test.line, err)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("%q: error %v, want %q", test.line, err, test.want)
+		}
+	}
+}
+
+func TestRunCommandLineRepeats(t *testing.T) {
+	e := newTestEditor()
+	if err := e.RunCommandLine("UNITS M"); err != nil {
+		t.Fatal(err)
+	}
+	if e.Units != model.UnitMeters {
+		t.Errorf("units %s, want M", e.Units)
+	}
+
+	// An empty line finishes the active command, then repeats the last
+	// repeatable one
+	for _, line := range []string{"PL", "35.1,-90.05", "35.2,-90.05", ""} {
+		if err := e.RunCommandLine(line); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+	}
+	if e.PL_activated || len(e.Doc.Lines) != 1 {
+		t.Fatalf("PL active %v with %d lines, want the line saved", e.PL_activated, len(e.Doc.Lines))
+	}
+	if err := e.RunCommandLine(""); err != nil {
+		t.Fatal(err)
+	}
+	if !e.PL_activated {
+		t.Error("an empty line did not repeat PL")
+	}
+}
@@ -0,0 +1,171 @@
+package editor
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/atotto/clipboard"
+)
+
+func (e *Editor) Drawing() bool {
+	return e.PL_activated || e.PO_activated || e.POL_activated
+}
+
+// AddDrawingPoint adds a vertex to the line or polygon being drawn, a new
+// point object or a measurement point, depending on the active command
+func (e *Editor) AddDrawingPoint(lat, lon float64) {
+	switch {
+	case e.Measuring():
+		e.addMeasurePoint(lat, lon)
+	case e.PL_activated:
+		dist := 0.0
+		if len(e.Line.Points) > 0 {
+			prevPoint := len(e.Line.Points) - 1
+			dist = model.GeodesicDistance(e.Line.Points[prevPoint].Lat, e.Line.Points[prevPoint].Lon, lat, lon)
+		}
+		e.Line.Points = append(e.Line.Points, model.LinePoint{Lat: lat, Lon: lon, Dist: dist})
+	case e.PO_activated:
+		clr := color.RGBA{255, 255, 255, 255}
+		point := model.PointObject{Lat: lat, Lon: lon, Color: clr, Scale: 1.0, Layer: e.Doc.CurrentLayer}
+		if e.PointSymbol != nil {
+			point.Type = e.PointSymbol.Name
+			point.Attrs = make(map[string]string, len(e.pointAttrs))
+			for key, value := range e.pointAttrs {
+				point.Attrs[key] = value
+			}
+		}
+		e.Doc.AddPoint(point)
+	case e.POL_activated:
+		e.PolygonObject.Points = append(e.PolygonObject.Points, model.PolyPoint{Lat: lat, Lon: lon})
+	}
+}
+
+// lastDrawingPoint returns the point typed relative coordinates start from
+func (e *Editor) lastDrawingPoint() (model.PolyPoint, bool) {
+	switch {
+	case e.PL_activated && len(e.Line.Points) > 0:
+		last := e.Line.Points[len(e.Line.Points)-1]
+		return model.PolyPoint{Lat: last.Lat, Lon: last.Lon}, true
+	case e.PO_activated && len(e.Doc.Points) > 0:
+		last := e.Doc.Points[len(e.Doc.Points)-1]
+		return model.PolyPoint{Lat: last.Lat, Lon: last.Lon}, true
+	case e.POL_activated && len(e.PolygonObject.Points) > 0:
+		return e.PolygonObject.Points[len(e.PolygonObject.Points)-1], true
+	case e.Measuring() && len(e.MeasurePoints) > 0:
+		return e.MeasurePoints[len(e.MeasurePoints)-1], true
+	}
+	return model.PolyPoint{}, false
+}
+
+// isCoordinateEntry reports whether typed text is a coordinate rather than a command
+func isCoordinateEntry(s string) bool {
+	if s == "" {
+		return false
+	}
+	return strings.HasPrefix(s, "@") || strings.ContainsAny(s[:1], "0123456789+-.")
+}
+
+// parseCoordinateEntry parses a typed position. Absolute positions are lat,lon
+// or x,y in the display CRS when one is set. Relative positions are
+// @distance<bearing from the last point, with the distance in the display
+// units and the bearing in degrees clockwise from north.
+func (e *Editor) parseCoordinateEntry(s string, last model.PolyPoint, haveLast bool) (model.PolyPoint, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "@") {
+		if !haveLast {
+			return model.PolyPoint{}, fmt.Errorf("%s: no previous point to measure from", s)
+		}
+		distanceText, bearingText, ok := strings.Cut(s[1:], "<")
+		if !ok {
+			return model.PolyPoint{}, fmt.Errorf("%s: use @distance<bearing", s)
+		}
+		distance, err := strconv.ParseFloat(strings.TrimSpace(distanceText), 64)
+		if err != nil {
+			return model.PolyPoint{}, fmt.Errorf("%s: bad distance %q", s, distanceText)
+		}
+		bearing, err := strconv.ParseFloat(strings.TrimSpace(bearingText), 64)
+		if err != nil {
+			return model.PolyPoint{}, fmt.Errorf("%s: bad bearing %q", s, bearingText)
+		}
+		lat, lon := model.VincentyDirect(last.Lat, last.Lon, model.NormalizeBearing(bearing), e.Units.ToMeters(distance))
+		return model.PolyPoint{Lat: lat, Lon: lon}, nil
+	}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) != 2 {
+		return model.PolyPoint{}, fmt.Errorf("%s: use lat,lon or @distance<bearing", s)
+	}
+	first, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return model.PolyPoint{}, fmt.Errorf("%s: bad number %q", s, fields[0])
+	}
+	second, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return model.PolyPoint{}, fmt.Errorf("%s: bad number %q", s, fields[1])
+	}
+
+	if e.DisplayCRS != nil {
+		lat, lon := e.DisplayCRS.ToLatLng(first, second)
+		return model.PolyPoint{Lat: lat, Lon: lon}, nil
+	}
+	if first < -90 || first > 90 || second < -180 || second > 180 {
+		return model.PolyPoint{}, fmt.Errorf("%s: latitude or longitude out of range", s)
+	}
+	return model.PolyPoint{Lat: first, Lon: second}, nil
+}
+
+// enterCoordinates adds each position in a list of typed or pasted
+// coordinates, one per line or separated by semicolons. Nothing is added
+// unless every position parses.
+func (e *Editor) enterCoordinates(text string) error {
+	entries := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ';'
+	})
+
+	last, haveLast := e.lastDrawingPoint()
+	var points []model.PolyPoint
+	for i, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		point, err := e.parseCoordinateEntry(entry, last, haveLast)
+		if err != nil {
+			if len(entries) > 1 {
+				return fmt.Errorf("line %d: %v", i+1, err)
+			}
+			return err
+		}
+		points = append(points, point)
+		last, haveLast = point, true
+	}
+
+	for _, point := range points {
+		e.AddDrawingPoint(point.Lat, point.Lon)
+	}
+	e.PrintMessage("Entered %d points", len(points))
+	return nil
+}
+
+// PasteCoordinates adds the coordinate list on the clipboard to the active drawing
+func (e *Editor) PasteCoordinates() {
+	clipboardContent, err := clipboard.ReadAll()
+	if err != nil {
+		e.PrintError(fmt.Errorf("error reading clipboard: %v", err))
+		return
+	}
+	if err := e.enterCoordinates(clipboardContent); err != nil {
+		e.PrintError(err)
+		return
+	}
+	for _, line := range strings.Split(clipboardContent, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			e.recordCommandLine(line)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package editor
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+func TestIsCoordinateEntry(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"35.1,-90.05", true},
+		{"-90.05 35.1", true},
+		{"+35.1,-90.05", true},
+		{".5,.5", true},
+		{"@100<45", true},
+		{"@", true},
+		{"PL", false},
+		{"OSNAP END", false},
+		{"?", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := isCoordinateEntry(test.s); got != test.want {
+			t.Errorf("isCoordinateEntry(%q) = %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseCoordinateEntry(t *testing.T) {
+	last := model.PolyPoint{Lat: 35.156072, Lon: -90.051911}
+	tennessee, err := model.LookupCRS(2274)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// For typing 1000 feet east of the last point in Tennessee State Plane feet
+	lastX, lastY := tennessee.FromLatLng(last.Lat, last.Lon)
+
+	tests := []struct {
+		name     string
+		s        string
+		units    model.DistanceUnit
+		crs      *model.CRS
+		distance float64 // Meters from the last point, or -1 to check lat/lon
+		bearing  float64
+		lat, lon float64
+	}{
+		{"lat,lon", "35.1,-90.05", model.UnitFeet, nil, -1, 0, 35.1, -90.05},
+		{"spaces", " 35.1  -90.05 ", model.UnitFeet, nil, -1, 0, 35.1, -90.05},
+		{"comma and space", "35.1, -90.05", model.UnitFeet, nil, -1, 0, 35.1, -90.05},
+		{"feet", "@100<45", model.UnitFeet, nil, 30.48, 45, 0, 0},
+		{"meters", "@100<90", model.UnitMeters, nil, 100, 90, 0, 0},
+		{"miles", "@ 1 < 180", model.UnitMiles, nil, 1609.344, 180, 0, 0},
+		{"kilometers", "@2.5<270", model.UnitKilometers, nil, 2500, 270, 0, 0},
+		{"negative bearing", "@100<-90", model.UnitMeters, nil, 100, 270, 0, 0},
+		{"bearing past 360", "@100<405", model.UnitMeters, nil, 100, 45, 0, 0},
+		{"display CRS", fmt.Sprintf("%.3f,%.3f", lastX+1000, lastY), model.UnitFeet, tennessee, 1000 * model.USSurveyFoot, 90, 0, 0},
+		{"relative in a display CRS", "@1000<90", model.UnitFeet, tennessee, 304.8, 90, 0, 0},
+	}
+
+	for _, test := range tests {
+		e := New()
+		e.Units = test.units
+		e.DisplayCRS = test.crs
+		point, err := e.parseCoordinateEntry(test.s, last, true)
+		if err != nil {
+			t.Errorf("%s: %q: %v", test.name, test.s, err)
+			continue
+		}
+
+		if test.distance < 0 {
+			if point.Lat != test.lat || point.Lon != test.lon {
+				t.Errorf("%s: %q parsed as %f, %f, want %f, %f", test.name, test.s, point.Lat, point.Lon, test.lat, test.lon)
+			}
+			continue
+		}
+		distance := model.GeodesicDistance(last.Lat, last.Lon, point.Lat, point.Lon)
+		bearing := model.GeodesicBearing(last.Lat, last.Lon, point.Lat, point.Lon)
+		// State Plane grid north is a little off true north away from the
+		// central meridian, and its scale factor is not quite 1
+		if math.Abs(distance-test.distance) > 0.001*test.distance || math.Abs(bearing-test.bearing) > 5 {
+			t.Errorf("%s: %q is %.3f m at %.3f°, want %.3f m at %g°", test.name, test.s, distance, bearing, test.distance, test.bearing)
+		}
+		if test.crs == nil && math.Abs(bearing-test.bearing) > 1e-6 {
+			t.Errorf("%s: %q at %.8f°, want %g°", test.name, test.s, bearing, test.bearing)
+		}
+	}
+}
+
+func TestParseCoordinateEntryErrors(t *testing.T) {
+	last := model.PolyPoint{Lat: 35.156072, Lon: -90.051911}
+	tests := []struct {
+		s        string
+		haveLast bool
+		want     string
+	}{
+		{"@100<45", false, "no previous point"},
+		{"@100", true, "use @distance<bearing"},
+		{"@far<45", true, "bad distance"},
+		{"@100<north", true, "bad bearing"},
+		{"35.1", true, "use lat,lon"},
+		{"35.1,-90.05,10", true, "use lat,lon"},
+		{"35.1,west", true, "bad number"},
+		{"north,-90.05", true, "bad number"},
+		{"95,-90.05", true, "out of range"},
+		{"35.1,-190", true, "out of range"},
+		{"-90.05,35.1", true, "out of range"}, // Lon,lat typed the KML way round
+	}
+
+	for _, test := range tests {
+		e := New()
+		_, err := e.parseCoordinateEntry(test.s, last, test.haveLast)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: error %v, want %q", test.s, err, test.want)
+		}
+	}
+}
+
+func TestEnterCoordinates(t *testing.T) {
+	e := New()
+	e.Stdout = io.Discard
+	e.Units = model.UnitMeters
+	e.PL_activated = true
+
+	// A pasted list, one per line or separated by semicolons, each relative
+	// entry measured from the one before it
+	pasted := "35.1,-90.05\r\n@100<90\n\n@100<0; 35.2,-90.1\n"
+	if err := e.enterCoordinates(pasted); err != nil {
+		t.Fatalf("enterCoordinates: %v", err)
+	}
+	if len(e.Line.Points) != 4 {
+		t.Fatalf("line has %d points, want 4", len(e.Line.Points))
+	}
+	for i, want := range []float64{0, 100, 100} {
+		if dist := e.Line.Points[i].Dist; math.Abs(dist-want) > 0.001 {
+			t.Errorf("point %d is %.4f m from the one before, want %g m", i, dist, want)
+		}
+	}
+	if point := e.Line.Points[3]; point.Lat != 35.2 || point.Lon != -90.1 {
+		t.Errorf("last point at %f, %f, want 35.2, -90.1", point.Lat, point.Lon)
+	}
+
+	// A bad entry anywhere adds nothing and names its line
+	err := e.enterCoordinates("35.3,-90.05\n35.3,west\n")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error %v, want one for line 2", err)
+	}
+	if len(e.Line.Points) != 4 {
+		t.Errorf("line has %d points after a bad list, want 4", len(e.Line.Points))
+	}
+}
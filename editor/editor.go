@@ -0,0 +1,116 @@
+package editor
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/gps"
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+const (
+	GOOGLEHYBRID = "GOOGLEHYBRID"
+	GOOGLEAERIAL = "GOOGLEAERIAL"
+	BINGHYBRID   = "BINGHYBRID"
+	BINGAERIAL   = "BINGAERIAL"
+	OSM          = "OSM"
+)
+
+const maxCommandOutput = 200
+
+// Editor is an editing session, the document and view along with the
+// command being drawn or measured, the GPS and the settings commands change.
+// It runs commands and scripts the same way with or without a window, the
+// window reads the state it draws from here.
+type Editor struct {
+	Doc     *model.Document
+	View    model.Viewport
+	Basemap string
+
+	LastCmdText       string
+	Line              model.PolyLine
+	PolygonObject     model.PolygonObject
+	PointSymbol       *SymbolType // Symbol PO places, nil for a plain point
+	pointAttrs        map[string]string
+	PL_activated      bool
+	PO_activated      bool
+	POL_activated     bool
+	DIST_activated    bool
+	AREA_activated    bool
+	BEARING_activated bool
+	MeasurePoints     []model.PolyPoint
+	MeasureResults    []string
+
+	GPS          *gps.GPS
+	Track        *gps.Track // Track being recorded, or the last one recorded
+	GPSFollow    bool       // Keep the map centered on the GPS position
+	gpsAverage   *gpsAverage
+	discardTrack bool // TRACKSTART warned the last track was unsaved, again discards it
+
+	DisplayCRS *model.CRS // nil for plain lat/lon
+	ExportCRS  *model.CRS
+	Units      model.DistanceUnit
+
+	OsnapEnabled bool
+	OsnapModes   SnapMode
+	Snap         SnapResult
+	Snapped      bool
+
+	// Messages go to Stdout and errors to Stderr, and both to Output for
+	// showing above the command line
+	Stdout io.Writer
+	Stderr io.Writer
+	Output []Message
+
+	scriptDepth int
+	scriptDir   string // Folder of the script running, for the paths of nested scripts
+	recordFile  *os.File
+}
+
+// Message is a line of command output
+type Message struct {
+	Text    string
+	IsError bool
+	Time    time.Time
+}
+
+// New starts a session with an empty document
+func New() *Editor {
+	e := &Editor{
+		Doc:          model.NewDocument(),
+		Basemap:      GOOGLEAERIAL,
+		GPS:          gps.NewGPS(),
+		OsnapEnabled: true,
+		OsnapModes:   SnapAll,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+	}
+	e.View.CenterLat = 35.156072
+	e.View.CenterLon = -90.051911
+	e.View.Zoom = 5
+
+	e.Line.Color = color.RGBA{0, 255, 255, 255}
+	e.Line.Width = 3.0
+	return e
+}
+
+func (e *Editor) PrintMessage(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintln(e.Stdout, message)
+	e.addOutput(Message{Text: message, Time: time.Now()})
+}
+
+func (e *Editor) PrintError(err error) {
+	fmt.Fprintln(e.Stderr, "Error:", err)
+	e.addOutput(Message{Text: "Error: " + err.Error(), IsError: true, Time: time.Now()})
+}
+
+func (e *Editor) addOutput(message Message) {
+	e.Output = append(e.Output, message)
+	if len(e.Output) > maxCommandOutput {
+		e.Output = e.Output[len(e.Output)-maxCommandOutput:]
+	}
+}
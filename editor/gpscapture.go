@@ -0,0 +1,149 @@
+package editor
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/gps"
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+const (
+	DefaultAverageSeconds = 10  // How long GPSPOINT collects fixes
+	DefaultAverageMaxHDOP = 2.0 // Fixes with a higher HDOP are left out of the average
+)
+
+// gpsAverage collects fixes for GPSPOINT
+type gpsAverage struct {
+	until    time.Time
+	maxHDOP  float64
+	samples  []gps.GPSFix
+	rejected int
+	lastFix  time.Time // Received time of the last fix looked at
+}
+
+// ToggleGPSFollow turns keeping the map centered on the GPS position on or off
+func (e *Editor) ToggleGPSFollow() {
+	e.GPSFollow = !e.GPSFollow
+	if e.GPSFollow {
+		e.PrintMessage("Following GPS")
+		e.RecenterOnGPS()
+	} else {
+		e.PrintMessage("Stopped following GPS")
+	}
+}
+
+// RecenterOnGPS moves the map to the GPS position, if there is one
+func (e *Editor) RecenterOnGPS() {
+	if !e.GPS.Running() {
+		return
+	}
+	fix := e.GPS.Fix()
+	if fix.HasFix() {
+		e.View.CenterLat, e.View.CenterLon = fix.Latitude, fix.Longitude
+	}
+}
+
+// UpdateGPS adds the latest GPS fix to the track being recorded and any
+// point being averaged, called for every frame or as often as fixes are wanted
+func (e *Editor) UpdateGPS() {
+	if e.Track != nil && e.GPS.Running() {
+		e.Track.Add(e.GPS.Fix())
+	}
+	e.updateGPSAverage()
+}
+
+// startGPSAverage starts collecting fixes for a point
+func (e *Editor) startGPSAverage(seconds, maxHDOP float64) error {
+	if !e.GPS.Running() {
+		return fmt.Errorf("GPS is not running, STARTGPS first")
+	}
+	if e.gpsAverage != nil {
+		return fmt.Errorf("already averaging a GPS point")
+	}
+	e.gpsAverage = &gpsAverage{
+		until:   time.Now().Add(time.Duration(seconds * float64(time.Second))),
+		maxHDOP: maxHDOP,
+	}
+	e.PrintMessage("Averaging GPS fixes for %gs, HDOP up to %g", seconds, maxHDOP)
+	return nil
+}
+
+// updateGPSAverage adds the latest fix to the average and places the point
+// once the time is up
+func (e *Editor) updateGPSAverage() {
+	average := e.gpsAverage
+	if average == nil {
+		return
+	}
+	if !e.GPS.Running() {
+		e.gpsAverage = nil
+		e.PrintError(fmt.Errorf("GPS stopped, point not placed"))
+		return
+	}
+
+	fix := e.GPS.Fix()
+	if fix.HasFix() && !fix.Stale() && fix.Received.After(average.lastFix) {
+		average.lastFix = fix.Received
+		if fix.HDOP > 0 && fix.HDOP <= average.maxHDOP {
+			average.samples = append(average.samples, fix)
+		} else {
+			average.rejected++
+		}
+	}
+
+	if time.Now().Before(average.until) {
+		return
+	}
+	e.gpsAverage = nil
+
+	if len(average.samples) == 0 {
+		e.PrintError(fmt.Errorf("no fixes with HDOP up to %g, %d rejected, point not placed", average.maxHDOP, average.rejected))
+		return
+	}
+
+	// Over a few meters the mean of the coordinates is as good as a geodesic mean
+	lat, lon := 0.0, 0.0
+	for _, sample := range average.samples {
+		lat += sample.Latitude
+		lon += sample.Longitude
+	}
+	lat /= float64(len(average.samples))
+	lon /= float64(len(average.samples))
+
+	// Spread of the samples around the mean, and their accuracy
+	capture := &model.GPSCapture{Fixes: len(average.samples), Time: average.samples[len(average.samples)-1].Time}
+	sumSquares := 0.0
+	for _, sample := range average.samples {
+		d := model.GeodesicDistance(lat, lon, sample.Latitude, sample.Longitude)
+		sumSquares += d * d
+		capture.Accuracy += sample.HorizontalAccuracy()
+		capture.HDOP += sample.HDOP
+		if gps.QualityRank(sample.Quality) > gps.QualityRank(capture.Quality) {
+			capture.Quality = sample.Quality
+		}
+	}
+	capture.Spread = math.Sqrt(sumSquares / float64(len(average.samples)))
+	capture.Accuracy /= float64(len(average.samples))
+	capture.HDOP /= float64(len(average.samples))
+
+	e.Doc.AddPoint(model.PointObject{Lat: lat, Lon: lon, Color: color.RGBA{255, 255, 255, 255}, Scale: 1.0, Capture: capture, Layer: e.Doc.CurrentLayer})
+	e.PrintMessage("Placed point at %.8f, %.8f from %d fixes (%d rejected), accuracy %s, spread %s", lat, lon, capture.Fixes, average.rejected,
+		e.Units.FormatDistance(capture.Accuracy), e.Units.FormatDistance(capture.Spread))
+}
+
+// AverageStatus describes the averaging in progress for the GPS panel, empty
+// when there is none
+func (e *Editor) AverageStatus() string {
+	a := e.gpsAverage
+	if a == nil {
+		return ""
+	}
+	left := time.Until(a.until).Seconds()
+	if left < 0 {
+		left = 0
+	}
+	return fmt.Sprintf("Averaging: %d fixes, %.0fs left", len(a.samples), left)
+}
@@ -0,0 +1,74 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/gps"
+)
+
+// recordTrack starts a track and adds a couple of fixes to it, as UpdateGPS
+// would from the receiver
+func recordTrack(t *testing.T, e *Editor) {
+	t.Helper()
+	if err := e.RunCommandLine("TRACKSTART 1 0"); err != nil {
+		t.Fatalf("TRACKSTART: %v", err)
+	}
+	received := time.Now()
+	for i, lat := range []float64{35.15, 35.151} {
+		e.Track.Add(gps.GPSFix{Latitude: lat, Longitude: -90.05, Received: received.Add(time.Duration(i) * time.Millisecond)})
+	}
+	if err := e.RunCommandLine("TRACKSTOP"); err != nil {
+		t.Fatalf("TRACKSTOP: %v", err)
+	}
+}
+
+func TestTrackStartKeepsUnsavedTrack(t *testing.T) {
+	e := newTestEditor()
+	if err := e.RunCommandLine("STARTGPS udp://127.0.0.1:0"); err != nil {
+		t.Fatalf("STARTGPS: %v", err)
+	}
+	defer e.GPS.StopGPS()
+
+	recordTrack(t, e)
+	recorded := e.Track
+
+	// Starting again warns and keeps the track, then a second TRACKSTART
+	// discards it
+	err := e.RunCommandLine("TRACKSTART")
+	if err == nil || !strings.Contains(err.Error(), "not saved") {
+		t.Fatalf("TRACKSTART over an unsaved track: error %v, want a warning", err)
+	}
+	if e.Track != recorded {
+		t.Fatal("unsaved track replaced")
+	}
+	if err := e.RunCommandLine("TRACKSTART"); err != nil {
+		t.Fatalf("TRACKSTART again: %v", err)
+	}
+	if e.Track == recorded || !e.Track.Recording() {
+		t.Error("TRACKSTART again did not start a new track")
+	}
+
+	// An exported track or one added to the map is replaced without asking,
+	// and the warning is asked again for the next unsaved one
+	if err := e.RunCommandLine("TRACKSTOP"); err != nil {
+		t.Fatal(err)
+	}
+	for _, save := range []string{"TRACKEXPORT " + filepath.Join(t.TempDir(), "drive.gpx"), "TRACKLINE"} {
+		recordTrack(t, e)
+		if err := e.RunCommandLine("TRACKSTART"); err == nil {
+			t.Fatalf("%s: no warning for an unsaved track", save)
+		}
+		if err := e.RunCommandLine(save); err != nil {
+			t.Fatalf("%s: %v", save, err)
+		}
+		if err := e.RunCommandLine("TRACKSTART"); err != nil {
+			t.Errorf("TRACKSTART after %s: %v", save, err)
+		}
+		if err := e.RunCommandLine("TRACKSTOP"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package editor
+
+const maxCommandHistory = 100
+
+// CommandHistory holds the command lines entered, for stepping back through
+// them with the arrow keys
+type CommandHistory struct {
+	lines []string
+	index int    // Line being shown, len(lines) for the line being typed
+	draft string // Line being typed before browsing started
+}
+
+// Add records an entered command line, skipping a repeat of the last one, and
+// goes back to the line being typed
+func (h *CommandHistory) Add(line string) {
+	if line != "" && (len(h.lines) == 0 || h.lines[len(h.lines)-1] != line) {
+		h.lines = append(h.lines, line)
+		if len(h.lines) > maxCommandHistory {
+			h.lines = h.lines[len(h.lines)-maxCommandHistory:]
+		}
+	}
+	h.index = len(h.lines)
+}
+
+// Browse steps through the history, -1 for older and 1 for newer, and returns
+// the line to show in place of current. Moving past the newest entry restores
+// the line that was being typed.
+func (h *CommandHistory) Browse(step int, current string) string {
+	if h.index == len(h.lines) {
+		h.draft = current
+	}
+	index := h.index + step
+	if index < 0 || index > len(h.lines) {
+		return current
+	}
+	h.index = index
+	if index == len(h.lines) {
+		return h.draft
+	}
+	return h.lines[index]
+}
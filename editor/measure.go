@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+const maxMeasureResults = 10 // Results kept in the on-screen history
+
+func (e *Editor) Measuring() bool {
+	return e.DIST_activated || e.AREA_activated || e.BEARING_activated
+}
+
+// addMeasurePoint adds a clicked point to the active measurement, a bearing
+// is complete as soon as it has both ends
+func (e *Editor) addMeasurePoint(lat, lon float64) {
+	e.MeasurePoints = append(e.MeasurePoints, model.PolyPoint{Lat: lat, Lon: lon})
+	if e.BEARING_activated && len(e.MeasurePoints) == 2 {
+		e.finishMeasurement()
+	}
+}
+
+// finishMeasurement records the active measurement in the results history
+func (e *Editor) finishMeasurement() {
+	if text, ok := e.MeasureText(e.MeasurePoints); ok {
+		e.addMeasureResult(text)
+	}
+	e.DIST_activated = false
+	e.AREA_activated = false
+	e.BEARING_activated = false
+	e.MeasurePoints = nil
+}
+
+// MeasureText describes the measurement of the given points for the active
+// measure mode, reporting false when there are too few points to measure
+func (e *Editor) MeasureText(points []model.PolyPoint) (string, bool) {
+	switch {
+	case e.DIST_activated:
+		if len(points) < 2 {
+			return "", false
+		}
+		total := 0.0
+		for i := 1; i < len(points); i++ {
+			total += model.GeodesicDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+		}
+		last := model.GeodesicDistance(points[len(points)-2].Lat, points[len(points)-2].Lon, points[len(points)-1].Lat, points[len(points)-1].Lon)
+		return fmt.Sprintf("DIST %s (%d segments, last %s)", e.Units.FormatDistance(total), len(points)-1, e.Units.FormatDistance(last)), true
+	case e.AREA_activated:
+		if len(points) < 3 {
+			return "", false
+		}
+		return fmt.Sprintf("AREA %s, perimeter %s", e.Units.FormatArea(model.GeodesicPolygonArea(points)), e.Units.FormatDistance(model.GeodesicPerimeter(points))), true
+	case e.BEARING_activated:
+		if len(points) < 2 {
+			return "", false
+		}
+		from, to := points[0], points[1]
+		bearing := model.GeodesicBearing(from.Lat, from.Lon, to.Lat, to.Lon)
+		distance := model.GeodesicDistance(from.Lat, from.Lon, to.Lat, to.Lon)
+		return fmt.Sprintf("BEARING %.2f deg (%s), %s", bearing, formatQuadrantBearing(bearing), e.Units.FormatDistance(distance)), true
+	}
+	return "", false
+}
+
+func (e *Editor) addMeasureResult(text string) {
+	fmt.Fprintln(e.Stdout, text)
+	e.MeasureResults = append(e.MeasureResults, text)
+	if len(e.MeasureResults) > maxMeasureResults {
+		e.MeasureResults = e.MeasureResults[len(e.MeasureResults)-maxMeasureResults:]
+	}
+}
+
+// ReportLine records the total length of an existing line
+func (e *Editor) ReportLine(index int) {
+	line := e.Doc.Lines[index]
+	e.addMeasureResult(fmt.Sprintf("LINE %d: %s (%d segments)", index, e.Units.FormatDistance(model.LineLength(line.Points)), len(line.Points)-1))
+}
+
+// ReportPolygon records the area and perimeter of an existing polygon
+func (e *Editor) ReportPolygon(index int) {
+	polygon := e.Doc.Polygons[index]
+	e.addMeasureResult(fmt.Sprintf("POLYGON %d: %s, perimeter %s", index, e.Units.FormatArea(model.GeodesicPolygonArea(polygon.Points)), e.Units.FormatDistance(model.GeodesicPerimeter(polygon.Points))))
+}
+
+// formatQuadrantBearing formats an azimuth as a surveyor's bearing, e.g. N45d30'00"E
+func formatQuadrantBearing(azimuth float64) string {
+	northSouth, eastWest := "N", "E"
+	angle := azimuth
+	switch {
+	case azimuth > 90 && azimuth <= 180:
+		northSouth, angle = "S", 180-azimuth
+	case azimuth > 180 && azimuth <= 270:
+		northSouth, eastWest, angle = "S", "W", azimuth-180
+	case azimuth > 270:
+		eastWest, angle = "W", 360-azimuth
+	}
+
+	totalSeconds := int(math.Round(angle * 3600))
+	degrees := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	seconds := totalSeconds % 60
+	return fmt.Sprintf("%s%02dd%02d'%02d\"%s", northSouth, degrees, minutes, seconds, eastWest)
+}
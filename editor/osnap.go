@@ -0,0 +1,255 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+// SnapMode is a set of object snap modes
+type SnapMode int
+
+const (
+	SnapEndpoint     SnapMode = 1 << iota // Line ends and point objects
+	SnapVertex                            // Interior line vertices and polygon vertices
+	SnapMidpoint                          // Middle of line and polygon segments
+	SnapIntersection                      // Crossings of two segments
+	SnapNearest                           // Closest point on a segment
+
+	SnapAll = SnapEndpoint | SnapVertex | SnapMidpoint | SnapIntersection | SnapNearest
+)
+
+const snapTolerance = 10.0 // Pixels
+
+var snapModeNames = []struct {
+	mode SnapMode
+	name string
+}{
+	{SnapEndpoint, "END"},
+	{SnapVertex, "VER"},
+	{SnapMidpoint, "MID"},
+	{SnapIntersection, "INT"},
+	{SnapNearest, "NEA"},
+}
+
+func (m SnapMode) String() string {
+	var names []string
+	for _, mode := range snapModeNames {
+		if m&mode.mode != 0 {
+			names = append(names, mode.name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, " ")
+}
+
+// ParseSnapMode parses a snap mode name, e.g. END or MID, as well as ALL and NONE
+func ParseSnapMode(s string) (SnapMode, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch s {
+	case "ALL":
+		return SnapAll, nil
+	case "NONE":
+		return 0, nil
+	}
+	for _, mode := range snapModeNames {
+		if s == mode.name {
+			return mode.mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown snap mode %q, use END, VER, MID, INT, NEA, ALL or NONE", s)
+}
+
+// SnapResult is the location the cursor snapped to and the mode that found it
+type SnapResult struct {
+	Lat, Lon float64
+	Mode     SnapMode
+}
+
+type snapSegment struct {
+	x0, y0, x1, y1 float64
+}
+
+// ToggleOsnap handles the OSNAP command. With no argument snapping is switched
+// on or off, otherwise the named mode is toggled.
+func (e *Editor) ToggleOsnap(arg string) error {
+	if arg == "" {
+		e.OsnapEnabled = !e.OsnapEnabled
+	} else {
+		mode, err := ParseSnapMode(arg)
+		if err != nil {
+			return err
+		}
+		switch mode {
+		case SnapAll, 0:
+			e.OsnapModes = mode
+		default:
+			e.OsnapModes ^= mode
+		}
+		e.OsnapEnabled = true
+	}
+	e.PrintMessage("OSNAP %s", e.OsnapStatus())
+	return nil
+}
+
+func (e *Editor) OsnapStatus() string {
+	if !e.OsnapEnabled {
+		return "OFF"
+	}
+	return e.OsnapModes.String()
+}
+
+// CursorLatLng returns the location under the cursor, snapped to existing
+// geometry while a drawing or measuring command is active
+func (e *Editor) CursorLatLng(view model.Viewport, mouseX, mouseY int) (float64, float64) {
+	e.Snapped = false
+	if e.OsnapEnabled && (e.Drawing() || e.Measuring()) {
+		e.Snap, e.Snapped = e.findSnap(view, float64(mouseX), float64(mouseY))
+	}
+	if e.Snapped {
+		return e.Snap.Lat, e.Snap.Lon
+	}
+	return view.ScreenToLatLng(float64(mouseX), float64(mouseY))
+}
+
+// findSnap searches the geometry near the cursor for the closest snap point.
+// Endpoints, vertices, midpoints and intersections win over nearest-on-segment
+// so that the cursor does not slide along a line past a vertex.
+func (e *Editor) findSnap(view model.Viewport, mouseX, mouseY float64) (SnapResult, bool) {
+	modes := e.OsnapModes
+	best := SnapResult{}
+	bestDistance := math.Inf(1)
+	found := false
+
+	consider := func(lat, lon, x, y float64, mode SnapMode) {
+		if modes&mode == 0 {
+			return
+		}
+		distance := math.Hypot(x-mouseX, y-mouseY)
+		if distance > snapTolerance {
+			return
+		}
+		// A nearest snap only counts when nothing better is in range
+		if found && best.Mode != SnapNearest && mode == SnapNearest {
+			return
+		}
+		if found && best.Mode == SnapNearest && mode != SnapNearest || distance < bestDistance {
+			best = SnapResult{Lat: lat, Lon: lon, Mode: mode}
+			bestDistance = distance
+			found = true
+		}
+	}
+	considerScreen := func(x, y float64, mode SnapMode) {
+		if modes&mode == 0 {
+			return
+		}
+		lat, lon := view.ScreenToLatLng(x, y)
+		consider(lat, lon, x, y, mode)
+	}
+	considerPoint := func(lat, lon float64, mode SnapMode) {
+		x, y := view.Project(lat, model.NearestLongitude(lon, view.CenterLon))
+		consider(lat, lon, x, y, mode)
+	}
+
+	// Segments close enough to the cursor to snap to
+	var nearby []snapSegment
+	considerSegment := func(lat0, lon0, lat1, lon1 float64) {
+		lon0 = model.NearestLongitude(lon0, view.CenterLon)
+		lon1 = model.NearestLongitude(lon1, lon0)
+		x0, y0 := view.Project(lat0, lon0)
+		x1, y1 := view.Project(lat1, lon1)
+		if model.PointLineSegmentDistance(mouseX, mouseY, x0, y0, x1, y1) > snapTolerance {
+			return
+		}
+		nearby = append(nearby, snapSegment{x0, y0, x1, y1})
+
+		considerScreen((x0+x1)/2, (y0+y1)/2, SnapMidpoint)
+		if modes&SnapNearest != 0 {
+			x, y := model.ClosestPointOnSegment(mouseX, mouseY, x0, y0, x1, y1)
+			considerScreen(x, y, SnapNearest)
+		}
+	}
+
+	lines := e.Doc.Lines
+	if len(e.Line.Points) > 0 {
+		lines = append(lines[:len(lines):len(lines)], e.Line)
+	}
+	for _, line := range lines {
+		if !e.Doc.Visible(line.Layer) {
+			continue
+		}
+		for i, point := range line.Points {
+			if i == 0 || i == len(line.Points)-1 {
+				considerPoint(point.Lat, point.Lon, SnapEndpoint)
+			} else {
+				considerPoint(point.Lat, point.Lon, SnapVertex)
+			}
+			if i > 0 {
+				previous := line.Points[i-1]
+				considerSegment(previous.Lat, previous.Lon, point.Lat, point.Lon)
+			}
+		}
+	}
+
+	for _, point := range e.Doc.Points {
+		if !e.Doc.Visible(point.Layer) {
+			continue
+		}
+		considerPoint(point.Lat, point.Lon, SnapEndpoint)
+	}
+
+	polygons := e.Doc.Polygons
+	if len(e.PolygonObject.Points) > 0 {
+		polygons = append(polygons[:len(polygons):len(polygons)], e.PolygonObject)
+	}
+	for index, polygon := range polygons {
+		closed := index < len(e.Doc.Polygons) // The polygon being drawn is still open
+		if !e.Doc.Visible(polygon.Layer) {
+			continue
+		}
+		for i, point := range polygon.Points {
+			considerPoint(point.Lat, point.Lon, SnapVertex)
+			if i > 0 {
+				previous := polygon.Points[i-1]
+				considerSegment(previous.Lat, previous.Lon, point.Lat, point.Lon)
+			}
+		}
+		if closed && len(polygon.Points) > 2 {
+			first, last := polygon.Points[0], polygon.Points[len(polygon.Points)-1]
+			considerSegment(last.Lat, last.Lon, first.Lat, first.Lon)
+		}
+	}
+
+	if modes&SnapIntersection != 0 {
+		for i := 0; i < len(nearby); i++ {
+			for j := i + 1; j < len(nearby); j++ {
+				if x, y, ok := segmentIntersection(nearby[i], nearby[j]); ok {
+					considerScreen(x, y, SnapIntersection)
+				}
+			}
+		}
+	}
+
+	return best, found
+}
+
+// segmentIntersection returns the point where two segments cross
+func segmentIntersection(a, b snapSegment) (float64, float64, bool) {
+	dxA, dyA := a.x1-a.x0, a.y1-a.y0
+	dxB, dyB := b.x1-b.x0, b.y1-b.y0
+	denominator := dxA*dyB - dyA*dxB
+	if denominator == 0 {
+		return 0, 0, false // Parallel
+	}
+
+	t := ((b.x0-a.x0)*dyB - (b.y0-a.y0)*dxB) / denominator
+	u := ((b.x0-a.x0)*dyA - (b.y0-a.y0)*dxA) / denominator
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, 0, false
+	}
+	return a.x0 + t*dxA, a.y0 + t*dyA, true
+}
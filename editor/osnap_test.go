@@ -0,0 +1,180 @@
+package editor
+
+import (
+	"io"
+	"math"
+	"testing"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+var snapView = model.Viewport{CenterLat: 35.15, CenterLon: -90.05, Zoom: 16, Width: 800, Height: 600}
+
+// newSnapEditor returns an editor with features laid out in screen pixels of
+// snapView:
+//
+//	a line from 100,100 through a vertex at 300,100 to 600,100
+//	a line from 400,40 down to 400,200, crossing the first at 400,100
+//	a point at 700,300
+//	a triangle 100,400 200,400 100,500 on its own layer
+func newSnapEditor() *Editor {
+	e := New()
+	e.Stdout, e.Stderr = io.Discard, io.Discard
+	e.View = snapView
+
+	linePoints := func(screen ...[2]float64) []model.LinePoint {
+		var points []model.LinePoint
+		for _, xy := range screen {
+			lat, lon := snapView.ScreenToLatLng(xy[0], xy[1])
+			points = append(points, model.LinePoint{Lat: lat, Lon: lon})
+		}
+		return points
+	}
+	e.Doc.AddLine(model.PolyLine{Points: linePoints([2]float64{100, 100}, [2]float64{300, 100}, [2]float64{600, 100})})
+	e.Doc.AddLine(model.PolyLine{Points: linePoints([2]float64{400, 40}, [2]float64{400, 200})})
+
+	lat, lon := snapView.ScreenToLatLng(700, 300)
+	e.Doc.AddPoint(model.PointObject{Lat: lat, Lon: lon})
+
+	polygon := model.PolygonObject{Layer: e.Doc.Layer("Parcels")}
+	for _, point := range linePoints([2]float64{100, 400}, [2]float64{200, 400}, [2]float64{100, 500}) {
+		polygon.Points = append(polygon.Points, model.PolyPoint{Lat: point.Lat, Lon: point.Lon})
+	}
+	e.Doc.AddPolygon(polygon)
+	return e
+}
+
+func TestFindSnap(t *testing.T) {
+	tests := []struct {
+		name           string
+		modes          SnapMode
+		mouseX, mouseY float64
+		wantMode       SnapMode // 0 for no snap
+		wantX, wantY   float64
+	}{
+		{"endpoint", SnapAll, 103, 98, SnapEndpoint, 100, 100},
+		{"vertex", SnapAll, 302, 103, SnapVertex, 300, 100},
+		{"midpoint", SnapAll, 204, 97, SnapMidpoint, 200, 100},
+		{"intersection", SnapAll, 397, 104, SnapIntersection, 400, 100},
+		{"nearest", SnapAll, 250, 106, SnapNearest, 250, 100},
+		{"vertex beats a nearer nearest", SnapAll, 305, 104, SnapVertex, 300, 100},
+		{"point object", SnapAll, 695, 305, SnapEndpoint, 700, 300},
+		{"polygon vertex", SnapAll, 198, 396, SnapVertex, 200, 400},
+		{"polygon closing edge", SnapAll, 153, 446, SnapMidpoint, 150, 450},
+		{"only nearest", SnapNearest, 103, 98, SnapNearest, 103, 100},
+		{"only midpoint", SnapMidpoint, 302, 103, 0, 0, 0},
+		{"intersection off", SnapAll &^ SnapIntersection, 397, 104, SnapNearest, 400, 104},
+		{"out of range", SnapAll, 250, 300, 0, 0, 0},
+		{"no modes", 0, 103, 98, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		e := newSnapEditor()
+		e.OsnapModes = test.modes
+		snap, found := e.findSnap(snapView, test.mouseX, test.mouseY)
+		if found != (test.wantMode != 0) {
+			t.Errorf("%s: found %v %+v, want mode %s", test.name, found, snap, test.wantMode)
+			continue
+		}
+		if !found {
+			continue
+		}
+		x, y := snapView.LatLngToScreen64(snap.Lat, snap.Lon)
+		if snap.Mode != test.wantMode || math.Abs(x-test.wantX) > 1e-6 || math.Abs(y-test.wantY) > 1e-6 {
+			t.Errorf("%s: snapped %s to %.3f, %.3f, want %s to %g, %g", test.name, snap.Mode, x, y, test.wantMode, test.wantX, test.wantY)
+		}
+	}
+}
+
+func TestFindSnapHiddenLayer(t *testing.T) {
+	e := newSnapEditor()
+	if err := e.Doc.SetLayerVisible("Parcels", false); err != nil {
+		t.Fatal(err)
+	}
+	if snap, found := e.findSnap(snapView, 198, 396); found {
+		t.Errorf("snapped to %+v on a hidden layer", snap)
+	}
+}
+
+func TestCursorLatLngSnapsWhileDrawing(t *testing.T) {
+	e := newSnapEditor()
+
+	// Not drawing, the cursor isn't snapped
+	lat, lon := e.CursorLatLng(snapView, 103, 98)
+	if x, y := snapView.LatLngToScreen64(lat, lon); e.Snapped || math.Abs(x-103) > 1e-6 || math.Abs(y-98) > 1e-6 {
+		t.Errorf("cursor moved to %.3f, %.3f while not drawing", x, y)
+	}
+
+	e.PL_activated = true
+	lat, lon = e.CursorLatLng(snapView, 103, 98)
+	if x, y := snapView.LatLngToScreen64(lat, lon); !e.Snapped || math.Abs(x-100) > 1e-6 || math.Abs(y-100) > 1e-6 {
+		t.Errorf("cursor at %.3f, %.3f while drawing, want the endpoint at 100, 100", x, y)
+	}
+
+	e.OsnapEnabled = false
+	lat, lon = e.CursorLatLng(snapView, 103, 98)
+	if x, _ := snapView.LatLngToScreen64(lat, lon); e.Snapped || math.Abs(x-103) > 1e-6 {
+		t.Errorf("cursor snapped with OSNAP off")
+	}
+}
+
+func TestParseSnapMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want SnapMode
+	}{
+		{"END", SnapEndpoint},
+		{"ver", SnapVertex},
+		{" Mid ", SnapMidpoint},
+		{"INT", SnapIntersection},
+		{"nea", SnapNearest},
+		{"all", SnapAll},
+		{"NONE", 0},
+	}
+	for _, test := range tests {
+		if mode, err := ParseSnapMode(test.s); err != nil || mode != test.want {
+			t.Errorf("ParseSnapMode(%q) = %s, %v, want %s", test.s, mode, err, test.want)
+		}
+	}
+	for _, s := range []string{"", "ENDPOINT", "perp"} {
+		if _, err := ParseSnapMode(s); err == nil {
+			t.Errorf("ParseSnapMode(%q) succeeded, want an error", s)
+		}
+	}
+
+	if s := (SnapEndpoint | SnapMidpoint).String(); s != "END MID" {
+		t.Errorf("END|MID is %q", s)
+	}
+	if s := SnapMode(0).String(); s != "NONE" {
+		t.Errorf("no modes is %q", s)
+	}
+}
+
+func TestToggleOsnap(t *testing.T) {
+	e := New()
+	e.Stdout = io.Discard
+
+	steps := []struct {
+		arg  string
+		want string
+	}{
+		{"", "OFF"},
+		{"", "END VER MID INT NEA"},
+		{"MID", "END VER INT NEA"},
+		{"NONE", "NONE"},
+		{"END", "END"},
+		{"INT", "END INT"},
+		{"ALL", "END VER MID INT NEA"},
+	}
+	for _, step := range steps {
+		if err := e.ToggleOsnap(step.arg); err != nil {
+			t.Fatalf("OSNAP %s: %v", step.arg, err)
+		}
+		if status := e.OsnapStatus(); status != step.want {
+			t.Errorf("OSNAP %s: status %q, want %q", step.arg, status, step.want)
+		}
+	}
+	if err := e.ToggleOsnap("perp"); err == nil {
+		t.Error("OSNAP perp succeeded, want an error")
+	}
+}
@@ -0,0 +1,127 @@
+package editor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const maxScriptDepth = 8 // Scripts may run other scripts, but not forever
+
+// RunScript executes a script file one line at a time as if each line was
+// typed in the command box. Blank lines act as pressing return on an empty
+// command line and lines starting with ; are comments. The script stops at
+// the first error. A script run from another script is found relative to the
+// folder of the script running it.
+func (e *Editor) RunScript(filename string) error {
+	if e.scriptDepth >= maxScriptDepth {
+		return fmt.Errorf("%s: scripts nested too deeply", filename)
+	}
+	if e.scriptDepth > 0 && !filepath.IsAbs(filename) {
+		filename = filepath.Join(e.scriptDir, filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parentDir := e.scriptDir
+	e.scriptDepth++
+	e.scriptDir = filepath.Dir(filename)
+	defer func() {
+		e.scriptDepth--
+		e.scriptDir = parentDir
+	}()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, ";") {
+			continue
+		}
+		if err := e.RunCommandLine(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	e.PrintMessage("Ran %s (%d lines)", filename, lineNumber)
+	return nil
+}
+
+// startRecording writes every command line and clicked point from now on to
+// a script file that SCRIPT can replay
+func (e *Editor) startRecording(filename string) error {
+	if e.recordFile != nil {
+		if err := e.stopRecording(); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(file, "; FiberForge script recorded %s\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	// Start from the same view settings when replayed
+	if e.DisplayCRS != nil {
+		fmt.Fprintf(file, "CRS %d\n", e.DisplayCRS.Code)
+	} else {
+		fmt.Fprintln(file, "CRS")
+	}
+	fmt.Fprintf(file, "UNITS %s\n", strings.ToUpper(e.Units.String()))
+
+	e.recordFile = file
+	e.PrintMessage("Recording to %s", filename)
+	return nil
+}
+
+func (e *Editor) stopRecording() error {
+	if e.recordFile == nil {
+		return fmt.Errorf("not recording")
+	}
+	filename := e.recordFile.Name()
+	err := e.recordFile.Close()
+	e.recordFile = nil
+	if err != nil {
+		return err
+	}
+	e.PrintMessage("Recorded %s", filename)
+	return nil
+}
+
+// recordCommandLine adds a command line to the recording. Lines run by
+// scripts are left out since the SCRIPT command itself is recorded, as is
+// RECORD so that replaying does not record over the file.
+func (e *Editor) recordCommandLine(line string) {
+	if e.recordFile == nil || e.scriptDepth > 0 {
+		return
+	}
+	if fields := strings.Fields(line); len(fields) > 0 && strings.EqualFold(fields[0], "RECORD") {
+		return
+	}
+	if _, err := fmt.Fprintln(e.recordFile, line); err != nil {
+		e.PrintError(err)
+	}
+}
+
+// RecordPoint adds a clicked point to the recording as typed coordinates in
+// the display CRS, which is how SCRIPT will read them back
+func (e *Editor) RecordPoint(lat, lon float64) {
+	if e.DisplayCRS != nil {
+		x, y := e.DisplayCRS.FromLatLng(lat, lon)
+		e.recordCommandLine(fmt.Sprintf("%.4f,%.4f", x, y))
+	} else {
+		e.recordCommandLine(fmt.Sprintf("%.8f,%.8f", lat, lon))
+	}
+}
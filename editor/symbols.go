@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SymbolType is a kind of telecom asset placed with PO <type>, drawn with a
+// symbol built into the program. Attributes lists the attributes every point
+// of the type carries, with their default values.
+type SymbolType struct {
+	Name       string
+	Aliases    []string
+	Label      string
+	Attributes []SymbolAttribute
+}
+
+type SymbolAttribute struct {
+	Key     string
+	Default string
+}
+
+var SymbolTypes = []SymbolType{
+	{Name: "POLE", Label: "Pole", Attributes: []SymbolAttribute{{"owner", ""}, {"number", ""}, {"height", "40"}, {"class", "4"}}},
+	{Name: "HANDHOLE", Aliases: []string{"HH"}, Label: "Handhole", Attributes: []SymbolAttribute{{"size", "17x30x24"}, {"tier", "15"}, {"lid", "FIBER"}}},
+	{Name: "VAULT", Label: "Vault", Attributes: []SymbolAttribute{{"size", "48x48x36"}, {"material", "concrete"}, {"tier", "22"}}},
+	{Name: "PEDESTAL", Aliases: []string{"PED"}, Label: "Pedestal", Attributes: []SymbolAttribute{{"size", "10x10x30"}, {"owner", ""}}},
+	{Name: "SPLICE", Aliases: []string{"SC", "SPLICECLOSURE"}, Label: "Splice closure", Attributes: []SymbolAttribute{{"fibers", "144"}, {"splices", "0"}, {"enclosure", ""}}},
+	{Name: "MST", Label: "Multiport service terminal", Attributes: []SymbolAttribute{{"ports", "8"}, {"fibers", "12"}, {"tail", "100"}}},
+	{Name: "CABINET", Aliases: []string{"CAB"}, Label: "Cabinet", Attributes: []SymbolAttribute{{"size", ""}, {"power", "none"}, {"ports", "288"}}},
+	{Name: "BUILDING", Aliases: []string{"BLDG"}, Label: "Building", Attributes: []SymbolAttribute{{"address", ""}, {"units", "1"}}},
+}
+
+// lookupSymbolType finds a symbol type by name or alias, ignoring case
+func lookupSymbolType(name string) *SymbolType {
+	for i := range SymbolTypes {
+		symbol := &SymbolTypes[i]
+		if strings.EqualFold(symbol.Name, name) {
+			return symbol
+		}
+		for _, alias := range symbol.Aliases {
+			if strings.EqualFold(alias, name) {
+				return symbol
+			}
+		}
+	}
+	return nil
+}
+
+// symbolTypeNames lists the symbol type names for help and errors
+func symbolTypeNames() string {
+	names := make([]string, len(SymbolTypes))
+	for i, symbol := range SymbolTypes {
+		names[i] = symbol.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseAttributes reads key=value arguments into the type's attributes,
+// starting from the defaults
+func (s *SymbolType) ParseAttributes(args []string) (map[string]string, error) {
+	attributes := make(map[string]string, len(s.Attributes))
+	for _, attribute := range s.Attributes {
+		attributes[attribute.Key] = attribute.Default
+	}
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("attribute %q is not key=value", arg)
+		}
+		key = strings.ToLower(key)
+		if _, known := attributes[key]; !known {
+			return nil, fmt.Errorf("%s has no attribute %q, it has %s", s.Name, key, s.attributeKeys())
+		}
+		attributes[key] = value
+	}
+	return attributes, nil
+}
+
+func (s *SymbolType) attributeKeys() string {
+	keys := make([]string, len(s.Attributes))
+	for i, attribute := range s.Attributes {
+		keys[i] = attribute.Key
+	}
+	return strings.Join(keys, ", ")
+}
+
+// FormatAttributes writes attributes as key=value in a stable order
+func FormatAttributes(attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + attributes[key]
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,583 @@
+package gps
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/adrianmo/go-nmea"
+	"github.com/tarm/serial"
+)
+
+const (
+	DefaultGPSBaud         = 9600
+	DefaultGPSDPort        = 2947
+	knotsPerMeterPerSecond = 1.943844
+	GPSStaleAfter          = 5 * time.Second // A fix older than this is shown as stale
+)
+
+// Parts of the device names USB serial adapters and USB receivers get on
+// Linux and macOS
+var usbSerialNames = []string{"usbserial", "usbmodem", "ttyUSB", "ttyACM"}
+
+// GPSSource is where GPS positions are read from, a serial port, NMEA or
+// gpsd JSON over the network, or a recorded NMEA log
+type GPSSource struct {
+	Kind    string // serial, tcp, udp, gpsd or replay
+	Address string // Port name, host:port or log file
+	Baud    int
+	Speed   float64 // Replay speed, 1 for as recorded
+}
+
+func (s GPSSource) String() string {
+	switch s.Kind {
+	case "serial":
+		return fmt.Sprintf("%s at %d baud", s.Address, s.Baud)
+	case "replay":
+		return fmt.Sprintf("replay://%s at %gx", s.Address, s.Speed)
+	}
+	return s.Kind + "://" + s.Address
+}
+
+// GPSFix is a snapshot of what the receiver last reported
+type GPSFix struct {
+	Latitude         float64
+	Longitude        float64
+	Altitude         float64
+	Speed            float64 // Knots
+	Course           float64
+	HDOP             float64
+	VDOP             float64
+	PDOP             float64
+	Quality          string    // GGA fix quality, 0 invalid, 1 GPS, 2 DGPS, 4 RTK fixed, 5 RTK float...
+	FixType          string    // GSA fix type, 1 none, 2 2D, 3 3D
+	SatellitesUsed   int       // Satellites used in the fix
+	SatellitesInView int       // Satellites in view, all constellations
+	Time             time.Time // UTC time of the fix from the receiver
+	Received         time.Time // Local time the position was last updated, zero before the first fix
+
+	// Error estimates from GST, one standard deviation in meters
+	SemiMajor   float64
+	SemiMinor   float64
+	Orientation float64 // Semi-major axis, degrees clockwise from true north
+	AltError    float64
+	ErrorTime   time.Time // Local time of the last GST, zero if none
+}
+
+// HasFix reports whether a position has been received
+func (f GPSFix) HasFix() bool {
+	return !f.Received.IsZero()
+}
+
+// Stale reports whether the position has not been updated for a while, e.g.
+// the receiver lost its fix or the connection dropped
+func (f GPSFix) Stale() bool {
+	return f.HasFix() && time.Since(f.Received) > GPSStaleAfter
+}
+
+// userRangeError is a rough one sigma error in meters per unit of HDOP for
+// each fix quality, for receivers that do not send GST
+var userRangeError = map[string]float64{
+	nmea.GPS:  3.0,
+	nmea.DGPS: 1.0,
+	nmea.PPS:  1.0,
+	nmea.RTK:  0.01,
+	nmea.FRTK: 0.2,
+	nmea.EST:  10.0,
+}
+
+// HasErrorEllipse reports whether the receiver sent a GST error ellipse
+// recently enough to go with the position
+func (f GPSFix) HasErrorEllipse() bool {
+	return !f.ErrorTime.IsZero() && f.Received.Sub(f.ErrorTime) < GPSStaleAfter && f.SemiMajor > 0 && f.SemiMinor > 0
+}
+
+// ErrorEllipse returns the semi-major and semi-minor axes in meters and the
+// orientation of the semi-major axis. Without GST the error is estimated from
+// the HDOP and fix quality as a circle.
+func (f GPSFix) ErrorEllipse() (semiMajor, semiMinor, orientation float64) {
+	if f.HasErrorEllipse() {
+		return f.SemiMajor, f.SemiMinor, f.Orientation
+	}
+	radius := f.HorizontalAccuracy()
+	return radius, radius, 0
+}
+
+// HorizontalAccuracy returns the one sigma horizontal error in meters, the
+// root sum square of the GST ellipse axes or else an estimate from the HDOP
+func (f GPSFix) HorizontalAccuracy() float64 {
+	if f.HasErrorEllipse() {
+		return math.Hypot(f.SemiMajor, f.SemiMinor)
+	}
+	rangeError, ok := userRangeError[f.Quality]
+	if !ok {
+		rangeError = userRangeError[nmea.GPS]
+	}
+	hdop := f.HDOP
+	if hdop <= 0 {
+		hdop = 1
+	}
+	return hdop * rangeError
+}
+
+// QualityName describes the GGA fix quality
+func (f GPSFix) QualityName() string {
+	return model.FixQualityName(f.Quality)
+}
+
+// FixTypeName describes the GSA fix type
+func (f GPSFix) FixTypeName() string {
+	switch f.FixType {
+	case nmea.FixNone:
+		return "No fix"
+	case nmea.Fix2D:
+		return "2D"
+	case nmea.Fix3D:
+		return "3D"
+	}
+	return ""
+}
+
+type GPS struct {
+	done    chan struct{}
+	wg      sync.WaitGroup
+	reader  io.ReadCloser
+	replay  *NMEAReplay // Set when replaying a log, for speed and pause
+	source  GPSSource
+	logMu   sync.Mutex
+	logFile *os.File // NMEA log being saved, see StartNMEALog
+
+	// The reader goroutine updates the fix while Draw reads it, so both go
+	// through mu
+	mu         sync.Mutex
+	running    bool
+	err        error // Why the source stopped on its own, e.g. the connection dropped
+	fix        GPSFix
+	date       nmea.Date        // Last date from RMC, GGA only has the time
+	satsInView map[string]int64 // Satellites in view by talker, GP, GL, GA...
+}
+
+// Fix returns a copy of the latest fix
+func (gps *GPS) Fix() GPSFix {
+	gps.mu.Lock()
+	defer gps.mu.Unlock()
+	return gps.fix
+}
+
+func NewGPS() *GPS {
+	return &GPS{running: false}
+}
+
+// Running reports whether positions are being read. It turns false by itself
+// when the source disconnects, see Err.
+func (gps *GPS) Running() bool {
+	gps.mu.Lock()
+	defer gps.mu.Unlock()
+	return gps.running
+}
+
+// Err returns why the source stopped without STOPGPS, nil while running or
+// after STOPGPS
+func (gps *GPS) Err() error {
+	gps.mu.Lock()
+	defer gps.mu.Unlock()
+	return gps.err
+}
+
+// Source returns where positions are being read from, or were last read from
+func (gps *GPS) Source() GPSSource {
+	return gps.source
+}
+
+// Replay returns the NMEA log being replayed, nil for other sources
+func (gps *GPS) Replay() *NMEAReplay {
+	if !gps.Running() {
+		return nil
+	}
+	return gps.replay
+}
+
+// ParseGPSSource parses the arguments of STARTGPS, either a serial port and
+// optional baud rate, tcp://host:port or udp://:port for raw NMEA,
+// gpsd://host[:port], or replay://file and an optional speed. With no
+// arguments the USB serial port found is used, or the only port there is.
+func ParseGPSSource(args []string) (GPSSource, error) {
+	if len(args) == 0 {
+		ports := SerialPorts()
+		if len(ports) == 0 {
+			return GPSSource{}, fmt.Errorf("no serial ports found, use STARTGPS <port> [baud], tcp://host:port, udp://:port or gpsd://host")
+		}
+		// SerialPorts lists USB ports first, anything else may be a modem or
+		// Bluetooth port, so with several to choose from the port must be given
+		if len(ports) > 1 && !isUSBSerialPort(ports[0]) {
+			return GPSSource{}, fmt.Errorf("several serial ports found, use STARTGPS <port> [baud] with one of %s", strings.Join(ports, ", "))
+		}
+		return GPSSource{Kind: "serial", Address: ports[0], Baud: DefaultGPSBaud}, nil
+	}
+
+	if kind, address, ok := strings.Cut(args[0], "://"); ok {
+		kind = strings.ToLower(kind)
+		if kind == "replay" {
+			return parseReplaySource(address, args[1:])
+		}
+		switch kind {
+		case "tcp", "udp":
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return GPSSource{}, fmt.Errorf("%s: %v", args[0], err)
+			}
+		case "gpsd":
+			if address == "" {
+				address = "localhost"
+			}
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				address = net.JoinHostPort(address, strconv.Itoa(DefaultGPSDPort))
+			}
+		default:
+			return GPSSource{}, fmt.Errorf("unknown GPS source %q, use tcp://, udp://, gpsd:// or replay://", kind)
+		}
+		return GPSSource{Kind: kind, Address: address}, nil
+	}
+	if strings.EqualFold(args[0], "gpsd") {
+		return GPSSource{Kind: "gpsd", Address: net.JoinHostPort("localhost", strconv.Itoa(DefaultGPSDPort))}, nil
+	}
+
+	source := GPSSource{Kind: "serial", Address: args[0], Baud: DefaultGPSBaud}
+	if len(args) > 1 {
+		baud, err := strconv.Atoi(args[1])
+		if err != nil || baud <= 0 {
+			return GPSSource{}, fmt.Errorf("invalid baud rate %q", args[1])
+		}
+		source.Baud = baud
+	}
+	return source, nil
+}
+
+// open connects to the source. gpsd is asked to start streaming JSON reports.
+func (s GPSSource) open() (io.ReadCloser, error) {
+	switch s.Kind {
+	case "tcp":
+		return net.Dial("tcp", s.Address)
+	case "udp":
+		address, err := net.ResolveUDPAddr("udp", s.Address)
+		if err != nil {
+			return nil, err
+		}
+		return net.ListenUDP("udp", address)
+	case "gpsd":
+		conn, err := net.Dial("tcp", s.Address)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(conn, `?WATCH={"enable":true,"json":true};`+"\n"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	case "replay":
+		return openNMEAReplay(s.Address, s.Speed)
+	default:
+		return serial.OpenPort(&serial.Config{Name: s.Address, Baud: s.Baud})
+	}
+}
+
+func (gps *GPS) StartGPS(source GPSSource) error {
+	reader, err := source.open()
+	if err != nil {
+		return err
+	}
+
+	// Assign the opened source to the GPS struct
+	gps.reader = reader
+	gps.replay, _ = reader.(*NMEAReplay)
+	gps.source = source
+
+	// Start from no fix rather than where the last source left off
+	gps.mu.Lock()
+	gps.fix = GPSFix{}
+	gps.date = nmea.Date{}
+	gps.satsInView = make(map[string]int64)
+	gps.running = true
+	gps.err = nil
+	gps.mu.Unlock()
+
+	// Read one NMEA sentence or gpsd report per line
+	scanner := bufio.NewScanner(reader)
+
+	// Create a new 'done' channel
+	gps.done = make(chan struct{})
+
+	gps.wg.Add(1)
+	go func() {
+		defer gps.wg.Done()
+
+		for scanner.Scan() {
+			select {
+			case <-gps.done:
+				return
+			default:
+			}
+
+			line := strings.TrimSpace(scanner.Text())
+			gps.logNMEA(line)
+			if source.Kind == "gpsd" {
+				gps.handleGPSDReport(line)
+			} else {
+				gps.handleNMEA(line)
+			}
+		}
+
+		select {
+		case <-gps.done:
+			return // Stopped with StopGPS
+		default:
+		}
+
+		// The source ended by itself, the connection dropped, the receiver was
+		// unplugged or the log ran out
+		err := scanner.Err()
+		if err == nil && source.Kind == "replay" {
+			err = errors.New("end of the NMEA log")
+		} else if err == nil {
+			err = errors.New("connection closed")
+		}
+		reader.Close()
+
+		gps.mu.Lock()
+		gps.running = false
+		gps.err = err
+		gps.mu.Unlock()
+	}()
+
+	return nil
+}
+
+// handleNMEA updates the fix from one NMEA sentence
+func (gps *GPS) handleNMEA(line string) {
+	// Parse NMEA sentence
+	sentence, err := nmea.Parse(line)
+	if err != nil {
+		return // Ignore invalid sentences
+	}
+
+	gps.mu.Lock()
+	defer gps.mu.Unlock()
+
+	switch s := sentence.(type) {
+	case nmea.GGA:
+		gps.fix.Quality = s.FixQuality
+		gps.fix.SatellitesUsed = int(s.NumSatellites)
+		if s.FixQuality == nmea.Invalid {
+			return
+		}
+		gps.fix.Latitude = s.Latitude
+		gps.fix.Longitude = s.Longitude
+		gps.fix.Altitude = s.Altitude
+		gps.fix.HDOP = s.HDOP
+		gps.updated(s.Time)
+	case nmea.RMC:
+		if s.Date.Valid {
+			gps.date = s.Date
+		}
+		if s.Validity != nmea.ValidRMC {
+			return
+		}
+		gps.fix.Latitude = s.Latitude
+		gps.fix.Longitude = s.Longitude
+		gps.fix.Speed = s.Speed
+		gps.fix.Course = s.Course
+		gps.updated(s.Time)
+	case nmea.GLL:
+		if s.Validity != nmea.ValidGLL {
+			return
+		}
+		gps.fix.Latitude = s.Latitude
+		gps.fix.Longitude = s.Longitude
+		gps.updated(s.Time)
+	case nmea.GSA:
+		gps.fix.FixType = s.FixType
+		gps.fix.PDOP = s.PDOP
+		gps.fix.HDOP = s.HDOP
+		gps.fix.VDOP = s.VDOP
+	case GST:
+		gps.fix.SemiMajor = s.SemiMajor
+		gps.fix.SemiMinor = s.SemiMinor
+		gps.fix.Orientation = s.Orientation
+		gps.fix.AltError = s.AltError
+		gps.fix.ErrorTime = time.Now()
+	case nmea.GSV:
+		gps.satsInView[s.Talker] = s.NumberSVsInView
+		total := int64(0)
+		for _, n := range gps.satsInView {
+			total += n
+		}
+		gps.fix.SatellitesInView = int(total)
+	}
+}
+
+// updated records that the position changed, at the receiver's time t.
+// Called with mu held.
+func (gps *GPS) updated(t nmea.Time) {
+	gps.fix.Received = time.Now()
+	if !t.Valid {
+		return
+	}
+
+	year, month, day := gps.fix.Received.UTC().Date()
+	if gps.date.Valid {
+		year, month, day = 2000+gps.date.YY, time.Month(gps.date.MM), gps.date.DD
+	}
+	gps.fix.Time = time.Date(year, month, day, t.Hour, t.Minute, t.Second, t.Millisecond*int(time.Millisecond), time.UTC)
+}
+
+// gpsdReport holds the fields used from gpsd TPV (position), SKY
+// (satellites) and GST (error ellipse) reports
+type gpsdReport struct {
+	Class      string  `json:"class"`
+	Mode       int     `json:"mode"`
+	Status     int     `json:"status"`
+	Time       string  `json:"time"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	Alt        float64 `json:"alt"`
+	Speed      float64 `json:"speed"` // Meters per second
+	Track      float64 `json:"track"`
+	HDOP       float64 `json:"hdop"`
+	VDOP       float64 `json:"vdop"`
+	PDOP       float64 `json:"pdop"`
+	Major      float64 `json:"major"` // GST error ellipse
+	Minor      float64 `json:"minor"`
+	Orient     float64 `json:"orient"`
+	Satellites []struct {
+		Used bool `json:"used"`
+	} `json:"satellites"`
+}
+
+// gpsdQuality maps the gpsd TPV status to a GGA fix quality
+var gpsdQuality = map[int]string{
+	1: nmea.GPS,
+	2: nmea.DGPS,
+	3: nmea.RTK,
+	4: nmea.FRTK,
+	5: nmea.EST,
+	6: "7",
+	7: "8",
+}
+
+// handleGPSDReport updates the fix from one gpsd JSON report
+func (gps *GPS) handleGPSDReport(line string) {
+	var report gpsdReport
+	if err := json.Unmarshal([]byte(line), &report); err != nil {
+		return
+	}
+
+	gps.mu.Lock()
+	defer gps.mu.Unlock()
+
+	switch report.Class {
+	case "TPV":
+		gps.fix.FixType = strconv.Itoa(report.Mode)
+		if report.Mode < 2 {
+			gps.fix.Quality = nmea.Invalid
+			return // No fix yet
+		}
+		gps.fix.Quality = nmea.GPS
+		if quality, ok := gpsdQuality[report.Status]; ok {
+			gps.fix.Quality = quality
+		}
+		gps.fix.Latitude = report.Lat
+		gps.fix.Longitude = report.Lon
+		gps.fix.Altitude = report.Alt
+		gps.fix.Speed = report.Speed * knotsPerMeterPerSecond
+		gps.fix.Course = report.Track
+		gps.fix.Received = time.Now()
+		if t, err := time.Parse(time.RFC3339Nano, report.Time); err == nil {
+			gps.fix.Time = t.UTC()
+		}
+	case "GST":
+		if report.Major > 0 {
+			gps.fix.SemiMajor = report.Major
+			gps.fix.SemiMinor = report.Minor
+			gps.fix.Orientation = report.Orient
+			gps.fix.AltError = 0
+			gps.fix.ErrorTime = time.Now()
+		}
+	case "SKY":
+		if report.HDOP > 0 {
+			gps.fix.HDOP = report.HDOP
+		}
+		if report.VDOP > 0 {
+			gps.fix.VDOP = report.VDOP
+		}
+		if report.PDOP > 0 {
+			gps.fix.PDOP = report.PDOP
+		}
+		if len(report.Satellites) > 0 {
+			used := 0
+			for _, satellite := range report.Satellites {
+				if satellite.Used {
+					used++
+				}
+			}
+			gps.fix.SatellitesUsed = used
+			gps.fix.SatellitesInView = len(report.Satellites)
+		}
+	}
+}
+
+// StopGPS stops reading positions, or clears the error of a source that
+// stopped by itself
+func (gps *GPS) StopGPS() {
+	if gps.Running() {
+		close(gps.done)
+
+		// Closing the source unblocks the reader goroutine
+		if gps.reader != nil {
+			gps.reader.Close()
+		}
+		gps.wg.Wait() // Wait for the Go routine to exit
+	}
+
+	gps.mu.Lock()
+	gps.running = false
+	gps.err = nil
+	gps.mu.Unlock()
+	gps.replay = nil
+}
+
+// isUSBSerialPort reports whether a port is a USB serial adapter or USB
+// receiver, the likeliest to be a GPS
+func isUSBSerialPort(port string) bool {
+	for _, name := range usbSerialNames {
+		if strings.Contains(port, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// QualityRank orders GGA fix qualities from worst to best
+func QualityRank(quality string) int {
+	switch quality {
+	case nmea.RTK:
+		return 5
+	case nmea.FRTK:
+		return 4
+	case nmea.DGPS, nmea.PPS:
+		return 3
+	case nmea.GPS:
+		return 2
+	case nmea.EST:
+		return 1
+	}
+	return 0
+}
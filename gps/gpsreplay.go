@@ -0,0 +1,227 @@
+package gps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+const (
+	replayStep   = 50 * time.Millisecond // Longest sleep before checking pause, speed and stop
+	replayMaxGap = 10 * time.Second      // Gaps in the log, e.g. the receiver was off, are cut short
+)
+
+// NMEAReplay reads a recorded NMEA log back one line at a time, holding each
+// line until its timestamp comes around so that the GPS reader sees the
+// sentences at the pace they were recorded, or faster or slower.
+type NMEAReplay struct {
+	file    *os.File
+	lines   *bufio.Reader
+	pending []byte // Rest of the current line not yet read
+
+	lastTime time.Duration // Time of day of the last timestamped sentence
+	haveTime bool
+
+	mu     sync.Mutex
+	speed  float64
+	paused bool
+	closed bool
+}
+
+// parseReplaySource parses replay://file [speed]
+func parseReplaySource(filename string, args []string) (GPSSource, error) {
+	if filename == "" {
+		return GPSSource{}, fmt.Errorf("replay:// needs an NMEA log file")
+	}
+	source := GPSSource{Kind: "replay", Address: filename, Speed: 1}
+	if len(args) > 0 {
+		speed, err := ParseReplaySpeed(args[0])
+		if err != nil {
+			return GPSSource{}, err
+		}
+		source.Speed = speed
+	}
+	return source, nil
+}
+
+// ParseReplaySpeed parses a speed factor such as 2, 0.5 or 10x
+func ParseReplaySpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid replay speed %q", s)
+	}
+	return speed, nil
+}
+
+func openNMEAReplay(filename string, speed float64) (*NMEAReplay, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &NMEAReplay{file: file, lines: bufio.NewReader(file), speed: speed}, nil
+}
+
+func (r *NMEAReplay) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		line, err := r.lines.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+		if err := r.waitFor(string(line)); err != nil {
+			return 0, err
+		}
+		r.pending = line
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// waitFor sleeps until a sentence is due, going by the time since the
+// previous timestamped sentence
+func (r *NMEAReplay) waitFor(line string) error {
+	timestamp, ok := sentenceTime(strings.TrimSpace(line))
+	if !ok {
+		return nil
+	}
+	if !r.haveTime {
+		r.lastTime, r.haveTime = timestamp, true
+		return nil
+	}
+
+	delay := timestamp - r.lastTime
+	if delay < 0 {
+		delay += 24 * time.Hour // Past midnight
+	}
+	if delay > replayMaxGap {
+		delay = replayMaxGap
+	}
+	r.lastTime = timestamp
+
+	// Sleep in short steps so that pausing, changing speed and stopping take effect right away
+	for delay > 0 {
+		r.mu.Lock()
+		speed, paused, closed := r.speed, r.paused, r.closed
+		r.mu.Unlock()
+		if closed {
+			return io.EOF
+		}
+
+		step := time.Duration(float64(delay) / speed)
+		if paused || step > replayStep {
+			step = replayStep
+		}
+		time.Sleep(step)
+		if !paused {
+			delay -= time.Duration(float64(step) * speed)
+		}
+	}
+	return nil
+}
+
+// sentenceTime returns the time of day of sentences that carry one
+func sentenceTime(line string) (time.Duration, bool) {
+	sentence, err := nmea.Parse(line)
+	if err != nil {
+		return 0, false
+	}
+
+	var t nmea.Time
+	switch s := sentence.(type) {
+	case nmea.RMC:
+		t = s.Time
+	case nmea.GGA:
+		t = s.Time
+	case nmea.GLL:
+		t = s.Time
+	case nmea.ZDA:
+		t = s.Time
+	default:
+		return 0, false
+	}
+	if !t.Valid {
+		return 0, false
+	}
+	return time.Duration(t.Hour)*time.Hour + time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second + time.Duration(t.Millisecond)*time.Millisecond, true
+}
+
+func (r *NMEAReplay) SetSpeed(speed float64) {
+	r.mu.Lock()
+	r.speed = speed
+	r.mu.Unlock()
+}
+
+// TogglePause pauses or resumes the replay, reporting whether it is now paused
+func (r *NMEAReplay) TogglePause() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = !r.paused
+	return r.paused
+}
+
+// Status describes the replay speed for the GPS panel
+func (r *NMEAReplay) Status() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused {
+		return "Replay paused"
+	}
+	return fmt.Sprintf("Replay at %gx", r.speed)
+}
+
+func (r *NMEAReplay) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	return r.file.Close()
+}
+
+// StartNMEALog saves every NMEA sentence read from now on to a file, which
+// can be replayed with STARTGPS replay://
+func (gps *GPS) StartNMEALog(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	gps.logMu.Lock()
+	defer gps.logMu.Unlock()
+	if gps.logFile != nil {
+		gps.logFile.Close()
+	}
+	gps.logFile = file
+	return nil
+}
+
+func (gps *GPS) StopNMEALog() error {
+	gps.logMu.Lock()
+	defer gps.logMu.Unlock()
+	if gps.logFile == nil {
+		return fmt.Errorf("not logging NMEA")
+	}
+	err := gps.logFile.Close()
+	gps.logFile = nil
+	return err
+}
+
+// logNMEA adds a sentence to the NMEA log, if one is open
+func (gps *GPS) logNMEA(line string) {
+	if !strings.HasPrefix(line, "$") && !strings.HasPrefix(line, "!") {
+		return // Only NMEA sentences, not gpsd JSON
+	}
+
+	gps.logMu.Lock()
+	defer gps.logMu.Unlock()
+	if gps.logFile != nil {
+		fmt.Fprintf(gps.logFile, "%s\r\n", line)
+	}
+}
@@ -0,0 +1,66 @@
+package gps
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentence adds the $ and checksum to the body of an NMEA sentence
+func sentence(body string) string {
+	var checksum byte
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, checksum)
+}
+
+func TestReplay(t *testing.T) {
+	log := []string{
+		sentence("GPGGA,120000.00,3509.36000,N,09003.12000,W,1,08,1.2,80.0,M,-30.0,M,,"),
+		sentence("GPRMC,120000.00,A,3509.36000,N,09003.12000,W,0.0,0.0,191026,,,A"),
+		sentence("GPGGA,120001.00,3509.36432,N,09003.11466,W,4,12,0.8,81.5,M,-30.0,M,,"),
+		sentence("GPRMC,120001.00,A,3509.36432,N,09003.11466,W,2.5,90.0,191026,,,A"),
+	}
+	filename := filepath.Join(t.TempDir(), "drive.nmea")
+	if err := os.WriteFile(filename, []byte(strings.Join(log, "\r\n")+"\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := ParseGPSSource([]string{"replay://" + filename, "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gps := NewGPS()
+	if err := gps.StartGPS(source); err != nil {
+		t.Fatalf("StartGPS: %v", err)
+	}
+	defer gps.StopGPS()
+
+	// The log plays out in a hundredth of its second, then the GPS stops by itself
+	deadline := time.Now().Add(5 * time.Second)
+	for gps.Running() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if gps.Running() {
+		t.Fatal("replay did not reach the end of the log")
+	}
+	if err := gps.Err(); err == nil || !strings.Contains(err.Error(), "end of the NMEA log") {
+		t.Errorf("Err is %v, want the end of the log", err)
+	}
+
+	fix := gps.Fix()
+	if !fix.HasFix() {
+		t.Fatal("no fix after the replay")
+	}
+	if math.Abs(fix.Latitude-35.156072) > 1e-6 || math.Abs(fix.Longitude+90.051911) > 1e-6 {
+		t.Errorf("fix at %f, %f, want the last position in the log", fix.Latitude, fix.Longitude)
+	}
+	if fix.Quality != "4" || fix.SatellitesUsed != 12 || fix.Altitude != 81.5 || fix.Speed != 2.5 {
+		t.Errorf("fix quality %s, %d satellites, altitude %g, speed %g", fix.Quality, fix.SatellitesUsed, fix.Altitude, fix.Speed)
+	}
+}
@@ -0,0 +1,40 @@
+package gps
+
+import (
+	"github.com/adrianmo/go-nmea"
+)
+
+// TypeGST is the GNSS pseudorange error statistics sentence, which go-nmea
+// does not parse itself
+const TypeGST = "GST"
+
+// GST holds the position error estimates survey and RTK receivers report,
+// as one standard deviation in meters
+type GST struct {
+	nmea.BaseSentence
+	Time        nmea.Time
+	RMS         float64 // RMS of the pseudorange residuals
+	SemiMajor   float64 // Error ellipse semi-major axis
+	SemiMinor   float64 // Error ellipse semi-minor axis
+	Orientation float64 // Semi-major axis, degrees clockwise from true north
+	LatError    float64
+	LonError    float64
+	AltError    float64
+}
+
+func init() {
+	nmea.MustRegisterParser(TypeGST, func(s nmea.BaseSentence) (nmea.Sentence, error) {
+		p := nmea.NewParser(s)
+		return GST{
+			BaseSentence: s,
+			Time:         p.Time(0, "time"),
+			RMS:          p.Float64(1, "rms"),
+			SemiMajor:    p.Float64(2, "semi-major"),
+			SemiMinor:    p.Float64(3, "semi-minor"),
+			Orientation:  p.Float64(4, "orientation"),
+			LatError:     p.Float64(5, "latitude error"),
+			LonError:     p.Float64(6, "longitude error"),
+			AltError:     p.Float64(7, "altitude error"),
+		}, p.Err()
+	})
+}
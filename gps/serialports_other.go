@@ -0,0 +1,34 @@
+//go:build !windows
+
+package gps
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Device names USB and Bluetooth GPS receivers show up as on Linux and macOS
+var serialPortPatterns = []string{
+	"/dev/ttyUSB*",
+	"/dev/ttyACM*",
+	"/dev/rfcomm*",
+	"/dev/cu.*",
+}
+
+// SerialPorts lists the serial devices that could be a GPS receiver, USB ones
+// first so that built in ports such as /dev/cu.Bluetooth-Incoming-Port on a
+// Mac come after /dev/cu.usbserial-*
+func SerialPorts() []string {
+	var ports []string
+	for _, pattern := range serialPortPatterns {
+		matches, _ := filepath.Glob(pattern)
+		ports = append(ports, matches...)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if usbI, usbJ := isUSBSerialPort(ports[i]), isUSBSerialPort(ports[j]); usbI != usbJ {
+			return usbI
+		}
+		return ports[i] < ports[j]
+	})
+	return ports
+}
@@ -0,0 +1,30 @@
+package gps
+
+import (
+	"sort"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// SerialPorts lists the COM ports Windows knows about
+func SerialPorts() []string {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(0)
+	if err != nil {
+		return nil
+	}
+
+	var ports []string
+	for _, name := range names {
+		if port, _, err := key.GetStringValue(name); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
@@ -0,0 +1,232 @@
+package gps
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+const (
+	DefaultTrackDistance = 2.0             // Meters between track points
+	DefaultTrackInterval = 1 * time.Second // Time between track points
+)
+
+// Track records GPS fixes as a breadcrumb line. A fix is only added once the
+// receiver has moved MinDistance meters and MinInterval has passed since the
+// last point, so standing still does not pile up points. Resuming after a
+// pause starts a new segment, so the paused stretch is not joined up.
+type Track struct {
+	Line          model.PolyLine
+	MinDistance   float64 // Meters
+	MinInterval   time.Duration
+	Started       time.Time
+	segmentStarts []int // Index of the first point of each segment after the first
+	paused        bool
+	resumed       bool // The next point starts a new segment
+	stopped       bool
+	lastFix       time.Time // Received time of the last fix looked at
+	savedPoints   int       // Points when last exported or added to the map
+}
+
+func NewTrack(minDistance float64, minInterval time.Duration) *Track {
+	return &Track{
+		Line:        model.PolyLine{Color: model.TrackColor, Width: 2},
+		MinDistance: minDistance,
+		MinInterval: minInterval,
+		Started:     time.Now(),
+	}
+}
+
+// Recording reports whether the track is still taking points, even if paused
+func (t *Track) Recording() bool {
+	return !t.stopped
+}
+
+// Add appends a fix to the track if it is new and passes the distance and
+// time filter, reporting whether it was added
+func (t *Track) Add(fix GPSFix) bool {
+	if t.paused || t.stopped || !fix.HasFix() || fix.Stale() || !fix.Received.After(t.lastFix) {
+		return false
+	}
+	t.lastFix = fix.Received
+
+	fixTime := fix.Time
+	if fixTime.IsZero() {
+		fixTime = fix.Received.UTC()
+	}
+
+	dist := 0.0
+	if n := len(t.Line.Points); n > 0 && t.resumed {
+		t.segmentStarts = append(t.segmentStarts, n)
+	} else if n > 0 {
+		last := t.Line.Points[n-1]
+		dist = model.GeodesicDistance(last.Lat, last.Lon, fix.Latitude, fix.Longitude)
+		if dist < t.MinDistance || fixTime.Sub(last.Time) < t.MinInterval {
+			return false
+		}
+	}
+	t.resumed = false
+
+	t.Line.Points = append(t.Line.Points, model.LinePoint{Lat: fix.Latitude, Lon: fix.Longitude, Dist: dist, Time: fixTime, Alt: fix.Altitude, HDOP: fix.HDOP})
+	return true
+}
+
+// TogglePause pauses or resumes recording, reporting whether it is now paused
+func (t *Track) TogglePause() bool {
+	t.paused = !t.paused
+	if !t.paused {
+		t.resumed = true
+	}
+	return t.paused
+}
+
+// Paused reports whether recording is paused
+func (t *Track) Paused() bool {
+	return t.paused
+}
+
+func (t *Track) Stop() {
+	t.stopped = true
+}
+
+// MarkSaved records that the track has been exported or added to the map as
+// it is now
+func (t *Track) MarkSaved() {
+	t.savedPoints = len(t.Line.Points)
+}
+
+// Unsaved reports whether points have been recorded since the track was last
+// exported or added to the map
+func (t *Track) Unsaved() bool {
+	return len(t.Line.Points) > t.savedPoints
+}
+
+// Segments returns the points of each stretch recorded without a pause
+func (t *Track) Segments() [][]model.LinePoint {
+	var segments [][]model.LinePoint
+	start := 0
+	for _, end := range append(t.segmentStarts, len(t.Line.Points)) {
+		if end > start {
+			segments = append(segments, t.Line.Points[start:end])
+		}
+		start = end
+	}
+	return segments
+}
+
+// Length returns the length of the track in meters, leaving out the gaps
+// between segments
+func (t *Track) Length() float64 {
+	length := 0.0
+	for _, segment := range t.Segments() {
+		length += model.LineLength(segment)
+	}
+	return length
+}
+
+// gpxFile is the part of GPX 1.1 written for tracks
+type gpxFile struct {
+	XMLName        xml.Name `xml:"gpx"`
+	Version        string   `xml:"version,attr"`
+	Creator        string   `xml:"creator,attr"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	XmlnsXsi       string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Metadata       struct {
+		Time string `xml:"time"`
+	} `xml:"metadata"`
+	Tracks []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// gpxPoint holds a track point, with its elements in the order GPX 1.1 requires
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele,omitempty"`
+	Time string   `xml:"time,omitempty"`
+	HDOP *float64 `xml:"hdop,omitempty"`
+}
+
+// WriteGPX writes the track as a GPX 1.1 file with a track segment for each
+// stretch recorded without a pause
+func (t *Track) WriteGPX(w io.Writer, name string) error {
+	gpx := gpxFile{
+		Version:        "1.1",
+		Creator:        "FiberForge",
+		Xmlns:          "http://www.topografix.com/GPX/1/1",
+		XmlnsXsi:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd",
+	}
+	gpx.Metadata.Time = t.Started.UTC().Format(time.RFC3339)
+
+	track := gpxTrack{Name: name}
+	for _, points := range t.Segments() {
+		var segment gpxSegment
+		for _, point := range points {
+			p := gpxPoint{Lat: point.Lat, Lon: point.Lon}
+			if point.Alt != 0 {
+				alt := point.Alt
+				p.Ele = &alt
+			}
+			if !point.Time.IsZero() {
+				p.Time = point.Time.UTC().Format(time.RFC3339Nano)
+			}
+			if point.HDOP > 0 {
+				hdop := point.HDOP
+				p.HDOP = &hdop
+			}
+			segment.Points = append(segment.Points, p)
+		}
+		track.Segments = append(track.Segments, segment)
+	}
+	gpx.Tracks = []gpxTrack{track}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(gpx); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteGPXFile saves the track as a GPX file
+func (t *Track) WriteGPXFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := t.WriteGPX(file, t.Started.Format("2006-01-02 15:04")); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Status describes the track for messages and the GPS panel
+func (t *Track) Status(units model.DistanceUnit) string {
+	state := "Recording"
+	switch {
+	case t.stopped:
+		state = "Recorded"
+	case t.paused:
+		state = "Paused"
+	}
+	return fmt.Sprintf("%s track: %d points, %s", state, len(t.Line.Points), units.FormatDistance(t.Length()))
+}
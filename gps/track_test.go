@@ -0,0 +1,258 @@
+package gps
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+)
+
+// trackFixes returns a fix every second of receiver time heading north from
+// 35.15,-90.05, each step meters after the one before. They were received a
+// millisecond apart just now, so none are stale.
+func trackFixes(step float64, n int) []GPSFix {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	received := time.Now().Add(-time.Duration(n) * time.Millisecond)
+	var fixes []GPSFix
+	lat, lon := 35.15, -90.05
+	for i := 0; i < n; i++ {
+		fixes = append(fixes, GPSFix{
+			Latitude:  lat,
+			Longitude: lon,
+			Altitude:  80 + float64(i),
+			HDOP:      0.8,
+			Time:      start.Add(time.Duration(i) * time.Second),
+			Received:  received.Add(time.Duration(i) * time.Millisecond),
+		})
+		lat, lon = model.VincentyDirect(lat, lon, 0, step)
+	}
+	return fixes
+}
+
+func TestTrackAdd(t *testing.T) {
+	track := NewTrack(DefaultTrackDistance, DefaultTrackInterval)
+	fixes := trackFixes(1.5, 5)
+
+	// Every other fix moves 3 m, enough to pass the 2 m filter
+	var added []bool
+	for _, fix := range fixes {
+		added = append(added, track.Add(fix))
+	}
+	if want := []bool{true, false, true, false, true}; !equalBools(added, want) {
+		t.Errorf("added %v, want %v", added, want)
+	}
+	if len(track.Line.Points) != 3 {
+		t.Fatalf("%d points, want 3", len(track.Line.Points))
+	}
+	point := track.Line.Points[1]
+	if math.Abs(point.Dist-3) > 1e-6 || point.Alt != 82 || point.HDOP != 0.8 || !point.Time.Equal(fixes[2].Time) {
+		t.Errorf("second point %+v, want 3 m from the first with the fix's time, altitude and HDOP", point)
+	}
+	if length := track.Length(); math.Abs(length-6) > 1e-6 {
+		t.Errorf("length %.6f m, want 6 m", length)
+	}
+
+	// The same fix seen again, a stale one and one with no position are not added
+	last := fixes[4]
+	stale := last
+	stale.Received = time.Now().Add(-2 * GPSStaleAfter)
+	for name, fix := range map[string]GPSFix{"repeated": last, "stale": stale, "no fix": {}} {
+		if track.Add(fix) {
+			t.Errorf("%s fix added", name)
+		}
+	}
+}
+
+func TestTrackMinInterval(t *testing.T) {
+	track := NewTrack(1, 3*time.Second)
+	added := 0
+	for _, fix := range trackFixes(10, 7) {
+		if track.Add(fix) {
+			added++
+		}
+	}
+	// Moving far enough every second, but only every third second counts
+	if added != 3 {
+		t.Errorf("added %d points, want 3", added)
+	}
+
+	// Without the receiver's time the received time is used
+	track = NewTrack(1, 0)
+	fix := trackFixes(10, 1)[0]
+	fix.Time = time.Time{}
+	track.Add(fix)
+	if got := track.Line.Points[0].Time; !got.Equal(fix.Received) || got.Location() != time.UTC {
+		t.Errorf("point time %v, want the received time %v in UTC", got, fix.Received)
+	}
+}
+
+func TestTrackSegments(t *testing.T) {
+	track := NewTrack(1, 0)
+	fixes := trackFixes(10, 8)
+	for _, fix := range fixes[:3] {
+		track.Add(fix)
+	}
+	if !track.TogglePause() || !track.Paused() {
+		t.Fatal("TogglePause did not pause")
+	}
+	// Fixes while paused are dropped
+	for _, fix := range fixes[3:5] {
+		if track.Add(fix) {
+			t.Error("fix added while paused")
+		}
+	}
+	track.TogglePause()
+	for _, fix := range fixes[5:] {
+		track.Add(fix)
+	}
+
+	segments := track.Segments()
+	if len(segments) != 2 || len(segments[0]) != 3 || len(segments[1]) != 3 {
+		t.Fatalf("segments of %v points, want 3 and 3", segmentLengths(segments))
+	}
+	// The first point after a pause isn't measured from the last one before it
+	if dist := segments[1][0].Dist; dist != 0 {
+		t.Errorf("first point after the pause is %.3f m on, want 0", dist)
+	}
+	if length := track.Length(); math.Abs(length-40) > 1e-6 {
+		t.Errorf("length %.6f m, want 40 m without the paused stretch", length)
+	}
+
+	// Pausing again with nothing recorded before stopping leaves no empty segment
+	track.TogglePause()
+	track.TogglePause()
+	track.Stop()
+	if track.Recording() || track.Add(trackFixes(10, 9)[8]) {
+		t.Error("stopped track still recording")
+	}
+	if segments := track.Segments(); len(segments) != 2 {
+		t.Errorf("segments of %v points after stopping, want 2 segments", segmentLengths(segments))
+	}
+	if status := track.Status(model.UnitMeters); status != "Recorded track: 6 points, 40.0 m" {
+		t.Errorf("status %q", status)
+	}
+}
+
+func TestTrackUnsaved(t *testing.T) {
+	track := NewTrack(1, 0)
+	if track.Unsaved() {
+		t.Error("empty track is unsaved")
+	}
+	fixes := trackFixes(10, 3)
+	track.Add(fixes[0])
+	if !track.Unsaved() {
+		t.Error("recorded point not unsaved")
+	}
+	track.MarkSaved()
+	if track.Unsaved() {
+		t.Error("saved track still unsaved")
+	}
+	track.Add(fixes[1])
+	if !track.Unsaved() {
+		t.Error("point recorded after saving not unsaved")
+	}
+}
+
+func TestTrackWriteGPX(t *testing.T) {
+	track := NewTrack(1, 0)
+	track.Started = time.Date(2026, 10, 19, 11, 59, 0, 0, time.FixedZone("CDT", -5*3600))
+	fixes := trackFixes(10, 4)
+	fixes[1].Altitude, fixes[1].HDOP = 0, 0
+	track.Add(fixes[0])
+	track.Add(fixes[1])
+	track.TogglePause()
+	track.TogglePause()
+	track.Add(fixes[2])
+	track.Add(fixes[3])
+
+	var buf bytes.Buffer
+	if err := track.WriteGPX(&buf, "Drive"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("GPX does not start with the XML header: %q", buf.String()[:40])
+	}
+
+	var gpx struct {
+		XMLName  xml.Name
+		Version  string `xml:"version,attr"`
+		Metadata struct {
+			Time string `xml:"time"`
+		} `xml:"metadata"`
+		Tracks []struct {
+			Name     string `xml:"name"`
+			Segments []struct {
+				Points []struct {
+					Lat  float64  `xml:"lat,attr"`
+					Lon  float64  `xml:"lon,attr"`
+					Ele  *float64 `xml:"ele"`
+					Time string   `xml:"time"`
+					HDOP *float64 `xml:"hdop"`
+				} `xml:"trkpt"`
+			} `xml:"trkseg"`
+		} `xml:"trk"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &gpx); err != nil {
+		t.Fatalf("invalid GPX: %v", err)
+	}
+	if gpx.XMLName.Space != "http://www.topografix.com/GPX/1/1" || gpx.XMLName.Local != "gpx" || gpx.Version != "1.1" {
+		t.Errorf("root element %v version %s, want GPX 1.1", gpx.XMLName, gpx.Version)
+	}
+	if gpx.Metadata.Time != "2026-10-19T16:59:00Z" {
+		t.Errorf("metadata time %s, want the start in UTC", gpx.Metadata.Time)
+	}
+	if len(gpx.Tracks) != 1 || gpx.Tracks[0].Name != "Drive" {
+		t.Fatalf("tracks %+v, want one named Drive", gpx.Tracks)
+	}
+	segments := gpx.Tracks[0].Segments
+	if len(segments) != 2 || len(segments[0].Points) != 2 || len(segments[1].Points) != 2 {
+		t.Fatalf("segments %+v, want two of two points", segments)
+	}
+
+	first := segments[0].Points[0]
+	if first.Lat != fixes[0].Latitude || first.Lon != fixes[0].Longitude || first.Time != "2026-10-19T12:00:00Z" {
+		t.Errorf("first point %+v", first)
+	}
+	if first.Ele == nil || *first.Ele != 80 || first.HDOP == nil || *first.HDOP != 0.8 {
+		t.Errorf("first point elevation %v, HDOP %v, want 80 and 0.8", first.Ele, first.HDOP)
+	}
+	// Unknown altitude and HDOP are left out rather than written as 0
+	if second := segments[0].Points[1]; second.Ele != nil || second.HDOP != nil {
+		t.Errorf("second point elevation %v, HDOP %v, want none", second.Ele, second.HDOP)
+	}
+	if last := segments[1].Points[1]; last.Lat != fixes[3].Latitude || last.Time != "2026-10-19T12:00:03Z" {
+		t.Errorf("last point %+v", last)
+	}
+
+	// Elements must be in GPX 1.1 schema order
+	point := buf.String()[strings.Index(buf.String(), "<trkpt"):]
+	point = point[:strings.Index(point, "</trkpt>")]
+	ele, when, hdop := strings.Index(point, "<ele>"), strings.Index(point, "<time>"), strings.Index(point, "<hdop>")
+	if !(ele < when && when < hdop) {
+		t.Errorf("trkpt elements out of order: %s", point)
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func segmentLengths(segments [][]model.LinePoint) []int {
+	var lengths []int
+	for _, segment := range segments {
+		lengths = append(lengths, len(segment))
+	}
+	return lengths
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const headingMinSpeed = 1.0 // Knots, below this the course is mostly noise
+
+var gpsArrowColor = color.RGBA{0, 0, 160, 230}
+
+// updateGPSFollow keeps the GPS position centered while following. Dragging
+// the map or panning with the keys stops following.
+func (g *Game) updateGPSFollow() {
+	if !g.GPSFollow {
+		return
+	}
+	keyPan := ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyRight) ||
+		ebiten.IsKeyPressed(ebiten.KeyShift) && (ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyDown))
+	if keyPan || g.input.panning && g.panned() {
+		g.ToggleGPSFollow()
+		return
+	}
+	g.RecenterOnGPS()
+}
+
+// drawHeadingArrow draws an arrow pointing along the course from the edge of
+// the GPS circle
+func drawHeadingArrow(screen *ebiten.Image, x, y, radius float32, course float64) {
+	sin, cos := math.Sincos(model.ToRadians(course))
+	dx, dy := float32(sin), float32(-cos) // Course is clockwise from north, screen y grows down
+
+	length := radius + 16
+	halfWidth := float32(7)
+	tipX, tipY := x+dx*length, y+dy*length
+	baseX, baseY := x+dx*radius, y+dy*radius
+
+	var path vector.Path
+	path.MoveTo(tipX, tipY)
+	path.LineTo(baseX-dy*halfWidth, baseY+dx*halfWidth)
+	path.LineTo(baseX+dy*halfWidth, baseY-dx*halfWidth)
+	path.Close()
+
+	fillPath(screen, &path, gpsArrowColor)
+}
+
+// fillPath fills a closed path with a solid color
+func fillPath(screen *ebiten.Image, path *vector.Path, clr color.RGBA) {
+	vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	for i := range vertices {
+		vertices[i].SrcX, vertices[i].SrcY = 1, 1
+		vertices[i].ColorR = float32(clr.R) / 255
+		vertices[i].ColorG = float32(clr.G) / 255
+		vertices[i].ColorB = float32(clr.B) / 255
+		vertices[i].ColorA = float32(clr.A) / 255
+	}
+	screen.DrawTriangles(vertices, indices, whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image), &ebiten.DrawTrianglesOptions{})
+}
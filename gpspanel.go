@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/OpticalFlyer/FiberForge/gps"
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	gpsPanelWidth      = 230
+	gpsDotRadius       = 6
+	gpsEllipseSegments = 48
+)
+
+var (
+	gpsColor             = color.RGBA{0, 0, 255, 179}
+	gpsStaleColor        = color.RGBA{128, 128, 128, 179}
+	gpsEllipseColor      = color.RGBA{0, 0, 255, 50}
+	gpsStaleEllipseColor = color.RGBA{128, 128, 128, 50}
+	gpsWarnColor         = color.RGBA{255, 96, 96, 255}
+)
+
+// drawGPSPosition draws the GPS position as a dot inside its accuracy
+// ellipse, in meters on the ground so it shrinks and grows with the zoom.
+// Both turn grey once the fix is stale.
+func (g *Game) drawGPSPosition(screen *ebiten.Image, view model.Viewport, fix gps.GPSFix) {
+	if !fix.HasFix() {
+		return
+	}
+
+	dotColor, ellipseColor := gpsColor, gpsEllipseColor
+	if fix.Stale() {
+		dotColor, ellipseColor = gpsStaleColor, gpsStaleEllipseColor
+	}
+
+	semiMajor, semiMinor, orientation := fix.ErrorEllipse()
+	var xs, ys [gpsEllipseSegments]float32
+	var path vector.Path
+	for i := 0; i < gpsEllipseSegments; i++ {
+		// Distance from the center to the ellipse at this angle from the semi-major axis
+		theta := 2 * math.Pi * float64(i) / gpsEllipseSegments
+		sin, cos := math.Sincos(theta)
+		r := semiMajor * semiMinor / math.Hypot(semiMinor*cos, semiMajor*sin)
+
+		lat, lon := model.VincentyDirect(fix.Latitude, fix.Longitude, orientation+model.ToDegrees(theta), r)
+		xs[i], ys[i] = view.LatLngToScreen(lat, model.NearestLongitude(lon, fix.Longitude))
+		if i == 0 {
+			path.MoveTo(xs[i], ys[i])
+		} else {
+			path.LineTo(xs[i], ys[i])
+		}
+	}
+	path.Close()
+	fillPath(screen, &path, ellipseColor)
+	for i := 0; i < gpsEllipseSegments; i++ {
+		j := (i + 1) % gpsEllipseSegments
+		vector.StrokeLine(screen, xs[i], ys[i], xs[j], ys[j], 1, dotColor, false)
+	}
+
+	gpsX, gpsY := view.LatLngToScreen(fix.Latitude, fix.Longitude)
+	vector.DrawFilledCircle(screen, gpsX, gpsY, gpsDotRadius, dotColor, false)
+
+	if fix.Speed >= headingMinSpeed && !fix.Stale() {
+		drawHeadingArrow(screen, gpsX, gpsY, gpsDotRadius, fix.Course)
+	}
+}
+
+// drawGPSPanel shows the state of the GPS in the top right corner, returning
+// the y of its bottom edge
+func (g *Game) drawGPSPanel(screen *ebiten.Image, fix gps.GPSFix) int {
+	lines := []string{fmt.Sprintf("GPS: %s", g.GPS.Source())}
+	warning := ""
+
+	if !fix.HasFix() {
+		warning = "Waiting for fix"
+	} else {
+		status := fix.QualityName()
+		if name := fix.FixTypeName(); name != "" {
+			if status != "" {
+				status += ", "
+			}
+			status += name
+		}
+		if status == "" {
+			status = "Fix"
+		}
+		lines = append(lines,
+			fmt.Sprintf("Fix: %s", status),
+			fmt.Sprintf("Satellites: %d used, %d in view", fix.SatellitesUsed, fix.SatellitesInView),
+			fmt.Sprintf("HDOP %.1f  VDOP %.1f  PDOP %.1f", fix.HDOP, fix.VDOP, fix.PDOP),
+			accuracyDescription(fix, g.Units),
+			fmt.Sprintf("%.7f, %.7f", fix.Latitude, fix.Longitude),
+			fmt.Sprintf("Alt %.1f m  %.1f kn  %.0f deg", fix.Altitude, fix.Speed, fix.Course),
+		)
+		if !fix.Time.IsZero() {
+			lines = append(lines, fmt.Sprintf("Time: %s UTC", fix.Time.Format("15:04:05")))
+		}
+		age := time.Since(fix.Received)
+		lines = append(lines, fmt.Sprintf("Updated %.0fs ago", age.Seconds()))
+		if fix.Stale() {
+			warning = "Fix is stale"
+		}
+	}
+	if g.Track != nil && g.Track.Recording() {
+		lines = append(lines, g.Track.Status(g.Units))
+	}
+	if status := g.AverageStatus(); status != "" {
+		lines = append(lines, status)
+	}
+	if g.GPSFollow {
+		lines = append(lines, "Following")
+	}
+	if replay := g.GPS.Replay(); replay != nil {
+		lines = append(lines, replay.Status())
+	}
+	if err := g.GPS.Err(); err != nil {
+		warning = fmt.Sprintf("Stopped: %v", err)
+		if maxChars := (gpsPanelWidth - 16) / 7; len(warning) > maxChars {
+			warning = warning[:maxChars-3] + "..."
+		}
+	}
+	if warning != "" {
+		lines = append(lines, warning)
+	}
+
+	lineHeight := 16
+	width := gpsPanelWidth
+	height := len(lines)*lineHeight + 8
+	x := g.View.Width - width - 10
+	y := 10
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{50, 50, 50, 200}, false)
+
+	fontFace := basicfont.Face7x13
+	for i, line := range lines {
+		clr := color.Color(color.White)
+		if warning != "" && i == len(lines)-1 {
+			clr = gpsWarnColor
+		}
+		text.Draw(screen, line, fontFace, x+8, y+16+i*lineHeight, clr)
+	}
+	return y + height
+}
+
+// accuracyDescription gives the horizontal accuracy, and the GST ellipse when
+// the receiver sends one
+func accuracyDescription(fix gps.GPSFix, units model.DistanceUnit) string {
+	if fix.HasErrorEllipse() {
+		return fmt.Sprintf("Accuracy %s (%s x %s at %.0f deg)", units.FormatDistance(fix.HorizontalAccuracy()),
+			units.FormatDistance(fix.SemiMajor), units.FormatDistance(fix.SemiMinor), fix.Orientation)
+	}
+	return fmt.Sprintf("Accuracy ~%s (from HDOP)", units.FormatDistance(fix.HorizontalAccuracy()))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+const hoverThreshold = 5.0 // Pixels
+
+type hoverKind int
+
+const (
+	hoverNone hoverKind = iota
+	hoverPoint
+	hoverLine
+	hoverPolygon
+)
+
+// hoverState remembers the feature under the mouse, found again only when
+// the mouse, the view or the document changes
+type hoverState struct {
+	mouseX, mouseY int
+	view           model.Viewport
+	kind           hoverKind
+	index          int
+	valid          bool
+}
+
+// drawPointObject draws a point's icon, or a circle when it has none, and
+// its name label
+func drawPointObject(dst *ebiten.Image, pointX, pointY float32, point model.PointObject, icon *ebiten.Image) {
+	// A scale of 0 means none was given
+	scale := point.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+
+	labelX := float64(pointX)
+	if icon != nil {
+		// Draw the icon with the hotspot offset from the bottom-left corner
+		left, top, width, _ := pointIconBounds(pointX, pointY, point, icon)
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(scale, scale)
+		op.GeoM.Translate(left, top)
+		op.Filter = ebiten.FilterLinear
+		if point.IconColor != (color.RGBA{}) {
+			op.ColorScale.ScaleWithColor(point.IconColor)
+		}
+
+		dst.DrawImage(icon, op)
+		labelX = left + width
+	} else {
+		// Draw a circle if there's no icon
+		pointRadius := 5.0 * scale
+		pointColor := point.Color
+
+		vector.DrawFilledCircle(dst, pointX, pointY, float32(pointRadius), pointColor, false)
+		vector.StrokeCircle(dst, pointX, pointY, float32(pointRadius), 2, color.RGBA{0, 0, 0, 255}, false)
+		labelX += pointRadius
+	}
+
+	if point.Name != "" && point.LabelScale > 0 {
+		drawLabel(dst, point.Name, labelX+2, float64(pointY), point.LabelColor, point.LabelScale)
+	}
+}
+
+// pointIconBounds returns where a point's icon is drawn. If hotspot x and y
+// are both 0 the icon is centered on the point.
+func pointIconBounds(pointX, pointY float32, point model.PointObject, icon *ebiten.Image) (left, top, width, height float64) {
+	scale := point.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	width = float64(icon.Bounds().Dx()) * scale
+	height = float64(icon.Bounds().Dy()) * scale
+	if point.HotSpot.X == 0 && point.HotSpot.Y == 0 {
+		return float64(pointX) - width/2, float64(pointY) - height/2, width, height
+	}
+	return float64(pointX) - point.HotSpot.X*scale, float64(pointY) - height + point.HotSpot.Y*scale, width, height
+}
+
+// drawLabel draws text left aligned at x and vertically centered on y, with a
+// dark shadow to stand out on imagery
+func drawLabel(dst *ebiten.Image, label string, x, y float64, clr color.RGBA, scale float64) {
+	fontFace := basicfont.Face7x13
+	ascent := float64(fontFace.Metrics().Ascent.Ceil())
+
+	shadow := &ebiten.DrawImageOptions{}
+	shadow.GeoM.Scale(scale, scale)
+	shadow.GeoM.Translate(x+1, y+ascent*scale/2+1)
+	shadow.ColorScale.ScaleWithColor(color.RGBA{0, 0, 0, clr.A})
+	text.DrawWithOptions(dst, label, fontFace, shadow)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(x, y+ascent*scale/2)
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(dst, label, fontFace, op)
+}
+
+// pointIcon returns the image a point is drawn with, its KML icon or the
+// symbol of its type, nil to draw a circle
+func (g *Game) pointIcon(point model.PointObject) *ebiten.Image {
+	if point.IconHref == "" {
+		if point.Type == "" || !g.Doc.LoadIcons {
+			return nil
+		}
+		img, err := symbolImage(point.Type)
+		if err != nil {
+			return nil
+		}
+		return img
+	}
+
+	if icon, ok := g.icons[point.IconHref]; ok {
+		return icon
+	}
+	img := g.Doc.Icons[point.IconHref]
+	if img == nil {
+		return nil // Not loaded, or failed to load
+	}
+	icon := ebiten.NewImageFromImage(img)
+	g.icons[point.IconHref] = icon
+	return icon
+}
+
+// withStyle returns the point drawn with a highlight style
+func withStyle(p model.PointObject, style model.FeatureStyle) model.PointObject {
+	if style.IconHref != "" {
+		p.IconHref = style.IconHref
+	}
+	p.IconColor = style.IconColor
+	p.Scale = style.IconScale
+	p.HotSpot = style.IconHotSpot
+	p.LabelColor = style.LabelColor
+	p.LabelScale = style.LabelScale
+	return p
+}
+
+// findHover finds the feature with a highlight style under the mouse,
+// looking at points, then lines, then polygons like a click does
+func (g *Game) findHover(view model.Viewport, mouseX, mouseY int) (hoverKind, int) {
+	x, y := float64(mouseX), float64(mouseY)
+
+	for index := len(g.Doc.Points) - 1; index >= 0; index-- {
+		point := g.Doc.Points[index]
+		if point.Highlight == nil || !g.Doc.Visible(point.Layer) {
+			continue
+		}
+		pointX, pointY := view.LatLngToScreen(point.Lat, point.Lon)
+		if icon := g.pointIcon(point); icon != nil {
+			left, top, width, height := pointIconBounds(pointX, pointY, point, icon)
+			if x >= left && x <= left+width && y >= top && y <= top+height {
+				return hoverPoint, index
+			}
+		} else if math.Hypot(x-float64(pointX), y-float64(pointY)) <= hoverThreshold {
+			return hoverPoint, index
+		}
+	}
+
+	for index := len(g.Doc.Lines) - 1; index >= 0; index-- {
+		line := g.Doc.Lines[index]
+		if line.Highlight == nil || !g.Doc.Visible(line.Layer) {
+			continue
+		}
+		threshold := math.Max(hoverThreshold, float64(line.Width)/2)
+		for i := 0; i < len(line.Points)-1; i++ {
+			x0, y0, x1, y1 := view.SegmentToScreen(line.Points[i].Lat, line.Points[i].Lon, line.Points[i+1].Lat, line.Points[i+1].Lon)
+			if model.PointLineSegmentDistance(x, y, float64(x0), float64(y0), float64(x1), float64(y1)) <= threshold {
+				return hoverLine, index
+			}
+		}
+	}
+
+	for index := len(g.Doc.Polygons) - 1; index >= 0; index-- {
+		polygon := g.Doc.Polygons[index]
+		if polygon.Highlight != nil && g.Doc.Visible(polygon.Layer) && len(polygon.Points) > 2 && pointInPolygon(x, y, polygonScreenPoints(view, polygon.Points)) {
+			return hoverPolygon, index
+		}
+	}
+
+	return hoverNone, 0
+}
+
+// drawHighlight redraws the feature under the mouse with its highlight style
+func (g *Game) drawHighlight(screen *ebiten.Image, view model.Viewport, mouseX, mouseY int) {
+	if g.Drawing() || g.Measuring() || g.input.panning {
+		return
+	}
+
+	hover := &g.hover
+	if !hover.valid || hover.mouseX != mouseX || hover.mouseY != mouseY || hover.view != view {
+		hover.kind, hover.index = g.findHover(view, mouseX, mouseY)
+		hover.mouseX, hover.mouseY, hover.view, hover.valid = mouseX, mouseY, view, true
+	}
+
+	switch hover.kind {
+	case hoverPoint:
+		point := g.Doc.Points[hover.index]
+		pointX, pointY := view.LatLngToScreen(point.Lat, point.Lon)
+		point = withStyle(point, *point.Highlight)
+		drawPointObject(screen, pointX, pointY, point, g.pointIcon(point))
+	case hoverLine:
+		line := g.Doc.Lines[hover.index]
+		for i := 0; i < len(line.Points)-1; i++ {
+			solidLine(screen, view, line.Points[i].Lat, line.Points[i].Lon, line.Points[i+1].Lat, line.Points[i+1].Lon, line.Highlight.LineWidth, line.Highlight.LineColor)
+		}
+	case hoverPolygon:
+		polygon := g.Doc.Polygons[hover.index]
+		drawFilledPolygon(screen, polygonScreenPoints(view, polygon.Points), *polygon.Highlight)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"image/color"
 	"math"
 
+	"github.com/OpticalFlyer/FiberForge/model"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
@@ -76,9 +77,8 @@ func textDashedLine(screen *ebiten.Image, x0, y0, x1, y1, dashLength, gapLength,
 
 /*
 // Dashed line from world coordinates
-func dashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom float64, screenWidth, screenHeight int, dashLength, gapLength, strokeWidth float32, clr color.Color) {
-	x0, y0 := latLngToScreenCoords(lat0, lng0, centerLat, centerLon, zoom, screenWidth, screenHeight)
-	x1, y1 := latLngToScreenCoords(lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight)
+func dashedLine(screen *ebiten.Image, view model.Viewport, lat0, lng0, lat1, lng1 float64, dashLength, gapLength, strokeWidth float32, clr color.Color) {
+	x0, y0, x1, y1 := view.SegmentToScreen(lat0, lng0, lat1, lng1)
 	dx := x1 - x0
 	dy := y1 - y0
 	length := float32(math.Sqrt(float64(dx*dx + dy*dy)))
@@ -115,9 +115,8 @@ func dashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerL
 */
 
 // Optimized dashed line
-func dashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom float64, screenWidth, screenHeight int, dashLength, gapLength, strokeWidth float32, clr color.Color) {
-	x0, y0 := latLngToScreenCoords(lat0, lng0, centerLat, centerLon, zoom, screenWidth, screenHeight)
-	x1, y1 := latLngToScreenCoords(lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight)
+func dashedLine(screen *ebiten.Image, view model.Viewport, lat0, lng0, lat1, lng1 float64, dashLength, gapLength, strokeWidth float32, clr color.Color) {
+	x0, y0, x1, y1 := view.SegmentToScreen(lat0, lng0, lat1, lng1)
 	dx := x1 - x0
 	dy := y1 - y0
 	length := float32(math.Sqrt(float64(dx*dx + dy*dy)))
@@ -142,8 +141,8 @@ func dashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerL
 		endY := y0 + (i+dashLength)*float32(math.Sin(angle))
 
 		// Check if the dash is within the screen bounds
-		if (startX >= 0 && startX <= float32(screenWidth) && startY >= 0 && startY <= float32(screenHeight)) ||
-			(endX >= 0 && endX <= float32(screenWidth) && endY >= 0 && endY <= float32(screenHeight)) {
+		if (startX >= 0 && startX <= float32(view.Width) && startY >= 0 && startY <= float32(view.Height)) ||
+			(endX >= 0 && endX <= float32(view.Width) && endY >= 0 && endY <= float32(view.Height)) {
 			vector.StrokeLine(screen, startX, startY, endX, endY, strokeWidth, clr, false)
 		}
 	}
@@ -158,9 +157,8 @@ func dashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerL
 
 /*
 // textDashedLine in world coordinates
-func textDashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom float64, screenWidth, screenHeight int, dashLength, gapLength, strokeWidth float32, clr color.Color, textStr string) {
-	x0, y0 := latLngToScreenCoords(lat0, lng0, centerLat, centerLon, zoom, screenWidth, screenHeight)
-	x1, y1 := latLngToScreenCoords(lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight)
+func textDashedLine(screen *ebiten.Image, view model.Viewport, lat0, lng0, lat1, lng1 float64, dashLength, gapLength, strokeWidth float32, clr color.Color, textStr string) {
+	x0, y0, x1, y1 := view.SegmentToScreen(lat0, lng0, lat1, lng1)
 	dx := x1 - x0
 	dy := y1 - y0
 	length := math.Sqrt(float64(dx*dx + dy*dy))
@@ -172,7 +170,7 @@ func textDashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, cen
 	segment := interval * float64(dashes)
 	bookend := (length - segment) / 2
 
-	dashedLine(screen, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight, dashLength, gapLength, strokeWidth, clr)
+	dashedLine(screen, view, lat0, lng0, lat1, lng1, dashLength, gapLength, strokeWidth, clr)
 
 	gapCount := int(length / interval)
 	for i := 0; i < gapCount; i++ {
@@ -186,9 +184,8 @@ func textDashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, cen
 }
 */
 
-func textDashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom float64, screenWidth, screenHeight int, dashLength, gapLength, strokeWidth float32, clr color.Color, textStr, label string) {
-	x0, y0 := latLngToScreenCoords(lat0, lng0, centerLat, centerLon, zoom, screenWidth, screenHeight)
-	x1, y1 := latLngToScreenCoords(lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight)
+func textDashedLine(screen *ebiten.Image, view model.Viewport, lat0, lng0, lat1, lng1 float64, dashLength, gapLength, strokeWidth float32, clr color.Color, textStr, label string) {
+	x0, y0, x1, y1 := view.SegmentToScreen(lat0, lng0, lat1, lng1)
 	dx := x1 - x0
 	dy := y1 - y0
 	length := math.Sqrt(float64(dx*dx + dy*dy))
@@ -200,21 +197,21 @@ func textDashedLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, cen
 	segment := interval * float64(dashes)
 	bookend := (length - segment) / 2
 
-	dashedLine(screen, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight, dashLength, gapLength, strokeWidth, clr)
+	dashedLine(screen, view, lat0, lng0, lat1, lng1, dashLength, gapLength, strokeWidth, clr)
 
 	gapCount := int(length / interval)
 	for i := 0; i < gapCount; i++ {
 		gapCenterX := float64(x0) + float64(i)*interval*math.Cos(angle) + (offset+bookend)*math.Cos(angle)
 		gapCenterY := float64(y0) + float64(i)*interval*math.Sin(angle) + (offset+bookend)*math.Sin(angle)
 
-		if gapCenterX >= 0 && gapCenterX <= float64(screenWidth) && gapCenterY >= 0 && gapCenterY <= float64(screenHeight) {
+		if gapCenterX >= 0 && gapCenterX <= float64(view.Width) && gapCenterY >= 0 && gapCenterY <= float64(view.Height) {
 			rotatedText(screen, gapCenterX, gapCenterY, angle, clr, textStr, -5)
 		}
 	}
 
 	labelX := float64(x0) + length/2*math.Cos(angle)
 	labelY := float64(y0) + length/2*math.Sin(angle)
-	if labelX >= 0 && labelX <= float64(screenWidth) && labelY >= 0 && labelY <= float64(screenHeight) {
+	if labelX >= 0 && labelX <= float64(view.Width) && labelY >= 0 && labelY <= float64(view.Height) {
 		rotatedText(screen, labelX, labelY, angle, clr, label, -20)
 	}
 }
@@ -238,13 +235,12 @@ func rotatedText(screen *ebiten.Image, x, y, angle float64, clr color.Color, tex
 	screen.DrawImage(textImage, textOpts)
 }
 
-func solidLine(screen *ebiten.Image, lat0, lng0, lat1, lng1, centerLat, centerLon, zoom float64, screenWidth, screenHeight int, strokeWidth float32, clr color.Color) {
-	x0, y0 := latLngToScreenCoords(lat0, lng0, centerLat, centerLon, zoom, screenWidth, screenHeight)
-	x1, y1 := latLngToScreenCoords(lat1, lng1, centerLat, centerLon, zoom, screenWidth, screenHeight)
+func solidLine(screen *ebiten.Image, view model.Viewport, lat0, lng0, lat1, lng1 float64, strokeWidth float32, clr color.Color) {
+	x0, y0, x1, y1 := view.SegmentToScreen(lat0, lng0, lat1, lng1)
 
 	// Check if the line is within the screen bounds
-	if (x0 >= 0 && x0 <= float32(screenWidth) && y0 >= 0 && y0 <= float32(screenHeight)) ||
-		(x1 >= 0 && x1 <= float32(screenWidth) && y1 >= 0 && y1 <= float32(screenHeight)) {
+	if (x0 >= 0 && x0 <= float32(view.Width) && y0 >= 0 && y0 <= float32(view.Height)) ||
+		(x1 >= 0 && x1 <= float32(view.Width) && y1 >= 0 && y1 <= float32(view.Height)) {
 		vector.StrokeLine(screen, x0, y0, x1, y1, strokeWidth, clr, false)
 	}
 }
@@ -5,114 +5,73 @@ import (
 	"image/color"
 	"log"
 	"math"
+	"os"
+	"strings"
 
-	"github.com/atotto/clipboard"
-
+	"github.com/OpticalFlyer/FiberForge/cli"
+	"github.com/OpticalFlyer/FiberForge/editor"
+	"github.com/OpticalFlyer/FiberForge/model"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
-type PointObject struct {
-	Lat, Lon  float64
-	Color     color.RGBA
-	IconImage *ebiten.Image
-	Scale     float64
-	HotSpot   HotSpot
-}
-
-type LinePoint struct {
-	Lat, Lon, Dist float64
-}
-
-type PolyLine struct {
-	Points []LinePoint
-	Color  color.RGBA
-	Width  float32
-}
-
-type PolyLineStyle struct {
-	Color string
-	Width float32
-}
-
-type IconStyleData struct {
-	ID      string
-	Color   string
-	Scale   float64
-	Href    string
-	HotSpot HotSpot
-}
-
-type PolyPoint struct {
-	Lat, Lon float64
-}
-
-type PolygonObject struct {
-	Points []PolyPoint
-}
-
 type Game struct {
-	ScreenWidth    int
-	ScreenHeight   int
-	basemap        string
+	*editor.Editor
 	TextBoxText    string
-	LastCmdText    string
-	Points         []PointObject
-	Line           PolyLine
-	Lines          []PolyLine
-	PolygonObject  PolygonObject
-	Polygons       []PolygonObject
-	StyleMap       map[string]map[string]string
-	Styles         map[string]PolyLineStyle
-	IconStyles     map[string]IconStyleData
-	IconImages     map[string]*ebiten.Image
-	PL_activated   bool
-	PO_activated   bool
-	POL_activated  bool
-	centerLat      float64
-	centerLon      float64
-	zoom           int
-	tileCache      TileImageCache
-	panning        bool
-	previousMouseX int
-	previousMouseY int
-	panStartMouseX int
-	panStartMouseY int
-	panStartLat    float64
-	panStartLon    float64
-	gps            *GPS
+	input          input
+	tileCache      *TileImageCache
+	tileScheduler  *TileScheduler
+	hover          hoverState // Feature under the mouse drawn highlighted
+	icons          map[string]*ebiten.Image
+	commandHistory editor.CommandHistory
 	numSegments    int
 	emptyTile      *ebiten.Image
 	offscreenImage *ebiten.Image
 	needRedraw     bool
+	drawnView      model.Viewport // View and basemap of the off-screen image
+	drawnBasemap   string
+}
+
+// input is the state of zooming and panning with the mouse, wheel and touch
+type input struct {
+	targetZoom         float64
+	zoomAnchorX        int
+	zoomAnchorY        int
+	touchIDs           []ebiten.TouchID
+	pinchStartDistance float64
+	pinchStartZoom     float64
+	panning            bool
+	previousMouseX     int
+	previousMouseY     int
+	panStartMouseX     int
+	panStartMouseY     int
+	panStartLat        float64
+	panStartLon        float64
 }
 
 func Initialize() (*Game, error) {
-	g := &Game{}
-	g.centerLat = 35.156072
-	g.centerLon = -90.051911
-	g.zoom = 5
-	g.basemap = GOOGLEAERIAL
+	g := &Game{Editor: editor.New()}
+	g.input.targetZoom = g.View.Zoom
+	g.icons = make(map[string]*ebiten.Image)
 
-	g.Line.Color = color.RGBA{0, 255, 255, 255}
-	g.Line.Width = 3.0
-	g.StyleMap = make(map[string]map[string]string)
-	g.Styles = make(map[string]PolyLineStyle)
-	g.IconStyles = make(map[string]IconStyleData)
+	// Redraw whenever the features change
+	g.Doc.OnChange(func() {
+		g.needRedraw = true
+		g.hover.valid = false
+	})
 
-	g.tileCache = NewTileImageCache()
+	g.tileCache = NewTileImageCache(DefaultTileCacheMaxTiles, DefaultTileCacheMaxBytes)
+	g.tileScheduler = NewTileScheduler(g.tileCache)
 
 	g.emptyTile = ebiten.NewImage(256, 256)
 	solidColor := color.RGBA{R: 0, G: 0, B: 0, A: 255}
 	g.emptyTile.Fill(solidColor)
 
-	g.gps = NewGPS()
-
-	g.ScreenWidth = 1024
-	g.ScreenHeight = 768
-	g.offscreenImage = ebiten.NewImage(g.ScreenWidth, g.ScreenHeight)
+	g.View.Width = 1024
+	g.View.Height = 768
+	g.offscreenImage = ebiten.NewImage(g.View.Width, g.View.Height)
 	g.needRedraw = true
 
 	// For polygon drawing
@@ -121,115 +80,60 @@ func Initialize() (*Game, error) {
 	return g, nil
 }
 
+func (g *Game) viewport() model.Viewport {
+	return g.View
+}
+
 func (g *Game) Update() error {
+	// Free tiles evicted from the cache since the last frame
+	g.tileCache.DisposeEvicted()
+
+	view := g.viewport()
+
+	// Add the latest GPS fix to the track being recorded and any point being averaged
+	g.UpdateGPS()
+
 	if droppedFiles := ebiten.DroppedFiles(); droppedFiles != nil {
-		err := LoadKMLDroppedFiles(droppedFiles, g)
+		err := model.LoadKMLDroppedFiles(droppedFiles, g.Doc)
 		if err != nil {
-			log.Println(err)
+			g.PrintError(err)
 		}
-		g.needRedraw = true
 	}
 
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.PL_activated {
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && (g.Drawing() || g.Measuring()) {
 		mouseX, mouseY := ebiten.CursorPosition()
-		lat, lon := screenCoordsToLatLng(mouseX, mouseY, g)
-		dist := 0.0
-		if len(g.Line.Points) > 0 {
-			prevPoint := len(g.Line.Points) - 1
-			dist = haversine(g.Line.Points[prevPoint].Lat, g.Line.Points[prevPoint].Lon, lat, lon, EarthRadiusFT)
-
-		}
-		g.Line.Points = append(g.Line.Points, LinePoint{Lat: lat, Lon: lon, Dist: dist})
-	} else if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.PO_activated {
-		mouseX, mouseY := ebiten.CursorPosition()
-		lat, lon := screenCoordsToLatLng(mouseX, mouseY, g)
-		clr := color.RGBA{255, 255, 255, 255}
-		g.Points = append(g.Points, PointObject{Lat: lat, Lon: lon, Color: clr, Scale: 1.0, IconImage: nil})
-		g.needRedraw = true
-	} else if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && g.POL_activated {
-		mouseX, mouseY := ebiten.CursorPosition()
-		lat, lon := screenCoordsToLatLng(mouseX, mouseY, g)
-		g.PolygonObject.Points = append(g.PolygonObject.Points, PolyPoint{Lat: lat, Lon: lon})
-		g.needRedraw = true
+		lat, lon := g.CursorLatLng(view, mouseX, mouseY)
+		g.RecordPoint(lat, lon)
+		g.AddDrawingPoint(lat, lon)
 	}
 
-	if inpututil.IsKeyJustReleased(ebiten.KeySpace) || inpututil.IsKeyJustReleased(ebiten.KeyEnter) {
-		if g.PL_activated { // Save new line
-			g.PL_activated = false
-			if len(g.Line.Points) > 0 {
-				g.Lines = append(g.Lines, g.Line)
-				g.Line.Points = nil
-			}
-		} else if g.POL_activated { // Save new polygon
-			g.POL_activated = false
-			if len(g.PolygonObject.Points) > 2 {
-				g.Polygons = append(g.Polygons, g.PolygonObject)
-				g.PolygonObject.Points = nil
-			}
-		} else if g.TextBoxText == "PL" || g.TextBoxText == "" && g.LastCmdText == "PL" { // Start new line
-			g.PL_activated = true
-			g.LastCmdText = "PL"
-		} else if g.PO_activated { // End point mode
-			g.PO_activated = false
-		} else if g.TextBoxText == "PO" || g.TextBoxText == "" && g.LastCmdText == "PO" { // Start new point
-			g.PO_activated = true
-			g.LastCmdText = "PO"
-		} else if g.TextBoxText == "POL" || g.TextBoxText == "" && g.LastCmdText == "POL" { // Start new point
-			g.POL_activated = true
-			g.LastCmdText = "POL"
-		} else if g.TextBoxText == "STARTGPS" {
-			if !g.gps.running {
-				g.gps.StartGPS() // Call StartGPS on the GPS instance
-			}
-			g.TextBoxText = ""
-		} else if g.TextBoxText == "STOPGPS" {
-			if g.gps.running {
-				g.gps.StopGPS() // Call StopGPS on the GPS instance
-			}
-			g.TextBoxText = ""
-		} else if g.TextBoxText == "GOOGLEHYBRID" {
-			g.basemap = GOOGLEHYBRID
-			g.tileCache = NewTileImageCache()
-		} else if g.TextBoxText == "GOOGLEAERIAL" {
-			g.basemap = GOOGLEAERIAL
-			g.tileCache = NewTileImageCache()
-		} else if g.TextBoxText == "BINGHYBRID" {
-			g.basemap = BINGHYBRID
-			g.tileCache = NewTileImageCache()
-		} else if g.TextBoxText == "BINGAERIAL" {
-			g.basemap = BINGAERIAL
-			g.tileCache = NewTileImageCache()
-		} else if g.TextBoxText == "OSM" {
-			g.basemap = OSM
-			g.tileCache = NewTileImageCache()
-		} else if g.TextBoxText == "MAPIMPORT" {
-			//homeDir, _ := os.UserHomeDir()
-			//LoadKMLFile(filepath.Join(homeDir, "test.kml"), g)
-			clipboardContent, err := clipboard.ReadAll()
-			if err != nil {
-				fmt.Printf("Error reading clipboard: %v\n", err)
-			}
-
-			LoadKMLFile(clipboardContent, g)
-		}
-		g.TextBoxText = ""
+	// Paste a list of coordinates into the active drawing or measurement
+	if (g.Drawing() || g.Measuring()) && (ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)) && inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		g.PasteCoordinates()
+	}
 
-		g.needRedraw = true
+	// Enter executes the command line, as does space unless it separates command arguments
+	if inpututil.IsKeyJustReleased(ebiten.KeyEnter) || inpututil.IsKeyJustReleased(ebiten.KeySpace) && !editor.SpaceSeparatesArgs(g.TextBoxText) {
+		g.submitCommandLine()
 	} else {
 		g.handleTextInput()
 	}
 
 	// Determine if line segment is clicked
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && !g.PL_activated && !g.PO_activated && !g.POL_activated {
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && !g.Drawing() && !g.Measuring() && !g.panned() {
 		mouseX, mouseY := ebiten.CursorPosition()
-		//lat, lon := screenCoordsToLatLng(mouseX, mouseY, g)
+		//lat, lon := view.ScreenToLatLng(float64(mouseX), float64(mouseY))
 
 		threshold := 5.0 // Pixels
 
 		// Iterate through each PointObject
-		for index, point := range g.Points {
+		for index, point := range g.Doc.Points {
+			if !g.Doc.Visible(point.Layer) {
+				continue
+			}
+
 			// Convert the point's lat/lon to screen coordinates
-			pointX, pointY := latLngToScreenCoords(point.Lat, point.Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
+			pointX, pointY := view.LatLngToScreen(point.Lat, point.Lon)
 
 			// Calculate the distance from the click to the point
 			dx := float32(mouseX) - pointX
@@ -239,202 +143,224 @@ func (g *Game) Update() error {
 			// Check if the distance is within the selection radius
 			if distance <= threshold {
 				fmt.Printf("Point %d selected\n", index)
+				if point.Name != "" || point.Symbol != "" {
+					g.PrintMessage("Point %d: %s", index, model.PointDescription(point))
+				}
+				if point.Type != "" {
+					g.PrintMessage("Point %d: %s %s", index, point.Type, editor.FormatAttributes(point.Attrs))
+				}
+				if capture := point.Capture; capture != nil {
+					g.PrintMessage("Point %d: GPS %s from %d fixes, accuracy %s, spread %s, HDOP %.1f", index, model.FixQualityName(capture.Quality),
+						capture.Fixes, g.Units.FormatDistance(capture.Accuracy), g.Units.FormatDistance(capture.Spread), capture.HDOP)
+				}
 			}
 		}
 
 		// Iterate through each segment in the PolyLine
-		for _, polyLine := range g.Lines {
+		lineClicked := false
+		for lineIndex, polyLine := range g.Doc.Lines {
+			if !g.Doc.Visible(polyLine.Layer) {
+				continue
+			}
 			for i := 0; i < len(polyLine.Points)-1; i++ {
 				// Convert the segment's start and end points from lat/lon to screen coordinates
-				startX, startY := latLngToScreenCoords(polyLine.Points[i].Lat, polyLine.Points[i].Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
-				endX, endY := latLngToScreenCoords(polyLine.Points[i+1].Lat, polyLine.Points[i+1].Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
+				startX, startY, endX, endY := view.SegmentToScreen(polyLine.Points[i].Lat, polyLine.Points[i].Lon, polyLine.Points[i+1].Lat, polyLine.Points[i+1].Lon)
 
 				// Calculate the distance from the mouse click to the current line segment
-				distance := pointLineSegmentDistance(float64(mouseX), float64(mouseY), float64(startX), float64(startY), float64(endX), float64(endY))
+				distance := model.PointLineSegmentDistance(float64(mouseX), float64(mouseY), float64(startX), float64(startY), float64(endX), float64(endY))
 
-				if distance <= threshold {
-					// The user clicked on a line segment
+				if distance <= threshold && !lineClicked {
+					// The user clicked on a line segment, report the length of the whole line
 					fmt.Printf("Clicked close to line segment between points %d and %d\n", i, i+1)
+					g.ReportLine(lineIndex)
+					lineClicked = true
 				}
 			}
 		}
-	}
-
-	// Zoomers...
-	_, scrollY := ebiten.Wheel()
-	scrollThreshold := 0.2
-	mouseX, mouseY := ebiten.CursorPosition()
 
-	if scrollY > scrollThreshold || scrollY < -scrollThreshold {
-		// Calculate the world coordinates before zooming
-		preZoomLat, preZoomLon := screenCoordsToLatLng(mouseX, mouseY, g)
-
-		if scrollY > scrollThreshold {
-			g.zoom++
-		} else if scrollY < -scrollThreshold {
-			g.zoom--
+		// Report the area of the topmost polygon under the click
+		if !lineClicked {
+			for index := len(g.Doc.Polygons) - 1; index >= 0; index-- {
+				polygon := g.Doc.Polygons[index]
+				if g.Doc.Visible(polygon.Layer) && len(polygon.Points) > 2 && pointInPolygon(float64(mouseX), float64(mouseY), polygonScreenPoints(view, polygon.Points)) {
+					g.ReportPolygon(index)
+					break
+				}
+			}
 		}
+	}
 
-		// Clamp the zoom level within a valid range (e.g., 0-21 for Google Maps)
-		g.zoom = int(math.Max(0, math.Min(21, float64(g.zoom))))
-
-		// Calculate the world coordinates after zooming
-		postZoomLat, postZoomLon := screenCoordsToLatLng(mouseX, mouseY, g)
-
-		// Adjust the center latitude and longitude to keep the world coordinates at the mouse position locked
-		g.centerLat += preZoomLat - postZoomLat
-		g.centerLon += preZoomLon - postZoomLon
+	// Toggle object snap
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		g.ToggleOsnap("")
+	}
 
-		g.needRedraw = true
+	// Toggle following the GPS
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		g.ToggleGPSFollow()
 	}
 
+	// Zoomers...
+	g.handleZoom()
+
 	// Panning
-	tileWidth := 360 / math.Pow(2, float64(g.zoom))
+	tileWidth := 360 / math.Pow(2, g.View.Zoom)
 	panSpeed := tileWidth * 0.5
 
 	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		g.centerLon -= panSpeed
-		g.needRedraw = true
+		g.View.CenterLon -= panSpeed
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		g.centerLon += panSpeed
-		g.needRedraw = true
+		g.View.CenterLon += panSpeed
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		g.centerLat += panSpeed
-		g.needRedraw = true
+	if ebiten.IsKeyPressed(ebiten.KeyUp) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		g.View.CenterLat += panSpeed
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		g.centerLat -= panSpeed
-		g.needRedraw = true
+	if ebiten.IsKeyPressed(ebiten.KeyDown) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		g.View.CenterLat -= panSpeed
 	}
 
 	// Panning with middle mouse button
-	mouseX, mouseY = ebiten.CursorPosition()
+	mouseX, mouseY := ebiten.CursorPosition()
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) || ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		if !g.panning {
-			g.panning = true
-			g.panStartMouseX, g.panStartMouseY = mouseX, mouseY
-			g.panStartLat, g.panStartLon = screenCoordsToLatLng(mouseX, mouseY, g)
+		view = g.viewport()
+		if !g.input.panning {
+			g.input.panning = true
+			g.input.panStartMouseX, g.input.panStartMouseY = mouseX, mouseY
+			g.input.panStartLat, g.input.panStartLon = view.ScreenToLatLng(float64(mouseX), float64(mouseY))
 		} else {
-			postZoomLat, postZoomLon := screenCoordsToLatLng(mouseX, mouseY, g)
-			g.centerLat += g.panStartLat - postZoomLat
-			g.centerLon += g.panStartLon - postZoomLon
+			// Keep the location grabbed at the start of the drag under the mouse
+			g.View.CenterLat, g.View.CenterLon = view.CenterForAnchor(g.input.panStartLat, g.input.panStartLon, float64(mouseX), float64(mouseY))
 		}
-		g.needRedraw = true
 	} else {
-		g.panning = false
+		g.input.panning = false
 	}
 
+	g.updateGPSFollow()
+
 	// Store previous mouse coordinates
-	g.previousMouseX, g.previousMouseY = mouseX, mouseY
+	g.input.previousMouseX, g.input.previousMouseY = mouseX, mouseY
 
-	// Clamp the coordinates to valid values
-	g.centerLat = math.Min(math.Max(g.centerLat, -85.05112878), 85.05112878)
-	g.centerLon = math.Min(math.Max(g.centerLon, -180), 180)
+	// Clamp the latitude to valid values and wrap the longitude around the world
+	g.View.CenterLat = math.Min(math.Max(g.View.CenterLat, -model.MaxLatitude), model.MaxLatitude)
+	g.View.CenterLon = model.WrapLongitude(g.View.CenterLon)
 
 	return nil
 }
 
+// panned reports whether the mouse moved far enough since the button went down
+// to count as dragging the map rather than clicking on it
+func (g *Game) panned() bool {
+	mouseX, mouseY := ebiten.CursorPosition()
+	return math.Hypot(float64(mouseX-g.input.panStartMouseX), float64(mouseY-g.input.panStartMouseY)) > 3
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
-	if g.needRedraw {
+	view := g.viewport()
+
+	// Redraw after the features change, the view moves or tiles are still loading
+	if g.needRedraw || view != g.drawnView || g.Basemap != g.drawnBasemap {
 		g.needRedraw = false // Reset the flag
+		g.drawnView = view
+		g.drawnBasemap = g.Basemap
 		g.offscreenImage.Clear()
 
 		// Calculate the center pixel coordinates of the game window
-		centerX := g.ScreenWidth / 2
-		centerY := g.ScreenHeight / 2
+		centerX := float64(g.View.Width) / 2
+		centerY := float64(g.View.Height) / 2
+
+		// Tiles come from the nearest whole zoom level, scaled to the fractional zoom
+		tileZoom := int(math.Round(g.View.Zoom))
+		tileScale := math.Pow(2, g.View.Zoom-float64(tileZoom))
+		scaledTileSize := model.TileSize * tileScale
 
 		// Get the tile coordinates and pixel coordinates of the center point
-		tileX, tileY := latLngToTile(g.centerLat, g.centerLon, g.zoom)
-		pixelX, pixelY := latLngToTilePixel(g.centerLat, g.centerLon, g.zoom)
+		pixelX, pixelY := latLngToWorldPixel(g.View.CenterLat, g.View.CenterLon, tileZoom)
+		tileX := int(math.Floor(pixelX / model.TileSize))
+		tileY := int(math.Floor(pixelY / model.TileSize))
 
 		// Calculate the tile offset to center the pixel coordinates in the game window
-		tileOffsetX := centerX - pixelX
-		tileOffsetY := centerY - pixelY
+		tileOffsetX := centerX - (pixelX-float64(tileX*model.TileSize))*tileScale
+		tileOffsetY := centerY - (pixelY-float64(tileY*model.TileSize))*tileScale
 
 		// Calculate the number of tiles needed to cover the window horizontally and vertically
-		numHorizontalTiles := int(math.Ceil(float64(g.ScreenWidth)/256)) + 2
-		numVerticalTiles := int(math.Ceil(float64(g.ScreenHeight)/256)) + 2
+		numHorizontalTiles := int(math.Ceil(float64(g.View.Width)/scaledTileSize)) + 2
+		numVerticalTiles := int(math.Ceil(float64(g.View.Height)/scaledTileSize)) + 2
 
 		// Calculate the starting tile coordinates based on the center tile
+		numTiles := 1 << tileZoom
 		startTileX := tileX - numHorizontalTiles/2
 		startTileY := tileY - numVerticalTiles/2
 
 		// Draw the tiles within the window
+		g.tileScheduler.BeginFrame()
 		for i := 0; i < numHorizontalTiles; i++ {
 			for j := 0; j < numVerticalTiles; j++ {
 				op := &ebiten.DrawImageOptions{}
-				tileOffsetXForTile := tileOffsetX + ((i - numHorizontalTiles/2) * 256)
-				tileOffsetYForTile := tileOffsetY + ((j - numVerticalTiles/2) * 256)
-				op.GeoM.Translate(float64(tileOffsetXForTile), float64(tileOffsetYForTile))
-				if drawTile(g.offscreenImage, g.emptyTile, &g.tileCache, startTileX+i, startTileY+j, g.zoom, g.basemap, op) {
+
+				// Snap both edges to whole pixels and stretch the tile between them, so
+				// neighbouring tiles share an edge without gaps or overlaps
+				x0 := math.Floor(tileOffsetX + float64(i-numHorizontalTiles/2)*scaledTileSize)
+				x1 := math.Floor(tileOffsetX + float64(i+1-numHorizontalTiles/2)*scaledTileSize)
+				y0 := math.Floor(tileOffsetY + float64(j-numVerticalTiles/2)*scaledTileSize)
+				y1 := math.Floor(tileOffsetY + float64(j+1-numVerticalTiles/2)*scaledTileSize)
+				scaleX, scaleY := (x1-x0)/model.TileSize, (y1-y0)/model.TileSize
+				op.GeoM.Scale(scaleX, scaleY)
+				op.GeoM.Translate(x0, y0)
+				if scaleX != 1 || scaleY != 1 {
+					op.Filter = ebiten.FilterLinear
+				}
+
+				// Rows past the poles have no tiles, columns wrap around the world
+				tileRow := startTileY + j
+				if tileRow < 0 || tileRow >= numTiles {
+					continue
+				}
+				tileColumn := wrapTileIndex(startTileX+i, numTiles)
+
+				// Tiles nearest the center of the view are downloaded first
+				priority := math.Hypot(float64(i-numHorizontalTiles/2), float64(j-numVerticalTiles/2))
+				if drawTile(g.offscreenImage, g.emptyTile, g.tileCache, g.tileScheduler, tileColumn, tileRow, tileZoom, g.Basemap, priority, op) {
 					g.needRedraw = true
 				}
 			}
 		}
+		g.tileScheduler.EndFrame()
 
 		// Draw Lines
 		g.numSegments = 0
-		for _, line := range g.Lines {
+		for _, line := range g.Doc.Lines {
 			numPoints := len(line.Points)
 			g.numSegments += numPoints - 1
-			if numPoints > 0 {
+			if numPoints > 0 && g.Doc.Visible(line.Layer) {
 				for i, j := 0, 1; j < numPoints; i, j = i+1, j+1 {
 					//label := fmt.Sprintf("%.0f'", line.Points[j].Dist)
-					//textDashedLine(screen, line.Points[i].Lat, line.Points[i].Lon, line.Points[j].Lat, line.Points[j].Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight, dashLength, gapLength, line.Width, line.Color, "144F", label)
-					solidLine(g.offscreenImage, line.Points[i].Lat, line.Points[i].Lon, line.Points[j].Lat, line.Points[j].Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight, line.Width, line.Color)
+					//textDashedLine(screen, view, line.Points[i].Lat, line.Points[i].Lon, line.Points[j].Lat, line.Points[j].Lon, dashLength, gapLength, line.Width, line.Color, "144F", label)
+					solidLine(g.offscreenImage, view, line.Points[i].Lat, line.Points[i].Lon, line.Points[j].Lat, line.Points[j].Lon, line.Width, line.Color)
 				}
 			}
 		}
 
 		// Draw point objects
-		if len(g.Points) > 0 {
-			for _, point := range g.Points {
-				pointX, pointY := latLngToScreenCoords(point.Lat, point.Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
+		if len(g.Doc.Points) > 0 {
+			for _, point := range g.Doc.Points {
+				if !g.Doc.Visible(point.Layer) {
+					continue
+				}
+				pointX, pointY := view.LatLngToScreen(point.Lat, point.Lon)
 
 				// Check if the point is within the screen bounds
-				if pointX >= 0 && pointX <= float32(g.ScreenWidth) && pointY >= 0 && pointY <= float32(g.ScreenHeight) {
-					if point.IconImage != nil {
-						// Draw the icon with the hotspot offset from the bottom-left corner
-						op := &ebiten.DrawImageOptions{}
-
-						// Calculate the offset based on hotspot values
-						//offsetX := float32(point.HotSpot.X * float64(point.IconImage.Bounds().Dx()))
-						//offsetY := float32(point.HotSpot.Y * float64(point.IconImage.Bounds().Dy()))
-
-						// If hotspot x and y are both 0, center the icon on pointX and pointY
-						if point.HotSpot.X == 0 && point.HotSpot.Y == 0 {
-							centerX := float32(point.IconImage.Bounds().Dx()) / 2
-							centerY := float32(point.IconImage.Bounds().Dy()) / 2
-							op.GeoM.Translate(float64(pointX-centerX), float64(pointY-centerY))
-						} else {
-							// Apply the hotspot offset
-							op.GeoM.Translate(float64(pointX)-point.HotSpot.X, float64(pointY)-float64(point.IconImage.Bounds().Dy())+point.HotSpot.Y)
-						}
-
-						g.offscreenImage.DrawImage(point.IconImage, op)
-					} else {
-						// Draw a circle if there's no icon
-						pointRadius := 5.0
-						pointColor := point.Color
-
-						vector.DrawFilledCircle(g.offscreenImage, pointX, pointY, float32(pointRadius), pointColor, false)
-						vector.StrokeCircle(g.offscreenImage, pointX, pointY, float32(pointRadius), 2, color.RGBA{0, 0, 0, 255}, false)
-					}
+				if pointX >= 0 && pointX <= float32(g.View.Width) && pointY >= 0 && pointY <= float32(g.View.Height) {
+					drawPointObject(g.offscreenImage, pointX, pointY, point, g.pointIcon(point))
 				}
 			}
 		}
 
-		// Loop through all polygons in g.Polygons and render them
-		for _, polygon := range g.Polygons {
-			if len(polygon.Points) > 2 {
-				screenPoints := make([]struct{ x, y float64 }, len(polygon.Points))
-				for i, pt := range polygon.Points {
-					x32, y32 := latLngToScreenCoords(pt.Lat, pt.Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
-					screenPoints[i] = struct{ x, y float64 }{float64(x32), float64(y32)}
-				}
-				drawFilledPolygon(g.offscreenImage, screenPoints, color.RGBA{0x00, 0xff, 0x00, 0x4D}) // Green filled polygon
+		// Loop through all polygons in g.Doc.Polygons and render them
+		for _, polygon := range g.Doc.Polygons {
+			if g.Doc.Visible(polygon.Layer) && len(polygon.Points) > 2 {
+				screenPoints := polygonScreenPoints(view, polygon.Points)
+				drawFilledPolygon(g.offscreenImage, screenPoints, polygon.Style)
 			}
 		}
 	}
@@ -442,17 +368,18 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// Draw the off-screen image to the screen
 	screen.DrawImage(g.offscreenImage, nil)
 
+	// Location under the cursor, snapped to existing geometry while drawing
+	mouseX, mouseY := ebiten.CursorPosition()
+	cursorLat, cursorLon := g.CursorLatLng(view, mouseX, mouseY)
+
+	// Draw the feature under the mouse with its highlight style
+	g.drawHighlight(screen, view, mouseX, mouseY)
+
 	// Draw currently active polygon
 	if g.POL_activated && len(g.PolygonObject.Points) > 0 {
-		screenPoints := make([]struct{ x, y float64 }, len(g.PolygonObject.Points))
-		for i, pt := range g.PolygonObject.Points {
-			x32, y32 := latLngToScreenCoords(pt.Lat, pt.Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
-			screenPoints[i] = struct{ x, y float64 }{float64(x32), float64(y32)}
-		}
+		screenPoints := polygonScreenPoints(view, g.PolygonObject.Points)
 
-		mouseX, mouseY := ebiten.CursorPosition()
-		screenX, screenY := screenCoordsToLatLng(mouseX, mouseY, g)
-		x32, y32 := latLngToScreenCoords(screenX, screenY, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
+		x32, y32 := view.LatLngToScreen(cursorLat, cursorLon)
 
 		// Check if the mouse coordinates are the same as the last point
 		lastPoint := screenPoints[len(screenPoints)-1]
@@ -462,7 +389,7 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 
 		if len(screenPoints) > 2 {
-			drawFilledPolygon(screen, screenPoints, color.RGBA{0x00, 0xff, 0x00, 0x4D}) // Green filled polygon
+			drawFilledPolygon(screen, screenPoints, g.PolygonObject.Style)
 		} else {
 			// Draw a line from the first point to the mouse cursor
 			vector.StrokeLine(screen, float32(screenPoints[0].x), float32(screenPoints[0].y), float32(x32), float32(y32), 2, color.RGBA{0x00, 0x00, 0x00, 0xff}, false)
@@ -474,23 +401,21 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	numPoints := len(g.Line.Points)
 	if numPoints > 0 {
 		for i, j := 0, 1; j < numPoints; i, j = i+1, j+1 {
-			label := fmt.Sprintf("%.0f'", g.Line.Points[j].Dist)
-			textDashedLine(screen, g.Line.Points[i].Lat, g.Line.Points[i].Lon, g.Line.Points[j].Lat, g.Line.Points[j].Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight, dashLength, gapLength, g.Line.Width, g.Line.Color, "144F", label)
+			label := g.Units.FormatDistance(g.Line.Points[j].Dist)
+			textDashedLine(screen, view, g.Line.Points[i].Lat, g.Line.Points[i].Lon, g.Line.Points[j].Lat, g.Line.Points[j].Lon, dashLength, gapLength, g.Line.Width, g.Line.Color, "144F", label)
 		}
-		mouseX, mouseY := ebiten.CursorPosition()
-		screenX, screenY := screenCoordsToLatLng(mouseX, mouseY, g)
-		dist := haversine(g.Line.Points[numPoints-1].Lat, g.Line.Points[numPoints-1].Lon, screenX, screenY, EarthRadiusFT)
-		label := fmt.Sprintf("%.0f'", dist)
-		textDashedLine(screen, g.Line.Points[numPoints-1].Lat, g.Line.Points[numPoints-1].Lon, screenX, screenY, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight, dashLength, gapLength, g.Line.Width, g.Line.Color, "144F", label)
+		dist := model.GeodesicDistance(g.Line.Points[numPoints-1].Lat, g.Line.Points[numPoints-1].Lon, cursorLat, cursorLon)
+		label := g.Units.FormatDistance(dist)
+		textDashedLine(screen, view, g.Line.Points[numPoints-1].Lat, g.Line.Points[numPoints-1].Lon, cursorLat, cursorLon, dashLength, gapLength, g.Line.Width, g.Line.Color, "144F", label)
 	}
 
 	/*// Draw point objects
-	if len(g.Points) > 0 {
-		for _, point := range g.Points {
-			pointX, pointY := latLngToScreenCoords(point.Lat, point.Lon, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
+	if len(g.Doc.Points) > 0 {
+		for _, point := range g.Doc.Points {
+			pointX, pointY := view.LatLngToScreen(point.Lat, point.Lon)
 
 			// Check if the point is within the screen bounds
-			if pointX >= 0 && pointX <= float32(g.ScreenWidth) && pointY >= 0 && pointY <= float32(g.ScreenHeight) {
+			if pointX >= 0 && pointX <= float32(g.View.Width) && pointY >= 0 && pointY <= float32(g.View.Height) {
 				pointRadius := 5.0
 				pointColor := point.Color
 
@@ -504,64 +429,98 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// GEOTIFF
 	//g.DrawGeoTiff(screen)
 
-	// Draw the current GPS position
-	if g.gps.running {
-		gpsX, gpsY := latLngToScreenCoords(g.gps.latitude, g.gps.longitude, g.centerLat, g.centerLon, float64(g.zoom), g.ScreenWidth, g.ScreenHeight)
-		gpsCircleRadius := 10.0 * g.gps.HDOP
-		gpsCircleColor := color.RGBA{0, 0, 255, 179}
-
-		vector.DrawFilledCircle(screen, gpsX, gpsY, float32(gpsCircleRadius), gpsCircleColor, false)
+	// Draw the GPS track being recorded under the position
+	if g.Track != nil && g.Track.Recording() {
+		g.drawTrack(screen, view)
 	}
 
-	g.DrawTextbox(screen, g.ScreenWidth, g.ScreenHeight)
+	// Draw the current GPS position and status, with measurement results below
+	// the panel. The panel stays up with the error after the GPS disconnects.
+	resultsTop := 10
+	if g.GPS.Running() || g.GPS.Err() != nil {
+		fix := g.GPS.Fix()
+		g.drawGPSPosition(screen, view, fix)
+		resultsTop = g.drawGPSPanel(screen, fix) + 10
+	}
 
-	// Get the current mouse position
-	mouseX, mouseY := ebiten.CursorPosition()
+	g.DrawTextbox(screen, g.View.Width, g.View.Height)
 
 	if g.PO_activated {
-		pointRadius := 5.0
-		pointColor := color.RGBA{128, 128, 128, 26}
+		if symbolImage, err := g.pointSymbolImage(); symbolImage != nil && err == nil {
+			// Preview the symbol under the cursor
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(float64(mouseX-symbolImage.Bounds().Dx()/2), float64(mouseY-symbolImage.Bounds().Dy()/2))
+			op.ColorScale.ScaleAlpha(0.5)
+			screen.DrawImage(symbolImage, op)
+		} else {
+			pointRadius := 5.0
+			pointColor := color.RGBA{128, 128, 128, 26}
 
-		vector.DrawFilledCircle(screen, float32(mouseX), float32(mouseY), float32(pointRadius), pointColor, false)
+			vector.DrawFilledCircle(screen, float32(mouseX), float32(mouseY), float32(pointRadius), pointColor, false)
+		}
 	}
 
 	// Draw the crosshair at the mouse position
-	if g.PL_activated || g.PO_activated || g.POL_activated {
+	g.drawMeasurement(screen, view, cursorLat, cursorLon, mouseX, mouseY)
+	g.drawMeasureResults(screen, resultsTop)
+	g.drawSnapMarker(screen, view)
+
+	if g.Drawing() || g.Measuring() {
 		drawCrosshair(screen, float32(mouseX), float32(mouseY), 100, color.RGBA{255, 255, 255, 255})
 	} else {
 		drawSquareCrosshair(screen, float32(mouseX), float32(mouseY), 10, 100, color.RGBA{255, 255, 255, 255})
 	}
 
-	mouseX, mouseY = ebiten.CursorPosition()
-	lat, lon := screenCoordsToLatLng(mouseX, mouseY, g)
-	debugString := fmt.Sprintf("Zoom: %d, Coords: %f, %f\n%d Points, %d Lines (%d Segments)\n%d Styles, %d Style Maps\n%.0f FPS",
-		g.zoom, lat, lon, len(g.Points), len(g.Lines), g.numSegments, len(g.Styles), len(g.StyleMap), ebiten.ActualFPS())
+	coords := fmt.Sprintf("%f, %f", cursorLat, cursorLon)
+	if g.DisplayCRS != nil {
+		x, y := g.DisplayCRS.FromLatLng(cursorLat, cursorLon)
+		coords += fmt.Sprintf(" (%.3f, %.3f %s EPSG:%d)", x, y, g.DisplayCRS.Unit, g.DisplayCRS.Code)
+	}
+	cacheStats := g.tileCache.Stats()
+	schedulerStats := g.tileScheduler.Stats()
+	debugString := fmt.Sprintf("Zoom: %.2f, Coords: %s, OSNAP: %s\n%d Points, %d Lines (%d Segments)\n%d Styles, %d Style Maps\nTiles: %d (%.0f/%.0f MB), %d Hits, %d Misses, %d Evicted\nDownloads: %d Queued, %d In Flight, %d Failed\n%.0f FPS",
+		g.View.Zoom, coords, g.OsnapStatus(), len(g.Doc.Points), len(g.Doc.Lines), g.numSegments, len(g.Doc.Styles), len(g.Doc.StyleMap),
+		cacheStats.Tiles, float64(cacheStats.Bytes)/(1024*1024), float64(cacheStats.MaxBytes)/(1024*1024), cacheStats.Hits, cacheStats.Misses, cacheStats.Evictions,
+		schedulerStats.Pending, schedulerStats.InFlight, schedulerStats.Failed, ebiten.ActualFPS())
 	ebitenutil.DebugPrint(screen, debugString)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
-	if g.ScreenWidth != outsideWidth || g.ScreenHeight != outsideHeight {
+	if g.View.Width != outsideWidth || g.View.Height != outsideHeight {
 		fmt.Println("Resizing to", outsideWidth, outsideHeight)
 		// Recreate the off-screen image with the new dimensions
 		g.offscreenImage = ebiten.NewImage(outsideWidth, outsideHeight)
-
-		g.needRedraw = true
 	}
 
-	g.ScreenWidth = outsideWidth
-	g.ScreenHeight = outsideHeight
+	g.View.Width = outsideWidth
+	g.View.Height = outsideHeight
 	return outsideWidth, outsideHeight
 }
 
 func main() {
+	// Subcommands run without drawing anything, e.g. fiberforge report drops.kml
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	fiberforge, err := Initialize()
 	if err != nil {
 		log.Fatalf("Error initializing program: %v", err)
 	}
 
-	startWorkerPool(10)
+	// Any other arguments are files to open, e.g. fiberforge drawing.kml
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-psn_") { // macOS adds -psn_ when opened from Finder
+			continue
+		}
+		if err := model.LoadMapFile(arg, fiberforge.Doc); err != nil {
+			fiberforge.PrintError(err)
+		}
+	}
+
+	fiberforge.tileScheduler.Start(10)
 
-	ebiten.SetWindowSize(fiberforge.ScreenWidth, fiberforge.ScreenHeight)
+	ebiten.SetWindowSize(fiberforge.View.Width, fiberforge.View.Height)
 	ebiten.SetWindowTitle("CAD/GIS Experiment")
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
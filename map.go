@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -11,163 +12,139 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
 
+	"github.com/OpticalFlyer/FiberForge/editor"
+	"github.com/OpticalFlyer/FiberForge/model"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 const (
-	GOOGLEHYBRID = "GOOGLEHYBRID"
-	GOOGLEAERIAL = "GOOGLEAERIAL"
-	BINGHYBRID   = "BINGHYBRID"
-	BINGAERIAL   = "BINGAERIAL"
-	OSM          = "OSM"
+	MaxZoom     = 24 // Deepest zoom level, past every provider's native max zoom
+	maxOverzoom = 8  // Levels an ancestor tile can be scaled up before it's under a pixel
 )
 
-type TileImageCache struct {
-	cache map[int]map[int]map[int]*ebiten.Image
-	mu    sync.Mutex
-}
-
-func NewTileImageCache() TileImageCache {
-	return TileImageCache{
-		cache: make(map[int]map[int]map[int]*ebiten.Image),
-	}
-}
-
-type DownloadRequest struct {
-	tileCache *TileImageCache
-	zoom      int
-	tileX     int
-	tileY     int
-	basemap   string
-}
-
-var downloadQueue = make(chan DownloadRequest, 100)
-
-func startWorkerPool(numWorkers int) {
-	for i := 0; i < numWorkers; i++ {
-		go tileDownloader()
-	}
-}
-
-func tileDownloader() {
-	for req := range downloadQueue {
-		img, err := downloadTileImage(req.tileX, req.tileY, req.zoom, req.basemap)
-		if err == nil {
-			req.tileCache.Set(req.zoom, req.tileX, req.tileY, img)
-		}
+// basemapMaxZoom returns the deepest zoom level the provider serves tiles for
+func basemapMaxZoom(basemap string) int {
+	switch basemap {
+	case editor.GOOGLEAERIAL, editor.GOOGLEHYBRID:
+		return 21
+	default: // Bing and OSM
+		return 19
 	}
 }
 
-func (cache *TileImageCache) Set(zoom, x, y int, img *ebiten.Image) {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
-
-	if _, ok := cache.cache[zoom]; !ok {
-		cache.cache[zoom] = make(map[int]map[int]*ebiten.Image)
-	}
-	if _, ok := cache.cache[zoom][x]; !ok {
-		cache.cache[zoom][x] = make(map[int]*ebiten.Image)
-	}
-	cache.cache[zoom][x][y] = img
-}
-
-func (cache *TileImageCache) Get(zoom, x, y int) (*ebiten.Image, bool) {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
-
-	if _, ok := cache.cache[zoom]; !ok {
-		return nil, false
+func drawTile(screen *ebiten.Image, emptyTile *ebiten.Image, tileCache *TileImageCache, scheduler *TileScheduler, tileX, tileY, zoom int, basemap string, priority float64, op *ebiten.DrawImageOptions) bool {
+	// Past the provider's max zoom, overzoom the deepest tile it has
+	sourceZoom := zoom
+	if maxZoom := basemapMaxZoom(basemap); zoom > maxZoom {
+		sourceZoom = maxZoom
 	}
-	if _, ok := cache.cache[zoom][x]; !ok {
-		return nil, false
+	dz := zoom - sourceZoom
+	if dz > maxOverzoom {
+		screen.DrawImage(emptyTile, op)
+		return false
 	}
-	img, ok := cache.cache[zoom][x][y]
-	return img, ok
-}
 
-func drawTile(screen *ebiten.Image, emptyTile *ebiten.Image, tileCache *TileImageCache, tileX, tileY, zoom int, basemap string, op *ebiten.DrawImageOptions) bool {
-	cachedImg, ok := tileCache.Get(zoom, tileX, tileY)
+	key := TileKey{Basemap: basemap, Zoom: sourceZoom, X: tileX >> dz, Y: tileY >> dz}
+	cachedImg, ok := tileCache.Get(key)
 	if ok {
-		screen.DrawImage(cachedImg, op)
+		drawTileRegion(screen, cachedImg, tileX, tileY, dz, op)
 		return false
 	} else {
-		// Draw the empty tile
-		screen.DrawImage(emptyTile, op)
+		// Ask the scheduler for the tile, it ignores tiles already on their way
+		scheduler.Request(key, priority)
 
-		// Add a download request to the queue
-		downloadQueue <- DownloadRequest{
-			tileCache: tileCache,
-			zoom:      zoom,
-			tileX:     tileX,
-			tileY:     tileY,
-			basemap:   basemap,
+		// Keep the map readable while loading with a scaled crop of the nearest
+		// cached ancestor, or else a downsampled mosaic of the children
+		if drawAncestorTile(screen, tileCache, tileX, tileY, zoom, sourceZoom, basemap, op) {
+			return true
+		}
+		screen.DrawImage(emptyTile, op)
+		if dz == 0 {
+			drawChildTiles(screen, tileCache, tileX, tileY, zoom, basemap, op)
 		}
 		return true
 	}
 }
 
-func latLngToTile(lat, lng float64, zoom int) (int, int) {
-	latRad := lat * math.Pi / 180.0
-	n := math.Pow(2, float64(zoom))
-	xtile := int((lng + 180.0) / 360.0 * n)
-	ytile := int((1.0 - math.Log(math.Tan(latRad)+1.0/math.Cos(latRad))/math.Pi) / 2.0 * n)
-	return xtile, ytile
+// drawAncestorTile draws the part of the nearest cached ancestor covering the
+// tile, looking at most maxOverzoom levels above the tile's zoom. Fallbacks are
+// peeked so probing them doesn't count as misses or keep them from eviction.
+func drawAncestorTile(screen *ebiten.Image, tileCache *TileImageCache, tileX, tileY, zoom, sourceZoom int, basemap string, op *ebiten.DrawImageOptions) bool {
+	for ancestorZoom := sourceZoom - 1; ancestorZoom >= 0 && zoom-ancestorZoom <= maxOverzoom; ancestorZoom-- {
+		dz := zoom - ancestorZoom
+		key := TileKey{Basemap: basemap, Zoom: ancestorZoom, X: tileX >> dz, Y: tileY >> dz}
+		if img, ok := tileCache.Peek(key); ok {
+			drawTileRegion(screen, img, tileX, tileY, dz, op)
+			return true
+		}
+	}
+	return false
 }
 
-func latLngToTilePixel(lat, lng float64, zoom int) (int, int) {
-	// Calculate the pixel coordinates inside the tile
-	latRad := lat * math.Pi / 180.0
-	n := math.Pow(2, float64(zoom))
-	pixelX := int((lng+180.0)/360.0*n*256.0) % 256
-	pixelY := int((1.0-math.Log(math.Tan(latRad)+1.0/math.Cos(latRad))/math.Pi)/2.0*n*256.0) % 256
-
-	return pixelX, pixelY
+// drawChildTiles draws whichever of the four tiles one level deeper are cached,
+// scaled down into the tile's place
+func drawChildTiles(screen *ebiten.Image, tileCache *TileImageCache, tileX, tileY, zoom int, basemap string, op *ebiten.DrawImageOptions) bool {
+	drawn := false
+	for dx := 0; dx < 2; dx++ {
+		for dy := 0; dy < 2; dy++ {
+			key := TileKey{Basemap: basemap, Zoom: zoom + 1, X: tileX*2 + dx, Y: tileY*2 + dy}
+			img, ok := tileCache.Peek(key)
+			if !ok {
+				continue
+			}
+			childOp := &ebiten.DrawImageOptions{}
+			childOp.GeoM.Scale(0.5, 0.5)
+			childOp.GeoM.Translate(float64(dx*model.TileSize/2), float64(dy*model.TileSize/2))
+			childOp.GeoM.Concat(op.GeoM)
+			childOp.Filter = ebiten.FilterLinear
+			screen.DrawImage(img, childOp)
+			drawn = true
+		}
+	}
+	return drawn
 }
 
-func screenCoordsToLatLng(screenX, screenY int, game *Game) (float64, float64) {
-	centerLat := game.centerLat
-	centerLon := game.centerLon
-	zoom := float64(game.zoom)
-
-	tileSize := 256.0
-	scale := tileSize * math.Pow(2, zoom)
-
-	metersPerPixel := math.Cos(centerLat*math.Pi/180.0) * 2 * math.Pi * 6371000 / scale
-
-	offsetX := float64(screenX - game.ScreenWidth/2)
-	offsetY := float64(screenY - game.ScreenHeight/2)
-
-	deltaLon := offsetX * metersPerPixel / (6371000 * math.Cos(centerLat*math.Pi/180.0)) * 180 / math.Pi
-	deltaLat := -offsetY * metersPerPixel / 6371000 * 180 / math.Pi
-
-	clickedLat := centerLat + deltaLat
-	clickedLon := centerLon + deltaLon
+// drawTileRegion draws the 1/2^dz crop of an ancestor tile image that covers
+// tileX, tileY, scaled up to a full tile
+func drawTileRegion(screen *ebiten.Image, img *ebiten.Image, tileX, tileY, dz int, op *ebiten.DrawImageOptions) {
+	if dz == 0 {
+		screen.DrawImage(img, op)
+		return
+	}
 
-	return clickedLat, clickedLon
+	scale := 1 << dz
+	size := img.Bounds().Dx() / scale
+	if size < 1 {
+		size = 1
+	}
+	// Masking keeps the offset within the ancestor even for negative tile indices
+	srcX := (tileX & (scale - 1)) * size
+	srcY := (tileY & (scale - 1)) * size
+	region := img.SubImage(image.Rect(srcX, srcY, srcX+size, srcY+size)).(*ebiten.Image)
+
+	regionOp := &ebiten.DrawImageOptions{}
+	regionOp.GeoM.Scale(float64(model.TileSize)/float64(size), float64(model.TileSize)/float64(size))
+	regionOp.GeoM.Concat(op.GeoM)
+	regionOp.Filter = ebiten.FilterLinear
+	screen.DrawImage(region, regionOp)
 }
 
-func latLngToScreenCoords(lat, lng, centerLat, centerLon, zoom float64, screenWidth, screenHeight int) (float32, float32) {
-	// Web Mercator projection formulas
-	tileSize := 256.0
-	//scale := tileSize * math.Pow(2, zoom)
-	worldSize := tileSize * math.Pow(2, zoom)
-	origin := worldSize / 2
-
-	// Convert center lat/lon to pixel coordinates
-	centerX := origin + centerLon*math.Pi/180.0*origin/math.Pi
-	centerY := origin - math.Log(math.Tan((centerLat*math.Pi/180.0+math.Pi/2)/2))*origin/math.Pi
-
-	// Convert lat/lon to pixel coordinates
-	x := origin + lng*math.Pi/180.0*origin/math.Pi
-	y := origin - math.Log(math.Tan((lat*math.Pi/180.0+math.Pi/2)/2))*origin/math.Pi
-
-	// Calculate screen coordinates
-	screenX := int(x - centerX + float64(screenWidth)/2)
-	screenY := int(y - centerY + float64(screenHeight)/2)
+// wrapTileIndex wraps a tile column around the world, numTiles being 2^zoom
+func wrapTileIndex(index, numTiles int) int {
+	index %= numTiles
+	if index < 0 {
+		index += numTiles
+	}
+	return index
+}
 
-	return float32(screenX), float32(screenY)
+// latLngToWorldPixel returns the Web Mercator pixel coordinates of a location
+// across the whole world at a tile zoom level
+func latLngToWorldPixel(lat, lng float64, zoom int) (float64, float64) {
+	x, y := model.ProjectMercator(lat, lng)
+	worldSize := model.TileSize * math.Pow(2, float64(zoom))
+	return x * worldSize, y * worldSize
 }
 
 func buildTilePath(basemap string, zoom, x, y int) (string, error) {
@@ -176,7 +153,7 @@ func buildTilePath(basemap string, zoom, x, y int) (string, error) {
 		return "", err
 	}
 	var fileExtension string
-	if basemap == OSM {
+	if basemap == editor.OSM {
 		fileExtension = "png"
 	} else {
 		fileExtension = "jpg"
@@ -201,7 +178,7 @@ func saveTileToDisk(tilePath string, data []byte) error {
 	return nil
 }
 
-func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
+func downloadTileImage(ctx context.Context, x, y, zoom int, basemap string) (*ebiten.Image, error) {
 	tilePath, err := buildTilePath(basemap, zoom, x, y)
 	if err != nil {
 		fmt.Printf("Failed to build tile path: %s\n", err)
@@ -215,7 +192,7 @@ func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
 		}
 		// Depending on the basemap, decode the image accordingly
 		var img image.Image
-		if basemap == OSM {
+		if basemap == editor.OSM {
 			img, err = png.Decode(bytes.NewReader(fileData))
 		} else {
 			img, err = jpeg.Decode(bytes.NewReader(fileData))
@@ -227,15 +204,15 @@ func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
 	}
 
 	var url string
-	if basemap == BINGHYBRID {
+	if basemap == editor.BINGHYBRID {
 		q := getQuadKey(zoom, x, y)
 		url = fmt.Sprintf("http://ecn.t1.tiles.virtualearth.net/tiles/h%s.jpeg?g=129&mkt=en-US&shading=hill&stl=H", q)
-	} else if basemap == BINGAERIAL {
+	} else if basemap == editor.BINGAERIAL {
 		q := getQuadKey(zoom, x, y)
 		url = fmt.Sprintf("http://ecn.t1.tiles.virtualearth.net/tiles/a%s.jpeg?g=129&mkt=en-US&shading=hill&stl=H", q)
-	} else if basemap == GOOGLEAERIAL {
+	} else if basemap == editor.GOOGLEAERIAL {
 		url = fmt.Sprintf("https://mt1.google.com/vt/lyrs=s&x=%d&y=%d&z=%d", x, y, zoom)
-	} else if basemap == GOOGLEHYBRID {
+	} else if basemap == editor.GOOGLEHYBRID {
 		url = fmt.Sprintf("https://mt1.google.com/vt/lyrs=s,h&x=%d&y=%d&z=%d", x, y, zoom)
 	} else {
 		url = fmt.Sprintf("https://tile.openstreetmap.org/%d/%d/%d.png", zoom, x, y)
@@ -248,7 +225,7 @@ func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
 	defer resp.Body.Close()*/
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +248,7 @@ func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
 	}
 
 	var img image.Image
-	if basemap == OSM {
+	if basemap == editor.OSM {
 		img, err = png.Decode(bytes.NewReader(data))
 	} else {
 		img, err = jpeg.Decode(bytes.NewReader(data))
@@ -287,25 +264,6 @@ func downloadTileImage(x, y, zoom int, basemap string) (*ebiten.Image, error) {
 	return ebiten.NewImageFromImage(img), nil
 }
 
-func pointLineSegmentDistance(x, y, x1, y1, x2, y2 float64) float64 {
-	// Calculate the squared length of the line segment
-	l2 := math.Pow(x2-x1, 2) + math.Pow(y2-y1, 2)
-	if l2 == 0 {
-		return math.Sqrt(math.Pow(x-x1, 2) + math.Pow(y-y1, 2))
-	}
-
-	// Calculate the projection of the point onto the line segment
-	t := ((x-x1)*(x2-x1) + (y-y1)*(y2-y1)) / l2
-	t = math.Max(0, math.Min(1, t))
-
-	// Calculate the projection point
-	projX := x1 + t*(x2-x1)
-	projY := y1 + t*(y2-y1)
-
-	// Calculate the distance from the point to the projection point
-	return math.Sqrt(math.Pow(x-projX, 2) + math.Pow(y-projY, 2))
-}
-
 func getQuadKey(zoom, tileX, tileY int) string {
 	var quadKey string
 	for i := zoom; i > 0; i-- {
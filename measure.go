@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var measureColor = color.RGBA{255, 255, 0, 255}
+
+// pointInPolygon reports whether a screen position falls inside a polygon ring
+func pointInPolygon(x, y float64, points []struct{ x, y float64 }) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		if (points[i].y > y) != (points[j].y > y) &&
+			x < (points[j].x-points[i].x)*(y-points[i].y)/(points[j].y-points[i].y)+points[i].x {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// drawMeasurement draws the measurement in progress up to the cursor along
+// with a live readout next to the cursor
+func (g *Game) drawMeasurement(screen *ebiten.Image, view model.Viewport, cursorLat, cursorLon float64, mouseX, mouseY int) {
+	if !g.Measuring() || len(g.MeasurePoints) == 0 {
+		return
+	}
+
+	points := append(append([]model.PolyPoint{}, g.MeasurePoints...), model.PolyPoint{Lat: cursorLat, Lon: cursorLon})
+
+	if g.AREA_activated && len(points) > 2 {
+		style := model.DefaultPolygonStyle()
+		style.FillColor = measureColor
+		style.FillColor.A = 0x4D
+		drawFilledPolygon(screen, polygonScreenPoints(view, points), style)
+	} else {
+		for i := 1; i < len(points); i++ {
+			x0, y0, x1, y1 := view.SegmentToScreen(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+			vector.StrokeLine(screen, x0, y0, x1, y1, 2, measureColor, false)
+		}
+	}
+
+	if text, ok := g.MeasureText(points); ok {
+		ebitenutil.DebugPrintAt(screen, text, mouseX+15, mouseY+15)
+	}
+}
+
+// drawMeasureResults lists the most recent results in the top right corner,
+// starting at top so they stay clear of the GPS panel
+func (g *Game) drawMeasureResults(screen *ebiten.Image, top int) {
+	for i, text := range g.MeasureResults {
+		// Debug text is 6 pixels per character and 16 pixels per line
+		x := g.View.Width - len(text)*6 - 10
+		y := top + i*16
+		ebitenutil.DebugPrintAt(screen, text, x, y)
+	}
+}
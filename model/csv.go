@@ -0,0 +1,165 @@
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadCSVFile loads a CSV file onto a layer named after the file
+func LoadCSVFile(filename string, doc *Document) error {
+	csvData, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	doc.importLayer = fileLayer(filename)
+	defer func() { doc.importLayer = "" }()
+	return LoadCSV(csvData, doc)
+}
+
+// LoadCSV adds the features of a CSV file laid out like ExportCSV writes it,
+// one vertex per row under a feature, id, vertex, x, y, crs header. Only x and
+// y are required, rows without a feature column are points. Coordinates are in
+// the row's crs, else in doc.ImportCRS, else lon/lat, so survey points in State
+// Plane or UTM can be loaded as they come.
+func LoadCSV(csvData []byte, doc *Document) error {
+	reader := csv.NewReader(bytes.NewReader(csvData))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("empty CSV file")
+	}
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	xColumn, hasX := columns["x"]
+	yColumn, hasY := columns["y"]
+	if !hasX || !hasY {
+		return fmt.Errorf("CSV header has no x and y columns")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	// Vertices are grouped by feature and id, keeping the order features first
+	// appear in
+	type csvFeature struct {
+		kind   string
+		points [][2]float64 // Lat, lon
+	}
+	var features []*csvFeature
+	byID := make(map[string]*csvFeature)
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if xColumn >= len(record) || yColumn >= len(record) {
+			return fmt.Errorf("row %d: missing x or y", row)
+		}
+
+		x, err := strconv.ParseFloat(strings.TrimSpace(record[xColumn]), 64)
+		if err != nil {
+			return fmt.Errorf("row %d: invalid x %q", row, record[xColumn])
+		}
+		y, err := strconv.ParseFloat(strings.TrimSpace(record[yColumn]), 64)
+		if err != nil {
+			return fmt.Errorf("row %d: invalid y %q", row, record[yColumn])
+		}
+
+		crs := doc.ImportCRS
+		if code := field(record, "crs"); code != "" {
+			if crs, err = ParseCRSArgument(code); err != nil {
+				return fmt.Errorf("row %d: %v", row, err)
+			}
+		}
+		lat, lon := y, x
+		if crs != nil {
+			lat, lon = crs.ToLatLng(x, y)
+		}
+
+		kind := strings.ToLower(field(record, "feature"))
+		switch kind {
+		case "":
+			kind = "point"
+		case "point", "line", "polygon":
+		default:
+			return fmt.Errorf("row %d: unknown feature %q", row, kind)
+		}
+		id := field(record, "id")
+		if kind == "point" || id == "" {
+			// Every point row is a point of its own
+			id = strconv.Itoa(row)
+		}
+
+		feature, ok := byID[kind+" "+id]
+		if !ok {
+			feature = &csvFeature{kind: kind}
+			byID[kind+" "+id] = feature
+			features = append(features, feature)
+		}
+		feature.points = append(feature.points, [2]float64{lat, lon})
+	}
+	if len(features) == 0 {
+		return fmt.Errorf("no rows found in the CSV file")
+	}
+
+	layer := doc.featureLayer()
+	var numPoints, numLines, numPolygons int
+	for _, feature := range features {
+		switch feature.kind {
+		case "point":
+			doc.AddPoint(PointObject{
+				Lat:        feature.points[0][0],
+				Lon:        feature.points[0][1],
+				Color:      color.RGBA{255, 0, 0, 255},
+				Scale:      1.0,
+				LabelColor: DefaultLabelColor,
+				LabelScale: 1,
+				Layer:      layer,
+			})
+			numPoints++
+		case "line":
+			line := PolyLine{Color: defaultLineColor, Width: 2, Layer: layer}
+			for i, point := range feature.points {
+				dist := 0.0
+				if i > 0 {
+					dist = GeodesicDistance(feature.points[i-1][0], feature.points[i-1][1], point[0], point[1])
+				}
+				line.Points = append(line.Points, LinePoint{Lat: point[0], Lon: point[1], Dist: dist})
+			}
+			doc.AddLine(line)
+			numLines++
+		case "polygon":
+			polygon := PolygonObject{Style: DefaultPolygonStyle(), Layer: layer}
+			for _, point := range feature.points {
+				polygon.Points = append(polygon.Points, PolyPoint{Lat: point[0], Lon: point[1]})
+			}
+			doc.AddPolygon(polygon)
+			numPolygons++
+		}
+	}
+
+	log.Printf("Loaded CSV with %d points, %d lines and %d polygons\n", numPoints, numLines, numPolygons)
+	return nil
+}
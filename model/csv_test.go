@@ -0,0 +1,106 @@
+package model
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadCSVRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "drops.kml")
+	if err := os.WriteFile(filename, []byte(testKML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := NewDocument()
+	if err := LoadKMLFile(filename, original); err != nil {
+		t.Fatal(err)
+	}
+
+	// Whatever ExportCSV writes, in any CRS, loads back as the same features
+	for _, code := range []int{4326, 2274, 26916} {
+		crs, err := LookupCRS(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := ExportCSV(&buf, original, crs); err != nil {
+			t.Fatal(err)
+		}
+
+		doc := NewDocument()
+		if err := LoadCSV(buf.Bytes(), doc); err != nil {
+			t.Fatalf("EPSG:%d: LoadCSV: %v", code, err)
+		}
+		if len(doc.Points) != 1 || len(doc.Lines) != 1 || len(doc.Polygons) != 1 {
+			t.Fatalf("EPSG:%d: got %d points, %d lines and %d polygons, want 1 of each", code, len(doc.Points), len(doc.Lines), len(doc.Polygons))
+		}
+		if point := doc.Points[0]; math.Abs(point.Lat-35.156072) > 1e-7 || math.Abs(point.Lon+90.051911) > 1e-7 {
+			t.Errorf("EPSG:%d: point at %f, %f", code, point.Lat, point.Lon)
+		}
+		if got, want := LineLength(doc.Lines[0].Points), LineLength(original.Lines[0].Points); math.Abs(got-want) > 0.01 {
+			t.Errorf("EPSG:%d: line length %.3f m, want %.3f m", code, got, want)
+		}
+		if n := len(doc.Polygons[0].Points); n != len(original.Polygons[0].Points) {
+			t.Errorf("EPSG:%d: polygon has %d points, want %d", code, n, len(original.Polygons[0].Points))
+		}
+	}
+}
+
+func TestLoadCSVImportCRS(t *testing.T) {
+	// Survey points in Tennessee State Plane feet, without a crs column
+	const survey = "x,y\n2460000.000,312000.000\n2461000.000,312000.000\n"
+
+	doc := NewDocument()
+	doc.ImportCRS, _ = LookupCRS(2274)
+	if err := LoadCSV([]byte(survey), doc); err != nil {
+		t.Fatalf("LoadCSV: %v", err)
+	}
+	if len(doc.Points) != 2 {
+		t.Fatalf("got %d points, want 2", len(doc.Points))
+	}
+	for i, point := range doc.Points {
+		x, y := doc.ImportCRS.FromLatLng(point.Lat, point.Lon)
+		if math.Abs(x-2460000-float64(i)*1000) > 0.001 || math.Abs(y-312000) > 0.001 {
+			t.Errorf("point %d came back as %.3f, %.3f", i, x, y)
+		}
+	}
+	// 1000 US survey feet apart
+	if dist := GeodesicDistance(doc.Points[0].Lat, doc.Points[0].Lon, doc.Points[1].Lat, doc.Points[1].Lon); math.Abs(dist-1000*USSurveyFoot) > 0.5 {
+		t.Errorf("points %.2f m apart, want about %.2f m", dist, 1000*USSurveyFoot)
+	}
+
+	// A crs column wins over the import CRS
+	doc = NewDocument()
+	doc.ImportCRS, _ = LookupCRS(2274)
+	if err := LoadCSV([]byte("x,y,crs\n-90.05,35.15,EPSG:4326\n"), doc); err != nil {
+		t.Fatal(err)
+	}
+	if point := doc.Points[0]; point.Lat != 35.15 || point.Lon != -90.05 {
+		t.Errorf("point at %f, %f, want 35.15, -90.05", point.Lat, point.Lon)
+	}
+}
+
+func TestLoadCSVMalformed(t *testing.T) {
+	tests := []struct {
+		csv  string
+		want string
+	}{
+		{"", "empty CSV"},
+		{"lat,lon\n35,-90\n", "no x and y columns"},
+		{"x,y\n", "no rows"},
+		{"x,y\n-90.05\n", "row 2: missing x or y"},
+		{"x,y\n-90.05,north\n", "row 2: invalid y"},
+		{"x,y,crs\n-90.05,35.15,EPSG:9999\n", "row 2: unsupported coordinate system"},
+		{"feature,x,y\ncircle,-90.05,35.15\n", "row 2: unknown feature"},
+	}
+
+	for _, test := range tests {
+		err := LoadCSV([]byte(test.csv), NewDocument())
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: error %v, want %q", test.csv, err, test.want)
+		}
+	}
+}
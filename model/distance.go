@@ -0,0 +1,224 @@
+package model
+
+import (
+	"math"
+)
+
+const EarthRadiusKM float64 = 6371.0     // Earth radius in kilometers
+const EarthRadiusFT float64 = 20902231.0 // Earth radius in feet
+
+func ToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180.0
+}
+
+func ToDegrees(radians float64) float64 {
+	return radians * 180.0 / math.Pi
+}
+
+func haversine(lat1, lon1, lat2, lon2, EarthRadius float64) float64 {
+	// Convert decimal degrees to radians
+	lat1, lon1 = ToRadians(lat1), ToRadians(lon1)
+	lat2, lon2 = ToRadians(lat2), ToRadians(lon2)
+
+	// Calculate the differences between the latitudes and longitudes
+	dLat := lat2 - lat1
+	dLon := lon2 - lon1
+
+	// Calculate the Haversine formula
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	// Calculate the distance
+	distance := EarthRadius * c
+	return distance
+}
+
+const EarthRadiusM float64 = 6371008.8 // Mean Earth radius in meters
+
+// vincentyInverse solves the inverse geodesic problem on the WGS84 ellipsoid
+// with Vincenty's formulas, returning the distance in meters and the forward
+// azimuths in degrees at both ends. It reports false for nearly antipodal
+// points where the iteration fails to converge.
+func vincentyInverse(lat1, lon1, lat2, lon2 float64) (float64, float64, float64, bool) {
+	a := WGS84.A
+	f := WGS84.F
+	b := a * (1 - f)
+
+	L := ToRadians(WrapLongitude(lon2 - lon1))
+	U1 := math.Atan((1 - f) * math.Tan(ToRadians(lat1)))
+	U2 := math.Atan((1 - f) * math.Tan(ToRadians(lat2)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, 0, 0, true // Coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0 // Equatorial line
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return 0, 0, 0, false
+	}
+
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	distance := b * A * (sigma - deltaSigma)
+
+	azimuth1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	azimuth2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+
+	return distance, NormalizeBearing(ToDegrees(azimuth1)), NormalizeBearing(ToDegrees(azimuth2)), true
+}
+
+// VincentyDirect solves the direct geodesic problem, returning the location
+// reached by travelling the distance in meters from a point along the azimuth
+// in degrees
+func VincentyDirect(lat1, lon1, azimuth, distance float64) (float64, float64) {
+	a := WGS84.A
+	f := WGS84.F
+	b := a * (1 - f)
+
+	sinAlpha1, cosAlpha1 := math.Sincos(ToRadians(azimuth))
+	tanU1 := (1 - f) * math.Tan(ToRadians(lat1))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := distance / (b * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		previous := sigma
+		sigma = distance/(b*A) + deltaSigma
+		if math.Abs(sigma-previous) < 1e-12 {
+			break
+		}
+	}
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+	sinSigma, cosSigma = math.Sincos(sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-f)*math.Hypot(sinAlpha, x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+	return ToDegrees(lat2), WrapLongitude(lon1 + ToDegrees(L))
+}
+
+// GeodesicDistance returns the ellipsoidal distance between two points in meters
+func GeodesicDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	distance, _, _, ok := vincentyInverse(lat1, lon1, lat2, lon2)
+	if !ok {
+		// Nearly antipodal, where a sphere is as good as it gets without Karney's method
+		return haversine(lat1, lon1, lat2, lon2, EarthRadiusM)
+	}
+	return distance
+}
+
+// GeodesicBearing returns the initial bearing from the first point to the
+// second in degrees clockwise from true north
+func GeodesicBearing(lat1, lon1, lat2, lon2 float64) float64 {
+	_, azimuth, _, ok := vincentyInverse(lat1, lon1, lat2, lon2)
+	if !ok {
+		// Fall back to the great circle bearing
+		phi1, phi2 := ToRadians(lat1), ToRadians(lat2)
+		dLon := ToRadians(lon2 - lon1)
+		y := math.Sin(dLon) * math.Cos(phi2)
+		x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLon)
+		return NormalizeBearing(ToDegrees(math.Atan2(y, x)))
+	}
+	return azimuth
+}
+
+func NormalizeBearing(bearing float64) float64 {
+	bearing = math.Mod(bearing, 360)
+	if bearing < 0 {
+		bearing += 360
+	}
+	return bearing
+}
+
+// GeodesicPolygonArea returns the area of a polygon on the WGS84 ellipsoid in
+// square meters. Latitudes are mapped to authalic latitudes on the sphere of
+// equal surface area, where the spherical excess of each edge down to the
+// equator is exact.
+func GeodesicPolygonArea(points []PolyPoint) float64 {
+	if len(points) < 3 {
+		return 0
+	}
+
+	e2 := WGS84.E2()
+	e := math.Sqrt(e2)
+	q := func(phi float64) float64 {
+		sinPhi := math.Sin(phi)
+		return (1 - e2) * (sinPhi/(1-e2*sinPhi*sinPhi) - 1/(2*e)*math.Log((1-e*sinPhi)/(1+e*sinPhi)))
+	}
+	qp := q(math.Pi / 2)
+	authalicRadius := WGS84.A * math.Sqrt(qp/2)
+	authalic := func(lat float64) float64 {
+		return math.Asin(math.Max(-1, math.Min(1, q(ToRadians(lat))/qp)))
+	}
+
+	excess := 0.0
+	for i := range points {
+		p1 := points[i]
+		p2 := points[(i+1)%len(points)]
+		beta1, beta2 := authalic(p1.Lat), authalic(p2.Lat)
+		dLon := ToRadians(WrapLongitude(p2.Lon - p1.Lon))
+
+		t1, t2 := math.Tan(beta1/2), math.Tan(beta2/2)
+		excess += 2 * math.Atan2(math.Tan(dLon/2)*(t1+t2), 1+t1*t2)
+	}
+
+	return math.Abs(excess) * authalicRadius * authalicRadius
+}
+
+// GeodesicPerimeter returns the length of a closed ring in meters
+func GeodesicPerimeter(points []PolyPoint) float64 {
+	perimeter := 0.0
+	for i := range points {
+		next := points[(i+1)%len(points)]
+		perimeter += GeodesicDistance(points[i].Lat, points[i].Lon, next.Lat, next.Lon)
+	}
+	return perimeter
+}
+
+// LineLength returns the geodesic length of a line in meters
+func LineLength(points []LinePoint) float64 {
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		length += GeodesicDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+	}
+	return length
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func dms(degrees, minutes, seconds float64) float64 {
+	return math.Copysign(math.Abs(degrees)+minutes/60+seconds/3600, degrees)
+}
+
+// Flinders Peak to Buninyong, the worked example of Vincenty's 1975 paper as
+// given by Geoscience Australia
+var (
+	flindersLat, flindersLon   = -dms(37, 57, 3.72030), dms(144, 25, 29.52440)
+	buninyongLat, buninyongLon = -dms(37, 39, 10.15610), dms(143, 55, 35.38390)
+	flindersAzimuth            = dms(306, 52, 5.37)
+	buninyongReverseAzimuth    = dms(127, 10, 25.07)
+	flindersBuninyongDistance  = 54972.271
+)
+
+func TestVincentyInverse(t *testing.T) {
+	distance, azimuth1, azimuth2, ok := vincentyInverse(flindersLat, flindersLon, buninyongLat, buninyongLon)
+	if !ok {
+		t.Fatal("vincentyInverse did not converge")
+	}
+	if math.Abs(distance-flindersBuninyongDistance) > 0.001 {
+		t.Errorf("distance %.4f m, want %.3f m", distance, flindersBuninyongDistance)
+	}
+	// Azimuths are quoted to a hundredth of an arc second
+	if math.Abs(azimuth1-flindersAzimuth) > 0.01/3600 {
+		t.Errorf("azimuth at Flinders Peak %.8f°, want %.8f°", azimuth1, flindersAzimuth)
+	}
+	// The forward azimuth at Buninyong points away from Flinders Peak
+	if math.Abs(azimuth2-(buninyongReverseAzimuth+180)) > 0.01/3600 {
+		t.Errorf("azimuth at Buninyong %.8f°, want %.8f°", azimuth2, buninyongReverseAzimuth+180)
+	}
+
+	if distance, _, _, ok := vincentyInverse(35.1, -90.05, 35.1, -90.05); !ok || distance != 0 {
+		t.Errorf("distance between coincident points %g, %v", distance, ok)
+	}
+}
+
+func TestVincentyDirect(t *testing.T) {
+	lat, lon := VincentyDirect(flindersLat, flindersLon, flindersAzimuth, flindersBuninyongDistance)
+	// 1e-7° is about a centimeter
+	if math.Abs(lat-buninyongLat) > 1e-7 || math.Abs(lon-buninyongLon) > 1e-7 {
+		t.Errorf("reached %.9f, %.9f, want Buninyong at %.9f, %.9f", lat, lon, buninyongLat, buninyongLon)
+	}
+
+	// Going there and measuring back agree, across the antimeridian too
+	for _, test := range []struct{ lat, lon, azimuth, distance float64 }{
+		{35.156072, -90.051911, 45, 1000},
+		{0, 179.9, 90, 50000},
+		{-60, 10, 200, 2000000},
+	} {
+		lat, lon := VincentyDirect(test.lat, test.lon, test.azimuth, test.distance)
+		distance, azimuth, _, ok := vincentyInverse(test.lat, test.lon, lat, lon)
+		if !ok || math.Abs(distance-test.distance) > 0.001 || math.Abs(azimuth-test.azimuth) > 1e-6 {
+			t.Errorf("%+v came back as %.4f m at %.8f°", test, distance, azimuth)
+		}
+	}
+}
+
+func TestGeodesicDistanceAntipodal(t *testing.T) {
+	// Vincenty's inverse doesn't converge for nearly antipodal points
+	lat1, lon1, lat2, lon2 := 0.0, 0.0, 0.5, 179.7
+	if _, _, _, ok := vincentyInverse(lat1, lon1, lat2, lon2); ok {
+		t.Fatal("vincentyInverse converged for nearly antipodal points")
+	}
+
+	distance := GeodesicDistance(lat1, lon1, lat2, lon2)
+	if want := haversine(lat1, lon1, lat2, lon2, EarthRadiusM); distance != want {
+		t.Errorf("distance %.3f m, want the haversine %.3f m", distance, want)
+	}
+	// Half the meridian circumference is 20,003,931 m, so a little under that
+	if distance < 19900000 || distance > 20003931 {
+		t.Errorf("distance %.0f m is not nearly half way around the world", distance)
+	}
+	if bearing := GeodesicBearing(lat1, lon1, lat2, lon2); math.IsNaN(bearing) || bearing < 0 || bearing >= 360 {
+		t.Errorf("bearing %g, want a great circle bearing", bearing)
+	}
+}
+
+func TestGeodesicPolygonArea(t *testing.T) {
+	// A quadrangle between two parallels has a closed form area on the
+	// ellipsoid, b²Δλ/2 times the difference of this between the parallels
+	e := math.Sqrt(WGS84.E2())
+	b := WGS84.A * (1 - WGS84.F)
+	zone := func(lat float64) float64 {
+		sinPhi := math.Sin(ToRadians(lat))
+		return sinPhi/(1-e*e*sinPhi*sinPhi) + math.Log((1+e*sinPhi)/(1-e*sinPhi))/(2*e)
+	}
+	quadrangle := func(lat1, lat2, dLon float64) float64 {
+		return b * b * ToRadians(dLon) / 2 * (zone(lat2) - zone(lat1))
+	}
+
+	tests := []struct {
+		name   string
+		points []PolyPoint
+		want   float64
+	}{
+		// Edges along parallels are great circles on the authalic sphere rather
+		// than parallels, which is well under the tolerance at this size
+		{"one degree at the equator", []PolyPoint{{0, 0}, {0, 1}, {1, 1}, {1, 0}}, quadrangle(0, 1, 1)},
+		{"tenth of a degree at 35°N", []PolyPoint{{35, -90.1}, {35, -90}, {35.1, -90}, {35.1, -90.1}}, quadrangle(35, 35.1, 0.1)},
+		{"across the antimeridian", []PolyPoint{{-10, 179.95}, {-10, -179.95}, {-9.9, -179.95}, {-9.9, 179.95}}, quadrangle(-10, -9.9, 0.1)},
+	}
+
+	for _, test := range tests {
+		area := GeodesicPolygonArea(test.points)
+		if math.Abs(area-test.want)/test.want > 1e-4 {
+			t.Errorf("%s: area %.0f m², want %.0f m²", test.name, area, test.want)
+		}
+
+		// Winding the other way gives the same area
+		reversed := make([]PolyPoint, len(test.points))
+		for i, point := range test.points {
+			reversed[len(test.points)-1-i] = point
+		}
+		if reversedArea := GeodesicPolygonArea(reversed); math.Abs(reversedArea-area) > 1e-6*area {
+			t.Errorf("%s: reversed area %.0f m², want %.0f m²", test.name, reversedArea, area)
+		}
+	}
+
+	if area := GeodesicPolygonArea([]PolyPoint{{0, 0}, {1, 1}}); area != 0 {
+		t.Errorf("area of two points %g, want 0", area)
+	}
+}
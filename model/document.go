@@ -0,0 +1,148 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Document holds the features and styles being edited, apart from the window
+// that draws them. Importers, exporters and commands work on a Document, and
+// anything showing it subscribes with OnChange to hear when it needs redrawing.
+type Document struct {
+	Points       []PointObject
+	Lines        []PolyLine
+	Polygons     []PolygonObject
+	Layers       []Layer
+	CurrentLayer int // Layer new features are drawn on
+	StyleMap     map[string]map[string]string
+	Styles       map[string]Style
+	Icons        map[string]image.Image // Icon images by KML href, relative ones under their file, drawn by whatever shows the document
+
+	ImportCRS *CRS // Coordinate system of imported CSV x,y without a crs column, nil for lon/lat
+	LoadIcons bool // Load icon images, off when nothing will be drawn
+
+	iconFS      fs.FS  // Where relative icon paths of the file being loaded point, the KMZ or the KML's folder
+	iconSource  string // Path of the KMZ or the KML's folder, relative icon hrefs are keyed under it
+	importLayer string // Layer of the file being loaded, empty for the current layer
+	listeners   []func()
+}
+
+// Layer is a named group of features that are shown or hidden together
+type Layer struct {
+	Name    string
+	Visible bool
+}
+
+// DefaultLayer is the layer a new document draws on
+const DefaultLayer = "0"
+
+func NewDocument() *Document {
+	return &Document{
+		Layers:    []Layer{{Name: DefaultLayer, Visible: true}},
+		StyleMap:  make(map[string]map[string]string),
+		Styles:    make(map[string]Style),
+		Icons:     make(map[string]image.Image),
+		LoadIcons: true,
+	}
+}
+
+// OnChange registers a function to call whenever the document changes
+func (d *Document) OnChange(listener func()) {
+	d.listeners = append(d.listeners, listener)
+}
+
+// Changed tells the listeners the document has changed, for edits made
+// directly to its fields
+func (d *Document) Changed() {
+	for _, listener := range d.listeners {
+		listener()
+	}
+}
+
+func (d *Document) AddPoint(point PointObject) {
+	d.Points = append(d.Points, point)
+	d.Changed()
+}
+
+func (d *Document) AddLine(line PolyLine) {
+	d.Lines = append(d.Lines, line)
+	d.Changed()
+}
+
+func (d *Document) AddPolygon(polygon PolygonObject) {
+	d.Polygons = append(d.Polygons, polygon)
+	d.Changed()
+}
+
+// NumSegments returns the number of line segments in all lines
+func (d *Document) NumSegments() int {
+	segments := 0
+	for _, line := range d.Lines {
+		if len(line.Points) > 1 {
+			segments += len(line.Points) - 1
+		}
+	}
+	return segments
+}
+
+// LayerIndex finds a layer by name, ignoring case
+func (d *Document) LayerIndex(name string) (int, bool) {
+	for i, layer := range d.Layers {
+		if strings.EqualFold(layer.Name, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Layer returns the index of the named layer, adding it if there is none
+func (d *Document) Layer(name string) int {
+	if index, exists := d.LayerIndex(name); exists {
+		return index
+	}
+	d.Layers = append(d.Layers, Layer{Name: name, Visible: true})
+	return len(d.Layers) - 1
+}
+
+// Visible reports whether features on a layer are shown
+func (d *Document) Visible(layer int) bool {
+	if layer < 0 || layer >= len(d.Layers) {
+		return true
+	}
+	return d.Layers[layer].Visible
+}
+
+func (d *Document) layerName(layer int) string {
+	if layer < 0 || layer >= len(d.Layers) {
+		return ""
+	}
+	return d.Layers[layer].Name
+}
+
+// SetLayerVisible shows or hides the named layer
+func (d *Document) SetLayerVisible(name string, visible bool) error {
+	index, exists := d.LayerIndex(name)
+	if !exists {
+		return fmt.Errorf("no layer %q", name)
+	}
+	d.Layers[index].Visible = visible
+	d.Changed()
+	return nil
+}
+
+// featureLayer is the layer imported features go on
+func (d *Document) featureLayer() int {
+	if d.importLayer == "" {
+		return d.CurrentLayer
+	}
+	return d.Layer(d.importLayer)
+}
+
+// fileLayer is the name of the layer the features of a file are loaded on
+func fileLayer(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ExportCSV writes every vertex of every feature as a CSV row with coordinates
+// in the given CRS, or lon/lat when crs is nil
+func ExportCSV(w io.Writer, doc *Document, crs *CRS) error {
+	if crs == nil {
+		crs, _ = LookupCRS(4326)
+	}
+
+	precision := 3 // Millimeters or thousandths of a foot
+	if crs.IsGeographic() {
+		precision = 8
+	}
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', precision, 64)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"feature", "id", "vertex", "x", "y", "crs"}); err != nil {
+		return err
+	}
+	crsName := fmt.Sprintf("EPSG:%d", crs.Code)
+
+	for i, point := range doc.Points {
+		x, y := crs.FromLatLng(point.Lat, point.Lon)
+		if err := writer.Write([]string{"point", strconv.Itoa(i), "0", format(x), format(y), crsName}); err != nil {
+			return err
+		}
+	}
+	for i, line := range doc.Lines {
+		for j, point := range line.Points {
+			x, y := crs.FromLatLng(point.Lat, point.Lon)
+			if err := writer.Write([]string{"line", strconv.Itoa(i), strconv.Itoa(j), format(x), format(y), crsName}); err != nil {
+				return err
+			}
+		}
+	}
+	for i, polygon := range doc.Polygons {
+		for j, point := range polygon.Points {
+			x, y := crs.FromLatLng(point.Lat, point.Lon)
+			if err := writer.Write([]string{"polygon", strconv.Itoa(i), strconv.Itoa(j), format(x), format(y), crsName}); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportGeoJSON writes every feature as a GeoJSON FeatureCollection, which is
+// always lon/lat on WGS84
+func ExportGeoJSON(w io.Writer, doc *Document) error {
+	type geometry struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string                 `json:"type"`
+		Geometry   geometry               `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	features := []feature{}
+	for i, point := range doc.Points {
+		properties := map[string]interface{}{"id": i, "layer": doc.layerName(point.Layer)}
+		if point.Name != "" {
+			properties["name"] = point.Name
+		}
+		if point.Type != "" {
+			properties["type"] = point.Type
+			for key, value := range point.Attrs {
+				properties[key] = value
+			}
+		}
+		if capture := point.Capture; capture != nil {
+			properties["accuracy_m"] = capture.Accuracy
+			properties["spread_m"] = capture.Spread
+			properties["fix_quality"] = FixQualityName(capture.Quality)
+			properties["hdop"] = capture.HDOP
+			properties["fixes"] = capture.Fixes
+			if !capture.Time.IsZero() {
+				properties["time"] = capture.Time.Format(time.RFC3339)
+			}
+		}
+		features = append(features, feature{
+			Type:       "Feature",
+			Geometry:   geometry{Type: "Point", Coordinates: [2]float64{point.Lon, point.Lat}},
+			Properties: properties,
+		})
+	}
+	for i, line := range doc.Lines {
+		coordinates := make([][2]float64, len(line.Points))
+		for j, point := range line.Points {
+			coordinates[j] = [2]float64{point.Lon, point.Lat}
+		}
+		features = append(features, feature{
+			Type:       "Feature",
+			Geometry:   geometry{Type: "LineString", Coordinates: coordinates},
+			Properties: map[string]interface{}{"id": i, "layer": doc.layerName(line.Layer), "length_m": LineLength(line.Points)},
+		})
+	}
+	for i, polygon := range doc.Polygons {
+		// Rings are closed by repeating the first point
+		ring := make([][2]float64, 0, len(polygon.Points)+1)
+		for _, point := range polygon.Points {
+			ring = append(ring, [2]float64{point.Lon, point.Lat})
+		}
+		if len(ring) > 0 {
+			ring = append(ring, ring[0])
+		}
+		features = append(features, feature{
+			Type:       "Feature",
+			Geometry:   geometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: map[string]interface{}{"id": i, "layer": doc.layerName(polygon.Layer), "area_m2": GeodesicPolygonArea(polygon.Points)},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+func ExportCSVFile(filename string, doc *Document, crs *CRS) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := ExportCSV(file, doc, crs); err != nil {
+		return err
+	}
+	return file.Close()
+}
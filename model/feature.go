@@ -0,0 +1,90 @@
+package model
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+type PointObject struct {
+	Lat, Lon   float64
+	Color      color.RGBA
+	IconHref   string     // Icon of the KML style, see Document.Icons, empty for none
+	IconColor  color.RGBA // Tint of the icon, zero for none
+	Scale      float64
+	HotSpot    HotSpot
+	Name       string
+	LabelColor color.RGBA
+	LabelScale float64           // Size of the name label, 0 for no label
+	Symbol     string            // GPX waypoint symbol, e.g. Flag, Blue
+	Type       string            // Symbol type placed with PO <type>, e.g. HANDHOLE
+	Attrs      map[string]string // Attributes of the symbol type
+	Capture    *GPSCapture       // Set on points placed from the GPS
+	Highlight  *FeatureStyle     // KML highlight style drawn under the mouse
+	Layer      int               // Index in Document.Layers
+}
+
+type LinePoint struct {
+	Lat, Lon float64
+	Dist     float64 // Geodesic distance from the previous point in meters
+
+	// Set on GPS track points
+	Time time.Time
+	Alt  float64
+	HDOP float64
+}
+
+type PolyLine struct {
+	Points    []LinePoint
+	Color     color.RGBA
+	Width     float32
+	Highlight *FeatureStyle // KML highlight style drawn under the mouse
+	Layer     int
+}
+
+type PolyPoint struct {
+	Lat, Lon float64
+}
+
+type PolygonObject struct {
+	Points    []PolyPoint
+	Style     FeatureStyle
+	Highlight *FeatureStyle // KML highlight style drawn under the mouse
+	Layer     int
+}
+
+// GPSCapture records how accurately a point placed from the GPS is known
+type GPSCapture struct {
+	Accuracy float64 // Mean one sigma horizontal accuracy of the fixes in meters
+	Spread   float64 // RMS distance of the fixes from the point in meters
+	Quality  string  // Best GGA fix quality among the fixes
+	HDOP     float64 // Mean HDOP
+	Fixes    int
+	Time     time.Time
+}
+
+// FixQualityName describes a GGA fix quality
+func FixQualityName(quality string) string {
+	switch quality {
+	case nmea.Invalid:
+		return "Invalid"
+	case nmea.GPS:
+		return "GPS"
+	case nmea.DGPS:
+		return "DGPS"
+	case nmea.PPS:
+		return "PPS"
+	case nmea.RTK:
+		return "RTK Fixed"
+	case nmea.FRTK:
+		return "RTK Float"
+	case nmea.EST:
+		return "Estimated"
+	case "7":
+		return "Manual"
+	case "8":
+		return "Simulated"
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	routeColor = color.RGBA{255, 0, 255, 255}
+	TrackColor = color.RGBA{255, 128, 0, 255} // GPS tracks, recorded or imported
+)
+
+// gpxDocument is the part of GPX 1.0 and 1.1 read on import. Elements are matched
+// without their namespace so both versions load.
+type gpxDocument struct {
+	XMLName   xml.Name       `xml:"gpx"`
+	Waypoints []gpxWaypoint  `xml:"wpt"`
+	Routes    []gpxRoute     `xml:"rte"`
+	Tracks    []gpxTrackData `xml:"trk"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  float64 `xml:"ele"`
+	Time string  `xml:"time"`
+	Name string  `xml:"name"`
+	Sym  string  `xml:"sym"`
+	HDOP float64 `xml:"hdop"`
+}
+
+type gpxRoute struct {
+	Name   string        `xml:"name"`
+	Points []gpxWaypoint `xml:"rtept"`
+}
+
+type gpxTrackData struct {
+	Name     string `xml:"name"`
+	Segments []struct {
+		Points []gpxWaypoint `xml:"trkpt"`
+	} `xml:"trkseg"`
+}
+
+// LoadMapFile loads a KML, KMZ, GPX or CSV file into the document
+func LoadMapFile(filename string, doc *Document) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gpx":
+		return LoadGPXFile(filename, doc)
+	case ".csv":
+		return LoadCSVFile(filename, doc)
+	}
+	return LoadKMLFile(filename, doc)
+}
+
+// LoadGPXFile loads a GPX file onto a layer named after the file
+func LoadGPXFile(filename string, doc *Document) error {
+	gpxData, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	doc.importLayer = fileLayer(filename)
+	defer func() { doc.importLayer = "" }()
+	return LoadGPX(gpxData, doc)
+}
+
+// LoadGPX adds GPX waypoints as points, and routes and track segments as lines
+// keeping the time and elevation of each point
+func LoadGPX(gpxData []byte, doc *Document) error {
+	var gpx gpxDocument
+	if err := xml.Unmarshal(gpxData, &gpx); err != nil {
+		return err
+	}
+	if len(gpx.Waypoints) == 0 && len(gpx.Routes) == 0 && len(gpx.Tracks) == 0 {
+		return fmt.Errorf("no waypoints, routes or tracks found in the GPX file")
+	}
+
+	layer := doc.featureLayer()
+	for _, waypoint := range gpx.Waypoints {
+		doc.AddPoint(PointObject{
+			Lat:        waypoint.Lat,
+			Lon:        waypoint.Lon,
+			Color:      color.RGBA{255, 0, 0, 255},
+			Scale:      1.0,
+			Name:       strings.TrimSpace(waypoint.Name),
+			LabelColor: DefaultLabelColor,
+			LabelScale: 1,
+			Symbol:     strings.TrimSpace(waypoint.Sym),
+			Layer:      layer,
+		})
+	}
+	for _, route := range gpx.Routes {
+		addGPXLine(route.Points, routeColor, layer, doc)
+	}
+	for _, track := range gpx.Tracks {
+		// Segments are kept apart, the receiver lost its fix in between
+		for _, segment := range track.Segments {
+			addGPXLine(segment.Points, TrackColor, layer, doc)
+		}
+	}
+
+	log.Printf("Loaded GPX with %d waypoints, %d routes and %d tracks\n", len(gpx.Waypoints), len(gpx.Routes), len(gpx.Tracks))
+	return nil
+}
+
+func addGPXLine(points []gpxWaypoint, clr color.RGBA, layer int, doc *Document) {
+	if len(points) < 2 {
+		return
+	}
+
+	line := PolyLine{Color: clr, Width: 2, Layer: layer}
+	for i, point := range points {
+		dist := 0.0
+		if i > 0 {
+			dist = GeodesicDistance(points[i-1].Lat, points[i-1].Lon, point.Lat, point.Lon)
+		}
+		linePoint := LinePoint{Lat: point.Lat, Lon: point.Lon, Dist: dist, Alt: point.Ele, HDOP: point.HDOP}
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(point.Time)); err == nil {
+			linePoint.Time = t
+		}
+		line.Points = append(line.Points, linePoint)
+	}
+	doc.AddLine(line)
+}
+
+// PointDescription names a point for selection messages, with its GPX symbol
+func PointDescription(point PointObject) string {
+	switch {
+	case point.Symbol == "":
+		return point.Name
+	case point.Name == "":
+		return "symbol " + point.Symbol
+	}
+	return fmt.Sprintf("%s, symbol %s", point.Name, point.Symbol)
+}
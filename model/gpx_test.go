@@ -0,0 +1,168 @@
+package model
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testGPX11 = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+	<wpt lat="35.156072" lon="-90.051911">
+		<ele>80.5</ele>
+		<name> HH-1 </name>
+		<sym>Flag, Blue</sym>
+	</wpt>
+	<wpt lat="35.157" lon="-90.05"></wpt>
+	<rte>
+		<name>Route</name>
+		<rtept lat="35.15" lon="-90.05"></rtept>
+		<rtept lat="35.16" lon="-90.05"></rtept>
+		<rtept lat="35.16" lon="-90.04"></rtept>
+	</rte>
+	<trk>
+		<name>Drive</name>
+		<trkseg>
+			<trkpt lat="35.15" lon="-90.05">
+				<ele>81.5</ele>
+				<time>2026-10-19T12:00:00Z</time>
+				<hdop>0.8</hdop>
+			</trkpt>
+			<trkpt lat="35.151" lon="-90.05">
+				<ele>82</ele>
+				<time>2026-10-19T07:00:01.25-05:00</time>
+			</trkpt>
+		</trkseg>
+		<trkseg>
+			<trkpt lat="35.152" lon="-90.05"><time>yesterday</time></trkpt>
+		</trkseg>
+		<trkseg>
+			<trkpt lat="35.153" lon="-90.05"></trkpt>
+			<trkpt lat="35.154" lon="-90.05"></trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+// testGPX10 is a GPX 1.0 track as older receivers and software write it, with
+// speed and course in each point
+const testGPX10 = `<?xml version="1.0"?>
+<gpx version="1.0" creator="test" xmlns="http://www.topografix.com/GPX/1/0">
+	<time>2026-10-19T12:00:00Z</time>
+	<wpt lat="35.15" lon="-90.05"><name>Start</name></wpt>
+	<trk>
+		<trkseg>
+			<trkpt lat="35.15" lon="-90.05">
+				<ele>80</ele>
+				<time>2026-10-19T12:00:00Z</time>
+				<course>0</course>
+				<speed>2.5</speed>
+			</trkpt>
+			<trkpt lat="35.151" lon="-90.05">
+				<ele>80</ele>
+				<time>2026-10-19T12:00:45Z</time>
+				<course>0</course>
+				<speed>2.5</speed>
+			</trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+func TestLoadGPX(t *testing.T) {
+	doc := NewDocument()
+	if err := LoadGPX([]byte(testGPX11), doc); err != nil {
+		t.Fatalf("LoadGPX: %v", err)
+	}
+
+	// The single point segment makes no line
+	if len(doc.Points) != 2 || len(doc.Lines) != 3 {
+		t.Fatalf("got %d points and %d lines, want 2 and 3", len(doc.Points), len(doc.Lines))
+	}
+
+	point := doc.Points[0]
+	if point.Name != "HH-1" || point.Symbol != "Flag, Blue" || point.Lat != 35.156072 || point.Lon != -90.051911 {
+		t.Errorf("waypoint is %q, symbol %q at %f, %f", point.Name, point.Symbol, point.Lat, point.Lon)
+	}
+	if description := PointDescription(point); description != "HH-1, symbol Flag, Blue" {
+		t.Errorf("waypoint described as %q", description)
+	}
+
+	route := doc.Lines[0]
+	if route.Color != routeColor || len(route.Points) != 3 {
+		t.Errorf("route color %v with %d points, want %v with 3", route.Color, len(route.Points), routeColor)
+	}
+	if dist := route.Points[1].Dist; math.Abs(dist-GeodesicDistance(35.15, -90.05, 35.16, -90.05)) > 1e-9 {
+		t.Errorf("second route point %.3f m on", dist)
+	}
+
+	track := doc.Lines[1]
+	if track.Color != TrackColor || len(track.Points) != 2 {
+		t.Fatalf("track color %v with %d points, want %v with 2", track.Color, len(track.Points), TrackColor)
+	}
+	first, second := track.Points[0], track.Points[1]
+	if first.Alt != 81.5 || first.HDOP != 0.8 || !first.Time.Equal(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("first track point elevation %g, HDOP %g, time %v", first.Alt, first.HDOP, first.Time)
+	}
+	// Times with an offset and fractional seconds are read as the same instant
+	if want := time.Date(2026, 10, 19, 12, 0, 1, 250000000, time.UTC); second.Alt != 82 || second.HDOP != 0 || !second.Time.Equal(want) {
+		t.Errorf("second track point elevation %g, HDOP %g, time %v, want 82, 0, %v", second.Alt, second.HDOP, second.Time, want)
+	}
+
+	// A later segment is its own line, not joined to the one before
+	if last := doc.Lines[2]; last.Points[0].Lat != 35.153 || last.Points[0].Dist != 0 || !last.Points[0].Time.IsZero() {
+		t.Errorf("last segment starts %+v", last.Points[0])
+	}
+}
+
+func TestLoadGPXVersions(t *testing.T) {
+	noNamespace := strings.Replace(testGPX10, ` xmlns="http://www.topografix.com/GPX/1/0"`, "", 1)
+	for name, data := range map[string]string{"GPX 1.0": testGPX10, "no namespace": noNamespace} {
+		doc := NewDocument()
+		if err := LoadGPX([]byte(data), doc); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(doc.Points) != 1 || len(doc.Lines) != 1 || doc.Points[0].Name != "Start" {
+			t.Errorf("%s: got %d points and %d lines, want the Start waypoint and a track", name, len(doc.Points), len(doc.Lines))
+			continue
+		}
+		points := doc.Lines[0].Points
+		if len(points) != 2 || points[1].Alt != 80 || points[1].Time.Sub(points[0].Time) != 45*time.Second {
+			t.Errorf("%s: track points %+v", name, points)
+		}
+	}
+}
+
+func TestLoadGPXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", `<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1"></gpx>`, "no waypoints, routes or tracks"},
+		{"not XML", "lat,lon\n35.15,-90.05\n", "EOF"},
+		{"KML", testKML, "expected element type <gpx>"},
+	}
+	for _, test := range tests {
+		err := LoadGPX([]byte(test.data), NewDocument())
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestLoadMapFileGPX(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "Drive Test.GPX")
+	if err := os.WriteFile(filename, []byte(testGPX10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := NewDocument()
+	if err := LoadMapFile(filename, doc); err != nil {
+		t.Fatalf("LoadMapFile: %v", err)
+	}
+	if len(doc.Lines) != 1 || doc.layerName(doc.Lines[0].Layer) != "Drive Test" {
+		t.Errorf("got %d lines on layer %q, want the track on Drive Test", len(doc.Lines), doc.layerName(doc.Points[0].Layer))
+	}
+}
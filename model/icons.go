@@ -0,0 +1,273 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Some KML icons are GIFs
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Icons referenced by KML styles are looked for, in order, inside the KMZ
+// or next to the KML for relative hrefs, among the Google Earth pushpins and
+// paddles bundled here, in the icon cache on disk, and finally on the web.
+// An icon that cannot be found anywhere is drawn as the default yellow
+// pushpin instead of failing the import.
+
+//go:embed icons/*.png
+var iconFiles embed.FS
+
+const iconDownloadTimeout = 10 * time.Second
+
+var iconClient = &http.Client{Timeout: iconDownloadTimeout}
+
+// Colors of the Google Earth pushpin and paddle icons by the name used in
+// their file names, e.g. ylw-pushpin.png or red-circle.png
+var googleIconColors = map[string]color.RGBA{
+	"ylw":    {255, 230, 0, 255},
+	"blue":   {60, 90, 255, 255},
+	"blu":    {60, 90, 255, 255},
+	"grn":    {0, 190, 60, 255},
+	"ltblu":  {90, 200, 255, 255},
+	"pink":   {255, 110, 190, 255},
+	"purple": {150, 60, 210, 255},
+	"red":    {230, 30, 30, 255},
+	"wht":    {255, 255, 255, 255},
+	"orange": {255, 150, 0, 255},
+}
+
+// loadIconImages loads the images of new icon hrefs into the document
+func loadIconImages(doc *Document, hrefs map[string]bool) {
+	// Icons are only needed to draw
+	if !doc.LoadIcons {
+		return
+	}
+
+	for href := range hrefs {
+		loadIconImage(doc, strings.TrimSpace(href))
+	}
+}
+
+// loadIconImage loads the image of an icon href into the document's icons on
+// first use, returning its key there. It never fails, icons that cannot be
+// found get the default pushpin.
+func loadIconImage(doc *Document, href string) string {
+	key := doc.iconKey(href)
+	if !doc.LoadIcons || key == "" {
+		return key
+	}
+	if _, exists := doc.Icons[key]; exists {
+		return key
+	}
+
+	img, err := resolveIcon(doc, href)
+	if err != nil {
+		log.Printf("Icon %s: %v, using the default pushpin\n", key, err)
+		img, _ = googleIcon("pushpin", "ylw-pushpin")
+	}
+	doc.Icons[key] = img
+	return key
+}
+
+// iconKey returns the key of an icon href in Document.Icons. Relative hrefs
+// are keyed under the KMZ or folder they were read from, as files/icon.png in
+// one KMZ is not the same icon as in another.
+func (d *Document) iconKey(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || !relativeIconHref(href) || d.iconSource == "" {
+		return href
+	}
+	return d.iconSource + "/" + href
+}
+
+// relativeIconHref reports whether an icon href is a path relative to the
+// KMZ or the KML's folder, rather than a web or file URL or an absolute path
+func relativeIconHref(href string) bool {
+	if u, err := url.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+		return false
+	}
+	return !filepath.IsAbs(href) && !strings.HasPrefix(href, "/")
+}
+
+func resolveIcon(doc *Document, href string) (image.Image, error) {
+	href = strings.TrimSpace(href)
+	u, err := url.Parse(href)
+	isWeb := err == nil && (u.Scheme == "http" || u.Scheme == "https")
+
+	if !isWeb {
+		return loadLocalIcon(doc, href)
+	}
+	if img, ok := bundledGoogleIcon(u); ok {
+		return img, nil
+	}
+	return downloadIcon(href)
+}
+
+// loadLocalIcon reads an icon given as a path, relative to the KMZ archive
+// or the folder of the KML, or as an absolute file path or file:// URL
+func loadLocalIcon(doc *Document, href string) (image.Image, error) {
+	if strings.HasPrefix(href, "file://") {
+		if u, err := url.Parse(href); err == nil {
+			return decodeIconFile(filepath.FromSlash(u.Path))
+		}
+	}
+	if filepath.IsAbs(href) {
+		return decodeIconFile(href)
+	}
+	if doc.iconFS == nil {
+		return nil, fmt.Errorf("relative icon path with nothing to resolve it against")
+	}
+
+	name := filepath.ToSlash(href)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.TrimPrefix(path.Clean(name), "/")
+
+	data, err := fs.ReadFile(doc.iconFS, name)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+func decodeIconFile(filename string) (image.Image, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// bundledGoogleIcon draws the standard Google Earth pushpins and paddles
+// without going to the web, e.g. maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png
+func bundledGoogleIcon(u *url.URL) (image.Image, bool) {
+	host := strings.ToLower(u.Hostname())
+	if !strings.HasSuffix(host, "google.com") && !strings.HasSuffix(host, "gstatic.com") {
+		return nil, false
+	}
+	dir, file := path.Split(u.Path)
+	kind := path.Base(dir)
+	if kind != "pushpin" && kind != "paddle" {
+		return nil, false
+	}
+
+	img, err := googleIcon(kind, strings.TrimSuffix(file, path.Ext(file)))
+	return img, err == nil
+}
+
+// googleIcon tints the bundled pushpin or paddle template to the color in
+// the icon name. Paddles without a color, such as the lettered ones, are blue
+// like Google's.
+func googleIcon(kind, name string) (image.Image, error) {
+	data, err := iconFiles.ReadFile("icons/" + kind + ".png")
+	if err != nil {
+		return nil, err
+	}
+	template, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	colorName, _, _ := strings.Cut(name, "-")
+	tint, ok := googleIconColors[colorName]
+	if !ok {
+		tint = googleIconColors["blu"]
+	}
+
+	bounds := template.Bounds()
+	tinted := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := template.At(x, y).RGBA()
+			tinted.Set(x, y, color.RGBA64{
+				R: uint16(r * uint32(tint.R) / 255),
+				G: uint16(g * uint32(tint.G) / 255),
+				B: uint16(b * uint32(tint.B) / 255),
+				A: uint16(a),
+			})
+		}
+	}
+	return tinted, nil
+}
+
+// iconCachePath returns where a downloaded icon is kept, next to the tile cache
+func iconCachePath(href string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(href))
+	return filepath.Join(homeDir, ".fiberforge", "iconcache", hex.EncodeToString(sum[:])), nil
+}
+
+// downloadIcon fetches an icon from the web, or from the icon cache if it
+// was downloaded before
+func downloadIcon(href string) (image.Image, error) {
+	cachePath, cacheErr := iconCachePath(href)
+	if cacheErr == nil {
+		if img, err := decodeIconFile(cachePath); err == nil {
+			return img, nil
+		}
+	}
+
+	resp, err := iconClient.Get(href)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheErr == nil {
+		if err := saveIconToDisk(cachePath, data); err != nil {
+			log.Printf("Failed to cache icon %s: %v\n", href, err)
+		}
+	}
+	log.Printf("Downloaded image: %s\n", href)
+	return img, nil
+}
+
+func saveIconToDisk(cachePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0644)
+}
+
+// absPath returns the absolute form of a path, so the same file loaded from
+// another working directory keys its icons the same, or the path as given if
+// that fails
+func absPath(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filename
+}
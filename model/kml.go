@@ -1,46 +1,44 @@
-package main
+package model
 
 import (
 	"archive/zip"
 	"bytes"
 	"encoding/xml"
 	"fmt"
-	"image"
 	"image/color"
 	"io"
 	"io/fs"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
 )
 
 type KML struct {
-	XMLName   xml.Name   `xml:"kml"`
-	Documents []Document `xml:"Document"`
-	Folders   []Folder   `xml:"Folder"` // Folders without a document
+	XMLName   xml.Name      `xml:"kml"`
+	Documents []KMLDocument `xml:"Document"`
+	Folders   []Folder      `xml:"Folder"` // Folders without a document
 }
 
-type Document struct {
-	XMLName    xml.Name    `xml:"Document"`
-	Folders    []Folder    `xml:"Folder"`
-	Documents  []Document  `xml:"Document"`  // Handle nested documents
-	Placemarks []Placemark `xml:"Placemark"` // Placemarks without a folder
-	Styles     []Style     `xml:"Style"`
-	StyleMaps  []StyleMap  `xml:"StyleMap"`
+type KMLDocument struct {
+	XMLName    xml.Name      `xml:"Document"`
+	Folders    []Folder      `xml:"Folder"`
+	Documents  []KMLDocument `xml:"Document"`  // Handle nested documents
+	Placemarks []Placemark   `xml:"Placemark"` // Placemarks without a folder
+	Styles     []Style       `xml:"Style"`
+	StyleMaps  []StyleMap    `xml:"StyleMap"`
 }
 
 type Folder struct {
-	XMLName    xml.Name    `xml:"Folder"`
-	Name       string      `xml:"name"`
-	Placemarks []Placemark `xml:"Placemark"`
-	Folders    []Folder    `xml:"Folder"`   // Handle nested folders
-	Documents  []Document  `xml:"Document"` // Handle nested documents
+	XMLName    xml.Name      `xml:"Folder"`
+	Name       string        `xml:"name"`
+	Placemarks []Placemark   `xml:"Placemark"`
+	Folders    []Folder      `xml:"Folder"`   // Handle nested folders
+	Documents  []KMLDocument `xml:"Document"` // Handle nested documents
 }
 
 type Placemark struct {
@@ -103,17 +101,20 @@ type Pair struct {
 */
 
 type Style struct {
-	XMLName   xml.Name  `xml:"Style"`
-	ID        string    `xml:"id,attr"`
-	IconStyle IconStyle `xml:"IconStyle"`
-	LineStyle LineStyle `xml:"LineStyle"`
+	XMLName    xml.Name   `xml:"Style"`
+	ID         string     `xml:"id,attr"`
+	IconStyle  IconStyle  `xml:"IconStyle"`
+	LabelStyle LabelStyle `xml:"LabelStyle"`
+	LineStyle  LineStyle  `xml:"LineStyle"`
+	PolyStyle  PolyStyle  `xml:"PolyStyle"`
 }
 
 type IconStyle struct {
-	Color   string  `xml:"color"`
-	Scale   float64 `xml:"scale"`
-	Icon    Icon    `xml:"Icon"`
-	HotSpot HotSpot `xml:"hotSpot"`
+	Color     string  `xml:"color"`
+	ColorMode string  `xml:"colorMode"`
+	Scale     float64 `xml:"scale"`
+	Icon      Icon    `xml:"Icon"`
+	HotSpot   HotSpot `xml:"hotSpot"`
 }
 
 type Icon struct {
@@ -128,20 +129,36 @@ type HotSpot struct {
 }
 
 type LineStyle struct {
-	Color string  `xml:"color"`
-	Width float64 `xml:"width"`
+	Color     string  `xml:"color"`
+	ColorMode string  `xml:"colorMode"`
+	Width     float64 `xml:"width"`
 }
 
-func processFoldersAndDocuments(folders []Folder, documents []Document, game *Game) error {
+// Fill and Outline are kept as text since leaving them out means 1
+type PolyStyle struct {
+	Color     string `xml:"color"`
+	ColorMode string `xml:"colorMode"`
+	Fill      string `xml:"fill"`
+	Outline   string `xml:"outline"`
+}
+
+// Scale is nil when not given, a scale of 0 hides the label
+type LabelStyle struct {
+	Color     string   `xml:"color"`
+	ColorMode string   `xml:"colorMode"`
+	Scale     *float64 `xml:"scale"`
+}
+
+func processFoldersAndDocuments(folders []Folder, documents []KMLDocument, doc *Document) error {
 	// Process Folders
 	for _, folder := range folders {
-		err := processPlacemarks(folder.Placemarks, game)
+		err := processPlacemarks(folder.Placemarks, doc)
 		if err != nil {
 			return err
 		}
 
 		// Recursively process nested folders and documents
-		err = processFoldersAndDocuments(folder.Folders, folder.Documents, game)
+		err = processFoldersAndDocuments(folder.Folders, folder.Documents, doc)
 		if err != nil {
 			return err
 		}
@@ -152,54 +169,39 @@ func processFoldersAndDocuments(folders []Folder, documents []Document, game *Ga
 		// Update the StyleMap for each Document.StyleMaps
 		convertedStyleMap := convertStyleMapsToMap(document.StyleMaps)
 		for id, pairs := range convertedStyleMap {
-			if _, exists := game.StyleMap[id]; !exists {
-				game.StyleMap[id] = pairs
+			if _, exists := doc.StyleMap[id]; !exists {
+				doc.StyleMap[id] = pairs
 				log.Printf("Added StyleMap %s - normal: %s, highlight: %s\n", id, pairs["normal"], pairs["highlight"])
 			} else {
 				for k, v := range pairs {
-					game.StyleMap[id][k] = v
+					doc.StyleMap[id][k] = v
 				}
 			}
 		}
 
-		// Update the convertedMap for each Document.Styles
-		convertedStyle := convertStylesToMap(document.Styles)
-		for id, styleEntry := range convertedStyle {
-			if _, exists := game.Styles[id]; !exists {
-				game.Styles[id] = styleEntry
-				//log.Printf("Added Style %s - Color: %s, Width: %f\n", id, styleEntry.Color, styleEntry.Width)
-			} else {
-				game.Styles[id] = styleEntry
-			}
-		}
-
-		// Update the IconStyles for each Document.Styles
-		convertedIconStyles := convertIconStylesToMap(document.Styles)
+		// Add the styles of the document, later ones replace earlier ones with the same id
 		newHrefs := make(map[string]bool)
-		for id, iconStyleEntry := range convertedIconStyles {
-			if _, exists := game.IconStyles[id]; !exists {
-				game.IconStyles[id] = iconStyleEntry
-				log.Printf("Added IconStyle %s - Color: %s, Scale: %f, Hotspot (%.0f, %.0f), Href: %s\n", id, iconStyleEntry.Color, iconStyleEntry.Scale, iconStyleEntry.HotSpot.X, iconStyleEntry.HotSpot.X, iconStyleEntry.Href)
-				if len(iconStyleEntry.Href) > 0 {
-					newHrefs[iconStyleEntry.Href] = true
-				}
+		for _, style := range document.Styles {
+			if _, exists := doc.Styles[style.ID]; !exists {
+				log.Printf("Added Style %s - Icon: %s, Line: %s, Poly: %s, Label: %s\n", style.ID, style.IconStyle.Icon.Href, style.LineStyle.Color, style.PolyStyle.Color, style.LabelStyle.Color)
+			}
+			doc.Styles[style.ID] = style
+			if len(style.IconStyle.Icon.Href) > 0 {
+				newHrefs[style.IconStyle.Icon.Href] = true
 			}
 		}
 
-		// Download and process new IconStyle images
-		err := downloadIconImages(game, newHrefs)
-		if err != nil {
-			return err
-		}
+		// Load new IconStyle images
+		loadIconImages(doc, newHrefs)
 
 		// Process Placemarks within the document with no folder
-		err = processPlacemarks(document.Placemarks, game)
+		err := processPlacemarks(document.Placemarks, doc)
 		if err != nil {
 			return err
 		}
 
 		// Recursively process folders and documents in the document
-		err = processFoldersAndDocuments(document.Folders, document.Documents, game)
+		err = processFoldersAndDocuments(document.Folders, document.Documents, doc)
 		if err != nil {
 			return err
 		}
@@ -208,54 +210,6 @@ func processFoldersAndDocuments(folders []Folder, documents []Document, game *Ga
 	return nil
 }
 
-func convertIconStylesToMap(styles []Style) map[string]IconStyleData {
-	convertedMap := make(map[string]IconStyleData)
-
-	for _, style := range styles {
-		convertedMap[style.ID] = IconStyleData{
-			ID:      style.ID,
-			Color:   style.IconStyle.Color,
-			Scale:   style.IconStyle.Scale,
-			Href:    style.IconStyle.Icon.Href,
-			HotSpot: style.IconStyle.HotSpot,
-		}
-	}
-
-	return convertedMap
-}
-
-func downloadIconImages(game *Game, hrefs map[string]bool) error {
-	if game.IconImages == nil {
-		game.IconImages = make(map[string]*ebiten.Image)
-	}
-
-	for href := range hrefs {
-		if _, exists := game.IconImages[href]; !exists {
-			img, err := downloadAndDecodeImage(href)
-			if err != nil {
-				return err
-			}
-			game.IconImages[href] = ebiten.NewImageFromImage(img)
-			log.Printf("Downloaded image: %s\n", href)
-		}
-	}
-	return nil
-}
-
-func downloadAndDecodeImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	img, _, err := image.Decode(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return img, nil
-}
-
 func convertStyleMapsToMap(styleMaps []StyleMap) map[string]map[string]string {
 	styleMapMap := make(map[string]map[string]string)
 
@@ -275,24 +229,11 @@ func convertStyleMapsToMap(styleMaps []StyleMap) map[string]map[string]string {
 	return styleMapMap
 }
 
-func convertStylesToMap(styles []Style) map[string]PolyLineStyle {
-	convertedMap := make(map[string]PolyLineStyle)
-
-	for _, style := range styles {
-		convertedMap[style.ID] = PolyLineStyle{
-			Color: style.LineStyle.Color,
-			Width: float32(style.LineStyle.Width),
-		}
-	}
-
-	return convertedMap
-}
-
 /*
-Sometimes there is an embedded style in the placemark
+Sometimes there is an embedded style in the placemark, on top of any styleUrl
 <Style><LineStyle><color>FF00ffff</color><width>5</width></LineStyle></Style>
 */
-func processPlacemarks(placemarks []Placemark, game *Game) error {
+func processPlacemarks(placemarks []Placemark, doc *Document) error {
 	for _, placemark := range placemarks {
 		var lineStrings []LineString
 		var points []Point
@@ -313,43 +254,15 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 			continue
 		}
 
+		style, highlight := doc.placemarkStyles(placemark)
+		layer := doc.featureLayer()
+
 		// Process lines
 		for _, lineString := range lineStrings {
 			rawLineString := strings.TrimSpace(lineString.Coordinates)
 			coordinates := strings.Split(strings.TrimSpace(rawLineString), " ")
 
-			var line PolyLine
-
-			styleURL := placemark.StyleURL
-			if len(styleURL) > 0 { // Either a StyleMap or Style link
-				if styleURL[0] == '#' {
-					styleURL = styleURL[1:] // Strip leading #
-				}
-
-				if _, exists := game.StyleMap[styleURL]; !exists { // Not a StyleMap link
-					line.Color, _ = hexStringToColor(game.Styles[styleURL].Color)
-					line.Width = game.Styles[styleURL].Width
-				} else { // StyleMap link
-					line.Color, _ = hexStringToColor(game.Styles[game.StyleMap[styleURL]["normal"]].Color)
-					line.Width = game.Styles[game.StyleMap[styleURL]["normal"]].Width
-				}
-			} else { // Embedded style?
-				if len(placemark.Style.LineStyle.Color) > 0 {
-					line.Color, _ = hexStringToColor(placemark.Style.LineStyle.Color)
-				} else {
-					line.Color = color.RGBA{0, 0, 0, 255}
-				}
-				if placemark.Style.LineStyle.Width > 0 {
-					line.Width = float32(placemark.Style.LineStyle.Width)
-				} else {
-					line.Width = 1.0
-				}
-			}
-
-			// Make sure we always have a minimum line width of 1
-			if line.Width < 1 {
-				line.Width = 1
-			}
+			line := PolyLine{Color: style.LineColor, Width: style.LineWidth, Highlight: highlight, Layer: layer}
 
 			for _, coordinate := range coordinates {
 				// In KMLs, longitude comes before latitude
@@ -367,14 +280,14 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 
 					dist := 0.0
 					if len(line.Points) > 0 {
-						dist = haversine(line.Points[len(line.Points)-1].Lat, line.Points[len(line.Points)-1].Lon, lat, lon, EarthRadiusFT)
+						dist = GeodesicDistance(line.Points[len(line.Points)-1].Lat, line.Points[len(line.Points)-1].Lon, lat, lon)
 
 					}
 					line.Points = append(line.Points, LinePoint{Lat: lat, Lon: lon, Dist: dist})
 				}
 			}
-			log.Printf("Added line with %d points, Style: %s, Line Width: %f\n", len(line.Points), styleURL, line.Width)
-			game.Lines = append(game.Lines, line)
+			log.Printf("Added line with %d points, Style: %s, Line Width: %f\n", len(line.Points), placemark.StyleURL, line.Width)
+			doc.AddLine(line)
 		}
 
 		// Process Polygons
@@ -387,7 +300,7 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 				coordinates = coordinates[:len(coordinates)-1]
 			}
 
-			var poly PolygonObject
+			poly := PolygonObject{Style: style, Highlight: highlight, Layer: layer}
 			for _, coordinate := range coordinates {
 				// In KMLs, longitude comes before latitude
 				latLon := strings.Split(coordinate, ",")
@@ -406,7 +319,7 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 				}
 			}
 			log.Printf("Added polygon with %d points\n", len(poly.Points))
-			game.Polygons = append(game.Polygons, poly)
+			doc.AddPolygon(poly)
 		}
 
 		/*// Process Points
@@ -424,7 +337,7 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 					return err
 				}
 
-				game.Points = append(game.Points, PointObject{Lat: lat, Lon: lon, Color: color.RGBA{255, 0, 0, 255}})
+				doc.AddPoint(PointObject{Lat: lat, Lon: lon, Color: color.RGBA{255, 0, 0, 255}})
 			}
 		}*/
 
@@ -443,39 +356,19 @@ func processPlacemarks(placemarks []Placemark, game *Game) error {
 					return err
 				}
 
-				styleURL := placemark.StyleURL
-				var iconHref string
-				var iconScale float64
-				var iconHotSpot HotSpot
-				if len(styleURL) > 0 {
-					if styleURL[0] == '#' {
-						styleURL = styleURL[1:] // Strip leading #
-					}
-
-					if _, exists := game.StyleMap[styleURL]; !exists { // Not a StyleMap link
-						iconHref = game.IconStyles[styleURL].Href
-						iconScale = game.IconStyles[styleURL].Scale
-						iconHotSpot = game.IconStyles[styleURL].HotSpot
-					} else { // StyleMap link
-						iconHref = game.IconStyles[game.StyleMap[styleURL]["normal"]].Href
-						iconScale = game.IconStyles[game.StyleMap[styleURL]["normal"]].Scale
-						iconHotSpot = game.IconStyles[game.StyleMap[styleURL]["normal"]].HotSpot
-					}
-				} else { // Embedded style?
-					iconHref = placemark.Style.IconStyle.Icon.Href
-					iconScale = placemark.Style.IconStyle.Scale
-					iconHotSpot = placemark.Style.IconStyle.HotSpot
-				}
-
-				iconImage := game.IconImages[iconHref]
-
-				game.Points = append(game.Points, PointObject{
-					Lat:       lat,
-					Lon:       lon,
-					Color:     color.RGBA{255, 0, 0, 255},
-					IconImage: iconImage,
-					Scale:     iconScale,
-					HotSpot:   iconHotSpot,
+				doc.AddPoint(PointObject{
+					Lat:        lat,
+					Lon:        lon,
+					Color:      color.RGBA{255, 0, 0, 255},
+					IconHref:   style.IconHref,
+					IconColor:  style.IconColor,
+					Scale:      style.IconScale,
+					HotSpot:    style.IconHotSpot,
+					Name:       strings.TrimSpace(placemark.Name),
+					LabelColor: style.LabelColor,
+					LabelScale: style.LabelScale,
+					Highlight:  highlight,
+					Layer:      layer,
 				})
 			}
 		}
@@ -517,10 +410,14 @@ func hexStringToColor(hex string) (color.RGBA, error) {
 	}, nil
 }
 
-func LoadKMLFile(filename string, game *Game) error {
+// LoadKMLFile loads a KML or KMZ file onto a layer named after the file
+func LoadKMLFile(filename string, doc *Document) error {
 	var kmlData []byte
 	var err error
 
+	doc.importLayer = fileLayer(filename)
+	defer func() { doc.importLayer = "" }()
+
 	if strings.HasSuffix(strings.ToLower(filename), ".kmz") {
 		// Read KMZ file
 		r, err := zip.OpenReader(filename)
@@ -529,6 +426,10 @@ func LoadKMLFile(filename string, game *Game) error {
 		}
 		defer r.Close()
 
+		// Icons packaged in the KMZ are referenced relative to it
+		doc.iconFS, doc.iconSource = &r.Reader, absPath(filename)
+		defer func() { doc.iconFS, doc.iconSource = nil, "" }()
+
 		// Find the KML file inside the KMZ archive
 		for _, f := range r.File {
 			if strings.HasSuffix(strings.ToLower(f.Name), ".kml") {
@@ -557,9 +458,13 @@ func LoadKMLFile(filename string, game *Game) error {
 		if err != nil {
 			return err
 		}
+
+		// Relative icon paths are next to the KML
+		doc.iconFS, doc.iconSource = os.DirFS(filepath.Dir(filename)), filepath.Dir(absPath(filename))
+		defer func() { doc.iconFS, doc.iconSource = nil, "" }()
 	}
 
-	err = LoadKML(kmlData, game)
+	err = LoadKML(kmlData, doc)
 	if err != nil {
 		return err
 	}
@@ -567,8 +472,11 @@ func LoadKMLFile(filename string, game *Game) error {
 	return nil
 }
 
-func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
+// LoadKMLDroppedFiles loads the files dropped on the window, each onto a
+// layer named after it
+func LoadKMLDroppedFiles(droppedFiles fs.FS, doc *Document) error {
 	var kmlData []byte
+	defer func() { doc.importLayer = "" }()
 
 	files, _ := fs.ReadDir(droppedFiles, ".")
 	for _, fileEntry := range files {
@@ -579,6 +487,7 @@ func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
 				continue
 			}
 			fileSize := fileInfo.Size()
+			doc.importLayer = fileLayer(fileEntry.Name())
 
 			file, err := droppedFiles.Open(fileEntry.Name())
 			if err != nil {
@@ -586,6 +495,27 @@ func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
 				continue
 			}
 
+			if strings.HasSuffix(strings.ToLower(fileEntry.Name()), ".csv") {
+				csvData, err := io.ReadAll(file)
+				if err != nil {
+					return err
+				}
+				if err := LoadCSV(csvData, doc); err != nil {
+					return fmt.Errorf("%s: %v", fileEntry.Name(), err)
+				}
+				continue
+			}
+			if strings.HasSuffix(strings.ToLower(fileEntry.Name()), ".gpx") {
+				gpxData, err := io.ReadAll(file)
+				if err != nil {
+					return err
+				}
+				if err := LoadGPX(gpxData, doc); err != nil {
+					return fmt.Errorf("%s: %v", fileEntry.Name(), err)
+				}
+				continue
+			}
+
 			if strings.HasSuffix(strings.ToLower(fileEntry.Name()), ".kmz") {
 				// Read KMZ file
 				content, err := io.ReadAll(file)
@@ -598,6 +528,7 @@ func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
 				if err != nil {
 					return err
 				}
+				doc.iconFS, doc.iconSource = r, fileEntry.Name()
 
 				// Find the KML file inside the KMZ archive
 				for _, f := range r.File {
@@ -627,9 +558,11 @@ func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
 				if err != nil {
 					return err
 				}
+				doc.iconFS = droppedFiles
 			}
 
-			err = LoadKML(kmlData, game)
+			err = LoadKML(kmlData, doc)
+			doc.iconFS, doc.iconSource, kmlData = nil, "", nil
 			if err != nil {
 				return err
 			}
@@ -639,7 +572,7 @@ func LoadKMLDroppedFiles(droppedFiles fs.FS, game *Game) error {
 	return nil
 }
 
-func LoadKML(kmlData []byte, game *Game) error {
+func LoadKML(kmlData []byte, doc *Document) error {
 	var err error
 
 	// Check if the data is UTF-16 encoded and convert it to UTF-8 if necessary
@@ -674,16 +607,19 @@ func LoadKML(kmlData []byte, game *Game) error {
 	}
 
 	// Process the Folders at the KML level
-	err = processFoldersAndDocuments(kml.Folders, nil, game)
+	err = processFoldersAndDocuments(kml.Folders, nil, doc)
 	if err != nil {
 		return err
 	}
 
 	// Process the Documents at the KML level
-	err = processFoldersAndDocuments(nil, kml.Documents, game)
+	err = processFoldersAndDocuments(nil, kml.Documents, doc)
 	if err != nil {
 		return err
 	}
 
+	// Styles are updated in place rather than through the document
+	doc.Changed()
+
 	return nil
 }
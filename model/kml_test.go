@@ -0,0 +1,182 @@
+package model
+
+import (
+	"archive/zip"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+	<Style id="drop">
+		<LineStyle><color>ff0000ff</color><width>3</width></LineStyle>
+		<IconStyle><Icon><href>http://maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png</href></Icon></IconStyle>
+	</Style>
+	<Folder>
+		<name>Drops</name>
+		<Placemark>
+			<name>HH-1</name>
+			<styleUrl>#drop</styleUrl>
+			<Point><coordinates>-90.051911,35.156072,0</coordinates></Point>
+		</Placemark>
+		<Placemark>
+			<styleUrl>#drop</styleUrl>
+			<LineString><coordinates>-90.051911,35.156072,0 -90.05,35.157,0</coordinates></LineString>
+		</Placemark>
+		<Placemark>
+			<Polygon><outerBoundaryIs><LinearRing><coordinates>
+				-90.052,35.156 -90.051,35.156 -90.051,35.157 -90.052,35.156
+			</coordinates></LinearRing></outerBoundaryIs></Polygon>
+		</Placemark>
+	</Folder>
+</Document>
+</kml>`
+
+func TestLoadKMLFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "drops.kml")
+	if err := os.WriteFile(filename, []byte(testKML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := NewDocument()
+	changes := 0
+	doc.OnChange(func() { changes++ })
+
+	if err := LoadKMLFile(filename, doc); err != nil {
+		t.Fatalf("LoadKMLFile: %v", err)
+	}
+	if len(doc.Points) != 1 || len(doc.Lines) != 1 || len(doc.Polygons) != 1 {
+		t.Fatalf("got %d points, %d lines and %d polygons, want 1 of each", len(doc.Points), len(doc.Lines), len(doc.Polygons))
+	}
+	if changes == 0 {
+		t.Error("loading did not notify the listeners")
+	}
+
+	point := doc.Points[0]
+	if point.Name != "HH-1" || point.Lat != 35.156072 || point.Lon != -90.051911 {
+		t.Errorf("point is %q at %f, %f", point.Name, point.Lat, point.Lon)
+	}
+	if point.IconHref == "" || doc.Icons[point.IconHref] == nil {
+		t.Errorf("point icon %q was not loaded", point.IconHref)
+	}
+
+	line := doc.Lines[0]
+	if want := (color.RGBA{255, 0, 0, 255}); line.Color != want || line.Width != 3 {
+		t.Errorf("line color %v width %g, want %v width 3", line.Color, line.Width, want)
+	}
+	if length := LineLength(line.Points); length < 150 || length > 250 {
+		t.Errorf("line length %.1f m, want about 200 m", length)
+	}
+
+	// The features go on a layer named after the file
+	if line.Layer != point.Layer || doc.layerName(line.Layer) != "drops" {
+		t.Errorf("features on layer %q, want drops", doc.layerName(line.Layer))
+	}
+	if len(doc.Layers) != 2 {
+		t.Errorf("got %d layers, want the default layer and drops", len(doc.Layers))
+	}
+}
+
+func TestLayerVisibility(t *testing.T) {
+	doc := NewDocument()
+	layer := doc.Layer("Poles")
+	if doc.Layer("poles") != layer {
+		t.Error("layer names should ignore case")
+	}
+	if err := doc.SetLayerVisible("POLES", false); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Visible(layer) || !doc.Visible(0) {
+		t.Error("only the hidden layer should be invisible")
+	}
+	if err := doc.SetLayerVisible("missing", true); err == nil {
+		t.Error("expected an error for a missing layer")
+	}
+}
+
+// writeKMZ writes a KMZ holding a placemark with the icon files/icon.png, a
+// square of the color given
+func writeKMZ(t *testing.T, filename string, clr color.RGBA) {
+	t.Helper()
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+
+	w, err := archive.Create("doc.kml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kml := strings.NewReplacer("http://maps.google.com/mapfiles/kml/pushpin/ylw-pushpin.png", "files/icon.png").Replace(testKML)
+	if _, err := w.Write([]byte(kml)); err != nil {
+		t.Fatal(err)
+	}
+
+	icon := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			icon.SetRGBA(x, y, clr)
+		}
+	}
+	w, err = archive.Create("files/icon.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(w, icon); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKMZIconsBySource(t *testing.T) {
+	dir := t.TempDir()
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	writeKMZ(t, filepath.Join(dir, "north.kmz"), red)
+	writeKMZ(t, filepath.Join(dir, "south.kmz"), blue)
+
+	// Both use files/icon.png, each its own
+	doc := NewDocument()
+	for _, name := range []string{"north.kmz", "south.kmz"} {
+		if err := LoadKMLFile(filepath.Join(dir, name), doc); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if len(doc.Points) != 2 {
+		t.Fatalf("got %d points, want one from each KMZ", len(doc.Points))
+	}
+	north, south := doc.Points[0].IconHref, doc.Points[1].IconHref
+	if north == south {
+		t.Fatalf("both points use icon %q", north)
+	}
+	if want := filepath.Join(dir, "north.kmz") + "/files/icon.png"; north != want {
+		t.Errorf("north icon keyed %q, want %q", north, want)
+	}
+	for href, want := range map[string]color.RGBA{north: red, south: blue} {
+		img := doc.Icons[href]
+		if img == nil {
+			t.Errorf("icon %q not loaded", href)
+			continue
+		}
+		if got := color.RGBAModel.Convert(img.At(1, 1)); got != want {
+			t.Errorf("icon %q is %v, want %v", href, got, want)
+		}
+	}
+
+	// Web icons are the same whichever file uses them
+	if got := doc.iconKey("http://maps.google.com/mapfiles/kml/paddle/red-circle.png"); got != "http://maps.google.com/mapfiles/kml/paddle/red-circle.png" {
+		t.Errorf("web icon keyed %q", got)
+	}
+}
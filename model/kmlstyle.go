@@ -0,0 +1,197 @@
+package model
+
+import (
+	"image/color"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+// FeatureStyle is a KML style resolved to what is drawn: the line, the
+// polygon fill and outline, the icon and the label. Features keep the style
+// they are drawn with, plus the highlight style of their StyleMap, if any,
+// to draw while the mouse is over them.
+type FeatureStyle struct {
+	LineColor   color.RGBA
+	LineWidth   float32
+	FillColor   color.RGBA
+	Fill        bool       // Fill polygons
+	Outline     bool       // Outline polygons with the line color and width
+	IconHref    string     // Key of the icon in Document.Icons
+	IconColor   color.RGBA // Multiplied with the icon, zero for none
+	IconScale   float64
+	IconHotSpot HotSpot
+	LabelColor  color.RGBA
+	LabelScale  float64 // 0 hides the label
+}
+
+var (
+	defaultLineColor    = color.RGBA{0, 0, 0, 255}
+	defaultPolygonColor = color.RGBA{0x00, 0xff, 0x00, 0x4D}
+	DefaultLabelColor   = color.RGBA{255, 255, 255, 255}
+)
+
+// DefaultPolygonStyle is how polygons without a style, like those drawn with
+// POL, are drawn
+func DefaultPolygonStyle() FeatureStyle {
+	return FeatureStyle{
+		LineColor: defaultLineColor,
+		LineWidth: 2,
+		FillColor: defaultPolygonColor,
+		Fill:      true,
+		Outline:   true,
+	}
+}
+
+// lookupStyle finds the shared style a styleUrl points to. A StyleMap link
+// gives the style of its normal or highlight pair, a plain Style link only
+// has a normal style.
+func (d *Document) lookupStyle(styleURL, key string) (Style, bool) {
+	styleURL = strings.TrimPrefix(strings.TrimSpace(styleURL), "#")
+	if styleURL == "" {
+		return Style{}, false
+	}
+
+	id := styleURL
+	if pairs, exists := d.StyleMap[styleURL]; exists {
+		id = pairs[key]
+	} else if key != "normal" {
+		return Style{}, false
+	}
+
+	style, exists := d.Styles[id]
+	return style, exists
+}
+
+// placemarkStyles resolves the normal and highlight styles of a placemark.
+// The placemark's inline style overrides the shared style it links to.
+func (d *Document) placemarkStyles(placemark Placemark) (FeatureStyle, *FeatureStyle) {
+	normal, _ := d.lookupStyle(placemark.StyleURL, "normal")
+	normalStyle := d.resolveStyle(mergeStyle(normal, placemark.Style))
+
+	highlight, exists := d.lookupStyle(placemark.StyleURL, "highlight")
+	if !exists {
+		return normalStyle, nil
+	}
+	highlightStyle := d.resolveStyle(mergeStyle(highlight, placemark.Style))
+	return normalStyle, &highlightStyle
+}
+
+// mergeStyle overrides the elements of a shared style with those given in an
+// inline one
+func mergeStyle(base, inline Style) Style {
+	merged := base
+
+	if inline.LineStyle.Color != "" {
+		merged.LineStyle.Color = inline.LineStyle.Color
+	}
+	if inline.LineStyle.ColorMode != "" {
+		merged.LineStyle.ColorMode = inline.LineStyle.ColorMode
+	}
+	if inline.LineStyle.Width > 0 {
+		merged.LineStyle.Width = inline.LineStyle.Width
+	}
+
+	if inline.PolyStyle.Color != "" {
+		merged.PolyStyle.Color = inline.PolyStyle.Color
+	}
+	if inline.PolyStyle.ColorMode != "" {
+		merged.PolyStyle.ColorMode = inline.PolyStyle.ColorMode
+	}
+	if inline.PolyStyle.Fill != "" {
+		merged.PolyStyle.Fill = inline.PolyStyle.Fill
+	}
+	if inline.PolyStyle.Outline != "" {
+		merged.PolyStyle.Outline = inline.PolyStyle.Outline
+	}
+
+	if inline.IconStyle.Color != "" {
+		merged.IconStyle.Color = inline.IconStyle.Color
+	}
+	if inline.IconStyle.ColorMode != "" {
+		merged.IconStyle.ColorMode = inline.IconStyle.ColorMode
+	}
+	if inline.IconStyle.Scale > 0 {
+		merged.IconStyle.Scale = inline.IconStyle.Scale
+	}
+	if inline.IconStyle.Icon.Href != "" {
+		merged.IconStyle.Icon = inline.IconStyle.Icon
+	}
+	if inline.IconStyle.HotSpot != (HotSpot{}) {
+		merged.IconStyle.HotSpot = inline.IconStyle.HotSpot
+	}
+
+	if inline.LabelStyle.Color != "" {
+		merged.LabelStyle.Color = inline.LabelStyle.Color
+	}
+	if inline.LabelStyle.ColorMode != "" {
+		merged.LabelStyle.ColorMode = inline.LabelStyle.ColorMode
+	}
+	if inline.LabelStyle.Scale != nil {
+		merged.LabelStyle.Scale = inline.LabelStyle.Scale
+	}
+
+	return merged
+}
+
+// resolveStyle turns a KML style into the colors and sizes drawn. Random color
+// modes are picked here, so each feature gets its own color.
+func (d *Document) resolveStyle(style Style) FeatureStyle {
+	resolved := FeatureStyle{
+		LineColor:   styleColor(style.LineStyle.Color, style.LineStyle.ColorMode, defaultLineColor),
+		LineWidth:   float32(style.LineStyle.Width),
+		FillColor:   styleColor(style.PolyStyle.Color, style.PolyStyle.ColorMode, defaultPolygonColor),
+		Fill:        kmlBool(style.PolyStyle.Fill),
+		Outline:     kmlBool(style.PolyStyle.Outline),
+		IconHref:    loadIconImage(d, style.IconStyle.Icon.Href),
+		IconScale:   style.IconStyle.Scale,
+		IconHotSpot: style.IconStyle.HotSpot,
+		LabelColor:  styleColor(style.LabelStyle.Color, style.LabelStyle.ColorMode, DefaultLabelColor),
+		LabelScale:  1,
+	}
+
+	// Make sure we always have a minimum line width of 1
+	if resolved.LineWidth < 1 {
+		resolved.LineWidth = 1
+	}
+	if style.IconStyle.Color != "" || style.IconStyle.ColorMode != "" {
+		resolved.IconColor = styleColor(style.IconStyle.Color, style.IconStyle.ColorMode, color.RGBA{255, 255, 255, 255})
+	}
+	if style.LabelStyle.Scale != nil {
+		resolved.LabelScale = *style.LabelStyle.Scale
+	}
+
+	return resolved
+}
+
+// styleColor reads a KML aabbggrr color, using the fallback when there is none
+func styleColor(hex, colorMode string, fallback color.RGBA) color.RGBA {
+	clr := fallback
+	if hex = strings.TrimSpace(hex); hex != "" {
+		parsed, err := hexStringToColor(strings.TrimPrefix(hex, "#"))
+		if err != nil {
+			log.Printf("Invalid KML color %q: %v\n", hex, err)
+		} else {
+			clr = parsed
+		}
+	}
+
+	// A random color mode scales each color component by a random amount,
+	// keeping the alpha
+	if strings.TrimSpace(colorMode) == "random" {
+		clr.R = uint8(rand.Float64() * float64(clr.R))
+		clr.G = uint8(rand.Float64() * float64(clr.G))
+		clr.B = uint8(rand.Float64() * float64(clr.B))
+	}
+	return clr
+}
+
+// kmlBool reads a KML boolean such as fill or outline, which are on unless
+// given as 0 or false
+func kmlBool(value string) bool {
+	switch strings.TrimSpace(value) {
+	case "0", "false":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+package model
+
+import (
+	"image/color"
+	"testing"
+)
+
+// testStyleKML has a StyleMap with normal and highlight styles, plain shared
+// styles and placemarks overriding parts of them inline
+const testStyleKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+	<Style id="dropNormal">
+		<LineStyle><color>ff0000ff</color><width>3</width></LineStyle>
+		<IconStyle><scale>1.2</scale><Icon><href>http://maps.google.com/mapfiles/kml/paddle/red-circle.png</href></Icon></IconStyle>
+		<LabelStyle><scale>0.8</scale></LabelStyle>
+	</Style>
+	<Style id="dropHighlight">
+		<LineStyle><color>ff00ffff</color><width>6</width></LineStyle>
+		<LabelStyle><color>ff00ffff</color><scale>1.5</scale></LabelStyle>
+	</Style>
+	<StyleMap id="drop">
+		<Pair><key>normal</key><styleUrl>#dropNormal</styleUrl></Pair>
+		<Pair><key>highlight</key><styleUrl>#dropHighlight</styleUrl></Pair>
+	</StyleMap>
+	<Style id="parcel">
+		<LineStyle><color>ffff0000</color><width>2</width></LineStyle>
+		<PolyStyle><color>4d00ff00</color><fill>0</fill><outline>1</outline></PolyStyle>
+	</Style>
+	<Style id="quiet">
+		<LabelStyle><scale>0</scale></LabelStyle>
+	</Style>
+	<Style id="random">
+		<LineStyle><color>80ffffff</color><colorMode>random</colorMode></LineStyle>
+	</Style>
+	<Placemark>
+		<name>Drop</name>
+		<styleUrl>#drop</styleUrl>
+		<LineString><coordinates>-90.05,35.15 -90.04,35.15</coordinates></LineString>
+	</Placemark>
+	<Placemark>
+		<name>Wide drop</name>
+		<styleUrl>#drop</styleUrl>
+		<Style><LineStyle><width>5</width></LineStyle></Style>
+		<LineString><coordinates>-90.05,35.16 -90.04,35.16</coordinates></LineString>
+	</Placemark>
+	<Placemark>
+		<name>Lot</name>
+		<styleUrl>#parcel</styleUrl>
+		<Style><PolyStyle><color>80ff0000</color></PolyStyle></Style>
+		<Polygon><outerBoundaryIs><LinearRing><coordinates>
+			-90.05,35.15 -90.04,35.15 -90.04,35.16 -90.05,35.15
+		</coordinates></LinearRing></outerBoundaryIs></Polygon>
+	</Placemark>
+	<Placemark>
+		<name>Unstyled lot</name>
+		<Style><PolyStyle><outline>false</outline></PolyStyle></Style>
+		<Polygon><outerBoundaryIs><LinearRing><coordinates>
+			-90.05,35.15 -90.04,35.15 -90.04,35.16 -90.05,35.15
+		</coordinates></LinearRing></outerBoundaryIs></Polygon>
+	</Placemark>
+	<Placemark>
+		<name>HH-1</name>
+		<styleUrl>#drop</styleUrl>
+		<Point><coordinates>-90.05,35.15,0</coordinates></Point>
+	</Placemark>
+	<Placemark>
+		<name>Unlabeled</name>
+		<styleUrl>#quiet</styleUrl>
+		<Point><coordinates>-90.04,35.15,0</coordinates></Point>
+	</Placemark>
+	<Placemark>
+		<name>Plain</name>
+		<Point><coordinates>-90.03,35.15,0</coordinates></Point>
+	</Placemark>
+	<Placemark><styleUrl>#random</styleUrl><LineString><coordinates>-90.05,35.17 -90.04,35.17</coordinates></LineString></Placemark>
+	<Placemark><styleUrl>#random</styleUrl><LineString><coordinates>-90.05,35.17 -90.04,35.17</coordinates></LineString></Placemark>
+	<Placemark><styleUrl>#random</styleUrl><LineString><coordinates>-90.05,35.17 -90.04,35.17</coordinates></LineString></Placemark>
+</Document>
+</kml>`
+
+var (
+	red    = color.RGBA{255, 0, 0, 255}
+	yellow = color.RGBA{255, 255, 0, 255}
+	blue   = color.RGBA{0, 0, 255, 255}
+)
+
+func loadStyleKML(t *testing.T) *Document {
+	t.Helper()
+	doc := NewDocument()
+	doc.LoadIcons = false
+	if err := LoadKML([]byte(testStyleKML), doc); err != nil {
+		t.Fatalf("LoadKML: %v", err)
+	}
+	if len(doc.Lines) != 5 || len(doc.Polygons) != 2 || len(doc.Points) != 3 {
+		t.Fatalf("got %d lines, %d polygons and %d points, want 5, 2 and 3", len(doc.Lines), len(doc.Polygons), len(doc.Points))
+	}
+	return doc
+}
+
+func TestStyleMapHighlight(t *testing.T) {
+	doc := loadStyleKML(t)
+
+	drop := doc.Lines[0]
+	if drop.Color != red || drop.Width != 3 {
+		t.Errorf("drop drawn %v width %g, want the normal style %v width 3", drop.Color, drop.Width, red)
+	}
+	if drop.Highlight == nil {
+		t.Fatal("drop has no highlight style")
+	}
+	if drop.Highlight.LineColor != yellow || drop.Highlight.LineWidth != 6 {
+		t.Errorf("drop highlighted %v width %g, want %v width 6", drop.Highlight.LineColor, drop.Highlight.LineWidth, yellow)
+	}
+
+	// The highlight style stands alone, it does not inherit the normal icon
+	point := doc.Points[0]
+	if point.IconHref == "" || point.Scale != 1.2 || point.LabelScale != 0.8 {
+		t.Errorf("point icon %q scale %g, label scale %g", point.IconHref, point.Scale, point.LabelScale)
+	}
+	if point.Highlight == nil || point.Highlight.IconHref != "" || point.Highlight.LabelColor != yellow || point.Highlight.LabelScale != 1.5 {
+		t.Errorf("point highlight %+v, want a yellow label at 1.5 and no icon", point.Highlight)
+	}
+
+	// A plain style has no highlight
+	if lot := doc.Polygons[0]; lot.Highlight != nil {
+		t.Errorf("lot with a plain style has highlight %+v", lot.Highlight)
+	}
+}
+
+func TestInlineStyleOverridesShared(t *testing.T) {
+	doc := loadStyleKML(t)
+
+	// The inline width applies to both the normal and highlight styles,
+	// keeping their colors
+	wide := doc.Lines[1]
+	if wide.Color != red || wide.Width != 5 {
+		t.Errorf("wide drop drawn %v width %g, want %v width 5", wide.Color, wide.Width, red)
+	}
+	if wide.Highlight == nil || wide.Highlight.LineColor != yellow || wide.Highlight.LineWidth != 5 {
+		t.Errorf("wide drop highlight %+v, want %v width 5", wide.Highlight, yellow)
+	}
+
+	// The inline fill color keeps the shared fill and outline flags and line
+	lot := doc.Polygons[0].Style
+	if want := (color.RGBA{0, 0, 255, 0x80}); lot.FillColor != want {
+		t.Errorf("lot fill %v, want the inline %v", lot.FillColor, want)
+	}
+	if lot.Fill || !lot.Outline || lot.LineColor != blue || lot.LineWidth != 2 {
+		t.Errorf("lot fill %v, outline %v in %v width %g, want outline only in %v width 2", lot.Fill, lot.Outline, lot.LineColor, lot.LineWidth, blue)
+	}
+}
+
+func TestFillOutlineDefaults(t *testing.T) {
+	doc := loadStyleKML(t)
+
+	// Fill and outline are on unless turned off, and colors fall back to the defaults
+	unstyled := doc.Polygons[1].Style
+	if !unstyled.Fill || unstyled.Outline {
+		t.Errorf("unstyled lot fill %v, outline %v, want fill only", unstyled.Fill, unstyled.Outline)
+	}
+	if unstyled.FillColor != defaultPolygonColor || unstyled.LineColor != defaultLineColor || unstyled.LineWidth != 1 {
+		t.Errorf("unstyled lot %+v, want the default colors and width 1", unstyled)
+	}
+
+	for _, value := range []string{"", "1", "true", " 1 "} {
+		if !kmlBool(value) {
+			t.Errorf("kmlBool(%q) is false", value)
+		}
+	}
+	for _, value := range []string{"0", "false", " 0\n"} {
+		if kmlBool(value) {
+			t.Errorf("kmlBool(%q) is true", value)
+		}
+	}
+}
+
+func TestLabelScale(t *testing.T) {
+	doc := loadStyleKML(t)
+
+	// A label scale of 0 hides the label rather than being taken as unset
+	if scale := doc.Points[1].LabelScale; scale != 0 {
+		t.Errorf("label scale %g, want 0", scale)
+	}
+	if plain := doc.Points[2]; plain.LabelScale != 1 || plain.LabelColor != DefaultLabelColor {
+		t.Errorf("unstyled label %v at %g, want %v at 1", plain.LabelColor, plain.LabelScale, DefaultLabelColor)
+	}
+
+	// Nor is an inline scale of 0 passed over when merging
+	zero, shared := 0.0, 0.8
+	merged := mergeStyle(Style{LabelStyle: LabelStyle{Scale: &shared}}, Style{LabelStyle: LabelStyle{Scale: &zero}})
+	if merged.LabelStyle.Scale == nil || *merged.LabelStyle.Scale != 0 {
+		t.Errorf("merged label scale %v, want 0", merged.LabelStyle.Scale)
+	}
+}
+
+func TestRandomColorMode(t *testing.T) {
+	doc := loadStyleKML(t)
+
+	// Each feature picks its own color, no brighter than the style's and with
+	// its alpha
+	colors := make(map[color.RGBA]bool)
+	for _, line := range doc.Lines[2:] {
+		if line.Color.A != 0x80 {
+			t.Errorf("random color %v lost the alpha", line.Color)
+		}
+		colors[line.Color] = true
+	}
+	if len(colors) == 1 {
+		t.Errorf("random color mode gave every line %v", doc.Lines[2].Color)
+	}
+
+	// Random scales the components, so black stays black
+	for i := 0; i < 10; i++ {
+		if clr := styleColor("ff000000", "random", red); clr != (color.RGBA{0, 0, 0, 255}) {
+			t.Fatalf("random black came out %v", clr)
+		}
+	}
+}
+
+func TestMergeStyle(t *testing.T) {
+	base := Style{
+		LineStyle:  LineStyle{Color: "ff0000ff", Width: 3},
+		PolyStyle:  PolyStyle{Color: "4d00ff00", Fill: "0"},
+		IconStyle:  IconStyle{Scale: 1.2, Icon: Icon{Href: "files/pole.png"}, HotSpot: HotSpot{X: 0.5, XUnits: "fraction"}},
+		LabelStyle: LabelStyle{Color: "ffffffff"},
+	}
+
+	// An empty inline style changes nothing
+	if merged := mergeStyle(base, Style{}); merged.LineStyle != base.LineStyle || merged.PolyStyle != base.PolyStyle || merged.IconStyle != base.IconStyle || merged.LabelStyle != base.LabelStyle {
+		t.Errorf("merging nothing gave %+v", merged)
+	}
+
+	inline := Style{
+		LineStyle: LineStyle{ColorMode: "random"},
+		PolyStyle: PolyStyle{Fill: "1", Outline: "0"},
+		IconStyle: IconStyle{Icon: Icon{Href: "files/handhole.png"}},
+	}
+	merged := mergeStyle(base, inline)
+	want := base
+	want.LineStyle.ColorMode = "random"
+	want.PolyStyle.Fill, want.PolyStyle.Outline = "1", "0"
+	want.IconStyle.Icon.Href = "files/handhole.png"
+	if merged.LineStyle != want.LineStyle || merged.PolyStyle != want.PolyStyle || merged.IconStyle != want.IconStyle {
+		t.Errorf("merged %+v, want %+v", merged, want)
+	}
+}
+
+func TestResolveStyle(t *testing.T) {
+	doc := NewDocument()
+	doc.LoadIcons = false
+
+	resolved := doc.resolveStyle(Style{
+		LineStyle: LineStyle{Color: "not a color", Width: 0.5},
+		IconStyle: IconStyle{Color: "ff0000ff", HotSpot: HotSpot{X: 0.5, Y: 0, XUnits: "fraction", YUnits: "fraction"}},
+	})
+	if resolved.LineColor != defaultLineColor || resolved.LineWidth != 1 {
+		t.Errorf("line %v width %g, want the default color and at least 1", resolved.LineColor, resolved.LineWidth)
+	}
+	if resolved.IconColor != red || resolved.IconHotSpot.X != 0.5 || resolved.IconHref != "" {
+		t.Errorf("icon %q tinted %v hot spot %+v", resolved.IconHref, resolved.IconColor, resolved.IconHotSpot)
+	}
+
+	// Without an icon color the icon is drawn as it is
+	if resolved := doc.resolveStyle(Style{IconStyle: IconStyle{Scale: 2}}); resolved.IconColor != (color.RGBA{}) {
+		t.Errorf("icon tinted %v with no color given", resolved.IconColor)
+	}
+}
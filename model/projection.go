@@ -0,0 +1,451 @@
+package model
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A small pure Go stand-in for PROJ covering the coordinate systems our
+// drawings and survey data come in: geographic lat/lon, Web Mercator, UTM and
+// the Transverse Mercator and Lambert Conformal Conic State Plane zones listed
+// in epsg.txt. NAD83 is treated as identical to WGS84, the difference is well
+// under the accuracy of the imagery we draw over.
+
+//go:embed epsg.txt
+var epsgDefinitions string
+
+const (
+	USSurveyFoot      = 1200.0 / 3937.0 // Meters per US survey foot
+	InternationalFoot = 0.3048          // Meters per international foot
+)
+
+type Ellipsoid struct {
+	A float64 // Semi-major axis in meters
+	F float64 // Flattening
+}
+
+var (
+	WGS84     = Ellipsoid{A: 6378137.0, F: 1 / 298.257223563}
+	GRS80     = Ellipsoid{A: 6378137.0, F: 1 / 298.257222101}
+	Clarke66  = Ellipsoid{A: 6378206.4, F: 1 / 294.978698214}
+	ellipsoid = map[string]Ellipsoid{"WGS84": WGS84, "GRS80": GRS80, "clrk66": Clarke66}
+)
+
+func (e Ellipsoid) E2() float64 {
+	return e.F * (2 - e.F)
+}
+
+// Projection converts between geographic coordinates in degrees and projected
+// coordinates in meters, false easting and northing included
+type Projection interface {
+	Forward(lat, lon float64) (x, y float64)
+	Inverse(x, y float64) (lat, lon float64)
+}
+
+// CRS is a coordinate reference system identified by its EPSG code
+type CRS struct {
+	Code        int
+	Name        string
+	Unit        string  // "deg", "m", "ftUS" or "ft"
+	UnitToMeter float64 // Meters per unit, 0 for geographic systems
+	proj        Projection
+}
+
+func (crs *CRS) String() string {
+	return fmt.Sprintf("EPSG:%d %s", crs.Code, crs.Name)
+}
+
+// IsGeographic reports whether the CRS works in degrees of lat/lon
+func (crs *CRS) IsGeographic() bool {
+	return crs.proj == nil
+}
+
+// FromLatLng converts a WGS84 location to x, y in the CRS units. Geographic
+// systems return lon, lat in that order like every other GIS.
+func (crs *CRS) FromLatLng(lat, lon float64) (float64, float64) {
+	if crs.IsGeographic() {
+		return lon, lat
+	}
+	x, y := crs.proj.Forward(lat, lon)
+	return x / crs.UnitToMeter, y / crs.UnitToMeter
+}
+
+// ToLatLng converts x, y in the CRS units to a WGS84 location
+func (crs *CRS) ToLatLng(x, y float64) (float64, float64) {
+	if crs.IsGeographic() {
+		return y, x
+	}
+	return crs.proj.Inverse(x*crs.UnitToMeter, y*crs.UnitToMeter)
+}
+
+var epsgRegistry = loadEPSGRegistry()
+
+// LookupCRS returns the coordinate system for an EPSG code
+func LookupCRS(code int) (*CRS, error) {
+	crs, ok := epsgRegistry[code]
+	if !ok {
+		return nil, fmt.Errorf("unsupported coordinate system EPSG:%d", code)
+	}
+	return crs, nil
+}
+
+// ParseCRS accepts "2274", "EPSG:2274" or "epsg:2274"
+func ParseCRS(s string) (*CRS, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 5 && strings.EqualFold(s[:5], "EPSG:") {
+		s = s[5:]
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPSG code %q", s)
+	}
+	return LookupCRS(code)
+}
+
+// ParseCRSArgument parses the EPSG code typed after a CRS command, nothing or
+// 4326 meaning plain WGS84 lat/lon
+func ParseCRSArgument(arg string) (*CRS, error) {
+	if arg == "" || arg == "4326" || strings.EqualFold(arg, "EPSG:4326") {
+		return nil, nil
+	}
+	return ParseCRS(arg)
+}
+
+func loadEPSGRegistry() map[int]*CRS {
+	registry := make(map[int]*CRS)
+
+	// UTM zones are generated rather than listed
+	for zone := 1; zone <= 60; zone++ {
+		north := newUTM(zone, false, WGS84)
+		south := newUTM(zone, true, WGS84)
+		registry[32600+zone] = &CRS{Code: 32600 + zone, Name: fmt.Sprintf("WGS 84 / UTM zone %dN", zone), Unit: "m", UnitToMeter: 1, proj: north}
+		registry[32700+zone] = &CRS{Code: 32700 + zone, Name: fmt.Sprintf("WGS 84 / UTM zone %dS", zone), Unit: "m", UnitToMeter: 1, proj: south}
+	}
+	for zone := 1; zone <= 23; zone++ {
+		registry[26900+zone] = &CRS{Code: 26900 + zone, Name: fmt.Sprintf("NAD83 / UTM zone %dN", zone), Unit: "m", UnitToMeter: 1, proj: newUTM(zone, false, GRS80)}
+	}
+
+	definitions, err := parseEPSGDefinitions(epsgDefinitions)
+	if err != nil {
+		log.Printf("epsg.txt: %v\n", err)
+	}
+	for code, crs := range definitions {
+		registry[code] = crs
+	}
+
+	return registry
+}
+
+// parseEPSGDefinitions reads "code | name | PROJ definition" lines as in
+// epsg.txt. Malformed lines are skipped and reported together in the error,
+// so one bad line doesn't lose every other coordinate system.
+func parseEPSGDefinitions(data string) (map[int]*CRS, error) {
+	definitions := make(map[int]*CRS)
+	var errs []error
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 3 {
+			errs = append(errs, fmt.Errorf("line %d: want code | name | definition, got %q", lineNumber, line))
+			continue
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid EPSG code %q", lineNumber, strings.TrimSpace(fields[0])))
+			continue
+		}
+		crs, err := parseProjDefinition(code, strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2]))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", lineNumber, err))
+			continue
+		}
+		definitions[code] = crs
+	}
+
+	return definitions, errors.Join(errs...)
+}
+
+// parseProjDefinition builds a CRS from a PROJ style definition such as
+// "+proj=lcc +lat_1=36.41666666666666 +lat_2=35.25 +lat_0=34.33333333333334 +lon_0=-86 +x_0=600000 +y_0=0 +ellps=GRS80 +units=us-ft"
+func parseProjDefinition(code int, name, definition string) (*CRS, error) {
+	params := make(map[string]string)
+	for _, field := range strings.Fields(definition) {
+		field = strings.TrimPrefix(field, "+")
+		key, value, _ := strings.Cut(field, "=")
+		params[key] = value
+	}
+
+	number := func(key string, fallback float64) (float64, error) {
+		value, ok := params[key]
+		if !ok {
+			return fallback, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("EPSG:%d: invalid %s %q", code, key, value)
+		}
+		return f, nil
+	}
+
+	ellps := WGS84
+	if ellpsName, ok := params["ellps"]; ok {
+		e, ok := ellipsoid[ellpsName]
+		if !ok {
+			return nil, fmt.Errorf("EPSG:%d: unsupported ellipsoid %q", code, ellpsName)
+		}
+		ellps = e
+	}
+
+	crs := &CRS{Code: code, Name: name, Unit: "m", UnitToMeter: 1}
+	switch params["units"] {
+	case "", "m":
+	case "us-ft":
+		crs.Unit, crs.UnitToMeter = "ftUS", USSurveyFoot
+	case "ft":
+		crs.Unit, crs.UnitToMeter = "ft", InternationalFoot
+	default:
+		return nil, fmt.Errorf("EPSG:%d: unsupported units %q", code, params["units"])
+	}
+
+	var values [8]float64
+	for i, key := range []string{"lat_0", "lon_0", "lat_1", "lat_2", "k_0", "x_0", "y_0", "zone"} {
+		fallback := 0.0
+		if key == "k_0" {
+			fallback = 1
+		}
+		value, err := number(key, fallback)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	lat0, lon0, lat1, lat2, k0, x0, y0, zone := values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7]
+
+	switch params["proj"] {
+	case "longlat":
+		crs.Unit, crs.UnitToMeter = "deg", 0
+	case "webmerc":
+		crs.proj = webMercator{}
+	case "utm":
+		if zone < 1 || zone > 60 || zone != math.Trunc(zone) {
+			return nil, fmt.Errorf("EPSG:%d: invalid UTM zone %q", code, params["zone"])
+		}
+		_, south := params["south"]
+		crs.proj = newUTM(int(zone), south, ellps)
+	case "tmerc":
+		crs.proj = newTransverseMercator(lat0, lon0, k0, x0, y0, ellps)
+	case "lcc":
+		if _, ok := params["lat_1"]; !ok {
+			return nil, fmt.Errorf("EPSG:%d: lcc needs a standard parallel lat_1", code)
+		}
+		if _, ok := params["lat_2"]; !ok {
+			lat2 = lat1 // One standard parallel
+		}
+		crs.proj = newLambertConformalConic(lat0, lon0, lat1, lat2, x0, y0, ellps)
+	default:
+		return nil, fmt.Errorf("EPSG:%d: unsupported projection %q", code, params["proj"])
+	}
+
+	return crs, nil
+}
+
+// webMercator is EPSG:3857, spherical formulas on the WGS84 semi-major axis
+type webMercator struct{}
+
+func (webMercator) Forward(lat, lon float64) (float64, float64) {
+	lat = math.Max(-MaxLatitude, math.Min(MaxLatitude, lat))
+	x := WGS84.A * ToRadians(lon)
+	y := WGS84.A * math.Log(math.Tan(math.Pi/4+ToRadians(lat)/2))
+	return x, y
+}
+
+func (webMercator) Inverse(x, y float64) (float64, float64) {
+	lon := ToDegrees(x / WGS84.A)
+	lat := ToDegrees(math.Atan(math.Sinh(y / WGS84.A)))
+	return lat, lon
+}
+
+// transverseMercator uses Snyder's series (USGS Professional Paper 1395)
+type transverseMercator struct {
+	ellps          Ellipsoid
+	lat0, lon0, k0 float64 // Radians
+	x0, y0         float64
+	e2, ep2, m0    float64
+}
+
+func newTransverseMercator(lat0, lon0, k0, x0, y0 float64, ellps Ellipsoid) *transverseMercator {
+	tm := &transverseMercator{
+		ellps: ellps,
+		lat0:  ToRadians(lat0),
+		lon0:  ToRadians(lon0),
+		k0:    k0,
+		x0:    x0,
+		y0:    y0,
+		e2:    ellps.E2(),
+	}
+	tm.ep2 = tm.e2 / (1 - tm.e2)
+	tm.m0 = tm.meridianArc(tm.lat0)
+	return tm
+}
+
+func newUTM(zone int, south bool, ellps Ellipsoid) *transverseMercator {
+	y0 := 0.0
+	if south {
+		y0 = 10000000
+	}
+	return newTransverseMercator(0, float64(zone)*6-183, 0.9996, 500000, y0, ellps)
+}
+
+// meridianArc is the distance along the meridian from the equator to lat
+func (tm *transverseMercator) meridianArc(lat float64) float64 {
+	e2 := tm.e2
+	e4 := e2 * e2
+	e6 := e4 * e2
+	return tm.ellps.A * ((1-e2/4-3*e4/64-5*e6/256)*lat -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*lat) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*lat) -
+		(35*e6/3072)*math.Sin(6*lat))
+}
+
+func (tm *transverseMercator) Forward(lat, lon float64) (float64, float64) {
+	phi := ToRadians(lat)
+	lambda := ToRadians(NearestLongitude(lon, ToDegrees(tm.lon0)))
+
+	sinPhi, cosPhi := math.Sincos(phi)
+	n := tm.ellps.A / math.Sqrt(1-tm.e2*sinPhi*sinPhi)
+	t := math.Tan(phi) * math.Tan(phi)
+	c := tm.ep2 * cosPhi * cosPhi
+	a := (lambda - tm.lon0) * cosPhi
+	m := tm.meridianArc(phi)
+
+	x := tm.k0 * n * (a + (1-t+c)*math.Pow(a, 3)/6 +
+		(5-18*t+t*t+72*c-58*tm.ep2)*math.Pow(a, 5)/120)
+	y := tm.k0 * (m - tm.m0 + n*math.Tan(phi)*(a*a/2+
+		(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+
+		(61-58*t+t*t+600*c-330*tm.ep2)*math.Pow(a, 6)/720))
+
+	return x + tm.x0, y + tm.y0
+}
+
+func (tm *transverseMercator) Inverse(x, y float64) (float64, float64) {
+	x -= tm.x0
+	y -= tm.y0
+
+	e2 := tm.e2
+	e4 := e2 * e2
+	e6 := e4 * e2
+	m := tm.m0 + y/tm.k0
+	mu := m / (tm.ellps.A * (1 - e2/4 - 3*e4/64 - 5*e6/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sinPhi1, cosPhi1 := math.Sincos(phi1)
+	c1 := tm.ep2 * cosPhi1 * cosPhi1
+	t1 := math.Tan(phi1) * math.Tan(phi1)
+	n1 := tm.ellps.A / math.Sqrt(1-e2*sinPhi1*sinPhi1)
+	r1 := tm.ellps.A * (1 - e2) / math.Pow(1-e2*sinPhi1*sinPhi1, 1.5)
+	d := x / (n1 * tm.k0)
+
+	phi := phi1 - (n1*math.Tan(phi1)/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*tm.ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*tm.ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lambda := tm.lon0 + (d-(1+2*t1+c1)*math.Pow(d, 3)/6+
+		(5-2*c1+28*t1-3*c1*c1+8*tm.ep2+24*t1*t1)*math.Pow(d, 5)/120)/cosPhi1
+
+	return ToDegrees(phi), WrapLongitude(ToDegrees(lambda))
+}
+
+// lambertConformalConic has two standard parallels, using Snyder's formulas
+type lambertConformalConic struct {
+	ellps      Ellipsoid
+	e          float64
+	lon0       float64 // Radians
+	x0, y0     float64
+	n, f, rho0 float64
+}
+
+func newLambertConformalConic(lat0, lon0, lat1, lat2, x0, y0 float64, ellps Ellipsoid) *lambertConformalConic {
+	lcc := &lambertConformalConic{
+		ellps: ellps,
+		e:     math.Sqrt(ellps.E2()),
+		lon0:  ToRadians(lon0),
+		x0:    x0,
+		y0:    y0,
+	}
+	phi0, phi1, phi2 := ToRadians(lat0), ToRadians(lat1), ToRadians(lat2)
+
+	m1, m2 := lcc.m(phi1), lcc.m(phi2)
+	t0, t1, t2 := lcc.t(phi0), lcc.t(phi1), lcc.t(phi2)
+	if math.Abs(phi1-phi2) < 1e-12 {
+		lcc.n = math.Sin(phi1)
+	} else {
+		lcc.n = (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	}
+	lcc.f = m1 / (lcc.n * math.Pow(t1, lcc.n))
+	lcc.rho0 = ellps.A * lcc.f * math.Pow(t0, lcc.n)
+	return lcc
+}
+
+func (lcc *lambertConformalConic) m(phi float64) float64 {
+	sinPhi := math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-lcc.e*lcc.e*sinPhi*sinPhi)
+}
+
+func (lcc *lambertConformalConic) t(phi float64) float64 {
+	sinPhi := math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-lcc.e*sinPhi)/(1+lcc.e*sinPhi), lcc.e/2)
+}
+
+func (lcc *lambertConformalConic) Forward(lat, lon float64) (float64, float64) {
+	phi := ToRadians(lat)
+	lambda := ToRadians(NearestLongitude(lon, ToDegrees(lcc.lon0)))
+
+	rho := lcc.ellps.A * lcc.f * math.Pow(lcc.t(phi), lcc.n)
+	theta := lcc.n * (lambda - lcc.lon0)
+
+	x := rho * math.Sin(theta)
+	y := lcc.rho0 - rho*math.Cos(theta)
+	return x + lcc.x0, y + lcc.y0
+}
+
+func (lcc *lambertConformalConic) Inverse(x, y float64) (float64, float64) {
+	x -= lcc.x0
+	y = lcc.rho0 - (y - lcc.y0)
+	if lcc.n < 0 {
+		x, y = -x, -y
+	}
+
+	rho := math.Copysign(math.Hypot(x, y), lcc.n)
+	theta := math.Atan2(x, y)
+	t := math.Pow(rho/(lcc.ellps.A*lcc.f), 1/lcc.n)
+
+	// Latitude has no closed form, iterate until it settles
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 15; i++ {
+		sinPhi := lcc.e * math.Sin(phi)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-sinPhi)/(1+sinPhi), lcc.e/2))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	lambda := theta/lcc.n + lcc.lon0
+
+	return ToDegrees(phi), WrapLongitude(ToDegrees(lambda))
+}
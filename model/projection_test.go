@@ -0,0 +1,177 @@
+package model
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// Check points from EPSG Guidance Note 7-2 and Snyder's Map Projections, a
+// Working Manual (USGS Professional Paper 1395)
+func TestProjectionCheckPoints(t *testing.T) {
+	airy := Ellipsoid{A: 6377563.396, F: 1 / 299.3249646}
+	tests := []struct {
+		name     string
+		proj     Projection
+		unit     float64 // Meters per unit of x and y
+		lat, lon float64
+		x, y     float64
+	}{
+		{"LCC NAD27 Texas South Central", newLambertConformalConic(27+50.0/60, -99, 28+23.0/60, 30+17.0/60, 2000000*USSurveyFoot, 0, Clarke66), USSurveyFoot, 28.5, -96, 2963503.91, 254759.80},
+		{"TM British National Grid", newTransverseMercator(49, -2, 0.9996012717, 400000, -100000, airy), 1, 50.5, 0.5, 577274.99, 69740.50},
+		{"UTM zone 18N Clarke 1866", newUTM(18, false, Clarke66), 1, 40.5, -73.5, 627106.5, 4484124.4},
+	}
+
+	for _, test := range tests {
+		x, y := test.proj.Forward(test.lat, test.lon)
+		x, y = x/test.unit, y/test.unit
+		if math.Abs(x-test.x) > 0.05 || math.Abs(y-test.y) > 0.05 {
+			t.Errorf("%s: Forward(%g, %g) = %.3f, %.3f, want %.2f, %.2f", test.name, test.lat, test.lon, x, y, test.x, test.y)
+		}
+
+		lat, lon := test.proj.Inverse(test.x*test.unit, test.y*test.unit)
+		if math.Abs(lat-test.lat) > 1e-6 || math.Abs(lon-test.lon) > 1e-6 {
+			t.Errorf("%s: Inverse(%.2f, %.2f) = %.9f, %.9f, want %g, %g", test.name, test.x, test.y, lat, lon, test.lat, test.lon)
+		}
+	}
+}
+
+func TestCRSRoundTrip(t *testing.T) {
+	tests := []struct {
+		code     int
+		lat, lon float64
+	}{
+		{2274, 35.156072, -90.051911}, // Memphis, Tennessee State Plane feet
+		{2274, 36.1627, -86.7816},     // Nashville
+		{32136, 35.9606, -83.9207},    // Knoxville, Tennessee State Plane meters
+		{2254, 32.2988, -88.6703},     // Mississippi East, transverse Mercator
+		{26916, 35.156072, -90.051911},
+		{32616, 35.156072, -90.051911},
+		{32718, -12.0464, -77.0428}, // UTM south, false northing 10,000 km
+		{3857, 35.156072, -90.051911},
+	}
+
+	for _, test := range tests {
+		crs, err := LookupCRS(test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := crs.FromLatLng(test.lat, test.lon)
+		lat, lon := crs.ToLatLng(x, y)
+		if math.Abs(lat-test.lat) > 1e-8 || math.Abs(lon-test.lon) > 1e-8 {
+			t.Errorf("EPSG:%d: %g, %g came back as %.10f, %.10f", test.code, test.lat, test.lon, lat, lon)
+		}
+	}
+}
+
+func TestCRSOrigins(t *testing.T) {
+	tests := []struct {
+		code     int
+		lat, lon float64
+		x, y     float64 // In the CRS units
+	}{
+		// The latitude of origin on the central meridian is the false origin
+		{2274, 34.33333333333334, -86, 600000 / USSurveyFoot, 0},
+		{32136, 34.33333333333334, -86, 600000, 0},
+		// UTM zone 16 is centred on 87°W, the equator has northing 0
+		{26916, 0, -87, 500000, 0},
+		{32716, 0, -87, 500000, 10000000},
+		{4326, 35.1, -90.05, -90.05, 35.1},
+	}
+
+	for _, test := range tests {
+		crs, err := LookupCRS(test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := crs.FromLatLng(test.lat, test.lon)
+		if math.Abs(x-test.x) > 1e-4 || math.Abs(y-test.y) > 1e-4 {
+			t.Errorf("EPSG:%d: FromLatLng(%g, %g) = %.4f, %.4f, want %.4f, %.4f", test.code, test.lat, test.lon, x, y, test.x, test.y)
+		}
+	}
+}
+
+func TestParseCRS(t *testing.T) {
+	for _, s := range []string{"2274", "EPSG:2274", "epsg:2274", " 2274 "} {
+		crs, err := ParseCRS(s)
+		if err != nil || crs.Code != 2274 || crs.Unit != "ftUS" {
+			t.Errorf("ParseCRS(%q) = %v, %v", s, crs, err)
+		}
+	}
+	for _, s := range []string{"", "EPSG:", "abc", "9999"} {
+		if _, err := ParseCRS(s); err == nil {
+			t.Errorf("ParseCRS(%q) succeeded, want an error", s)
+		}
+	}
+	for _, s := range []string{"", "4326", "EPSG:4326"} {
+		if crs, err := ParseCRSArgument(s); crs != nil || err != nil {
+			t.Errorf("ParseCRSArgument(%q) = %v, %v, want lat/lon", s, crs, err)
+		}
+	}
+}
+
+func TestParseEPSGDefinitions(t *testing.T) {
+	definitions, err := parseEPSGDefinitions(`# A comment, then a blank line
+
+2274 | NAD83 / Tennessee (ftUS) | +proj=lcc +lat_1=36.41666666666666 +lat_2=35.25 +lat_0=34.33333333333334 +lon_0=-86 +x_0=600000 +y_0=0 +ellps=GRS80 +units=us-ft
+2254 | NAD83 / Mississippi East (ftUS) | +proj=tmerc +lat_0=29.5 +lon_0=-88.83333333333333 +k_0=0.99995 +x_0=300000 +y_0=0 +ellps=GRS80 +units=us-ft
+4269 | NAD83 | +proj=longlat +ellps=GRS80
+32616 | WGS 84 / UTM zone 16N | +proj=utm +zone=16 +ellps=WGS84
+`)
+	if err != nil {
+		t.Fatalf("parseEPSGDefinitions: %v", err)
+	}
+	if len(definitions) != 4 {
+		t.Fatalf("got %d definitions, want 4", len(definitions))
+	}
+	if crs := definitions[2274]; crs.Name != "NAD83 / Tennessee (ftUS)" || crs.UnitToMeter != USSurveyFoot {
+		t.Errorf("EPSG:2274 parsed as %v in %s", crs, crs.Unit)
+	}
+	if crs := definitions[4269]; !crs.IsGeographic() {
+		t.Error("EPSG:4269 should be geographic")
+	}
+	if tm, ok := definitions[2254].proj.(*transverseMercator); !ok || tm.k0 != 0.99995 || tm.x0 != 300000 {
+		t.Errorf("EPSG:2254 parsed as %#v", definitions[2254].proj)
+	}
+}
+
+func TestParseEPSGDefinitionsMalformed(t *testing.T) {
+	lines := []struct {
+		line string
+		want string // Part of the error
+	}{
+		{"2274 NAD83 / Tennessee +proj=lcc", "want code | name | definition"},
+		{"2274 | NAD83 / Tennessee", "want code | name | definition"},
+		{"EPSG:2274 | NAD83 / Tennessee | +proj=lcc +lat_1=36", "invalid EPSG code"},
+		{"1 | Nowhere | +proj=stere", "unsupported projection"},
+		{"2 | Nowhere | +proj=tmerc +lat_0=north", "invalid lat_0"},
+		{"3 | Nowhere | +proj=tmerc +ellps=bessel", "unsupported ellipsoid"},
+		{"4 | Nowhere | +proj=tmerc +units=yd", "unsupported units"},
+		{"5 | Nowhere | +proj=utm", "invalid UTM zone"},
+		{"6 | Nowhere | +proj=lcc +lat_0=34", "standard parallel"},
+	}
+
+	for _, test := range lines {
+		// A malformed line is skipped without losing the good ones around it
+		data := "4326 | WGS 84 | +proj=longlat\n" + test.line + "\n4269 | NAD83 | +proj=longlat +ellps=GRS80\n"
+		definitions, err := parseEPSGDefinitions(data)
+		if err == nil || !strings.Contains(err.Error(), test.want) || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: error %v, want line 2 and %q", test.line, err, test.want)
+		}
+		if len(definitions) != 2 {
+			t.Errorf("%q: kept %d definitions, want the 2 good ones", test.line, len(definitions))
+		}
+	}
+}
+
+func TestEmbeddedEPSGDefinitions(t *testing.T) {
+	definitions, err := parseEPSGDefinitions(epsgDefinitions)
+	if err != nil {
+		t.Fatalf("epsg.txt: %v", err)
+	}
+	for code := range definitions {
+		if _, err := LookupCRS(code); err != nil {
+			t.Errorf("EPSG:%d from epsg.txt is not in the registry", code)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DistanceUnit is the unit distances and areas are displayed in. Distances are
+// always stored in meters.
+type DistanceUnit int
+
+const (
+	UnitFeet DistanceUnit = iota
+	UnitMeters
+	UnitMiles
+	UnitKilometers
+)
+
+const (
+	metersPerFoot = InternationalFoot
+	metersPerMile = 1609.344
+	sqMetersPerAc = 4046.8564224
+)
+
+func ParseDistanceUnit(s string) (DistanceUnit, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "FT", "FEET", "FOOT", "'":
+		return UnitFeet, nil
+	case "M", "METERS", "METER", "METRES", "METRE":
+		return UnitMeters, nil
+	case "MI", "MILES", "MILE":
+		return UnitMiles, nil
+	case "KM", "KILOMETERS", "KILOMETER", "KILOMETRES", "KILOMETRE":
+		return UnitKilometers, nil
+	}
+	return UnitFeet, fmt.Errorf("unknown unit %q, use FT, M, MI or KM", s)
+}
+
+func (u DistanceUnit) String() string {
+	switch u {
+	case UnitMeters:
+		return "m"
+	case UnitMiles:
+		return "mi"
+	case UnitKilometers:
+		return "km"
+	default:
+		return "ft"
+	}
+}
+
+func (u DistanceUnit) metersPerUnit() float64 {
+	switch u {
+	case UnitMeters:
+		return 1
+	case UnitMiles:
+		return metersPerMile
+	case UnitKilometers:
+		return 1000
+	default:
+		return metersPerFoot
+	}
+}
+
+// FromMeters converts a distance in meters to the unit
+func (u DistanceUnit) FromMeters(meters float64) float64 {
+	return meters / u.metersPerUnit()
+}
+
+// ToMeters converts a distance in the unit to meters
+func (u DistanceUnit) ToMeters(value float64) float64 {
+	return value * u.metersPerUnit()
+}
+
+// FormatDistance formats a distance in meters for display, e.g. 144' or 1.25 mi
+func (u DistanceUnit) FormatDistance(meters float64) string {
+	value := u.FromMeters(meters)
+	switch u {
+	case UnitMeters:
+		return fmt.Sprintf("%.1f m", value)
+	case UnitMiles:
+		return fmt.Sprintf("%.2f mi", value)
+	case UnitKilometers:
+		return fmt.Sprintf("%.3f km", value)
+	default:
+		return fmt.Sprintf("%.0f'", value)
+	}
+}
+
+// FormatArea formats an area in square meters for display
+func (u DistanceUnit) FormatArea(sqMeters float64) string {
+	perUnit := u.metersPerUnit()
+	value := sqMeters / (perUnit * perUnit)
+	switch u {
+	case UnitMeters:
+		return fmt.Sprintf("%.1f sq m (%.4f ha)", value, sqMeters/10000)
+	case UnitMiles:
+		return fmt.Sprintf("%.4f sq mi", value)
+	case UnitKilometers:
+		return fmt.Sprintf("%.4f sq km", value)
+	default:
+		return fmt.Sprintf("%.0f sq ft (%.3f ac)", value, sqMeters/sqMetersPerAc)
+	}
+}
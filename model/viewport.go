@@ -0,0 +1,139 @@
+package model
+
+import (
+	"math"
+)
+
+const (
+	MaxLatitude = 85.05112878 // Web Mercator cuts off the poles here
+	TileSize    = 256         // Pixels across a map tile
+)
+
+// Viewport is the part of the Web Mercator world shown in the window. All
+// conversions between lat/lon and screen pixels go through it so that drawing
+// and picking always agree to a fraction of a pixel.
+type Viewport struct {
+	CenterLat, CenterLon float64
+	Zoom                 float64
+	Width, Height        int
+}
+
+// ProjectMercator converts lat/lon to normalized Web Mercator coordinates, with
+// 0,0 at the top left of the world and 1,1 at the bottom right
+func ProjectMercator(lat, lon float64) (float64, float64) {
+	lat = math.Max(-MaxLatitude, math.Min(MaxLatitude, lat))
+	latRad := lat * math.Pi / 180.0
+	x := (lon + 180.0) / 360.0
+	y := (1.0 - math.Log(math.Tan(latRad)+1.0/math.Cos(latRad))/math.Pi) / 2.0
+	return x, y
+}
+
+// unprojectMercator is the exact inverse of ProjectMercator
+func unprojectMercator(x, y float64) (float64, float64) {
+	lon := x*360.0 - 180.0
+	lat := math.Atan(math.Sinh(math.Pi*(1.0-2.0*y))) * 180.0 / math.Pi
+	return lat, lon
+}
+
+// WorldSize returns the width and height of the whole world in pixels
+func (v Viewport) WorldSize() float64 {
+	return TileSize * math.Pow(2, v.Zoom)
+}
+
+// WrapLongitude normalizes a longitude into -180..180
+func WrapLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180.0, 360.0)
+	if lon < 0 {
+		lon += 360.0
+	}
+	return lon - 180.0
+}
+
+// NearestLongitude shifts lon by whole turns around the world to be within 180
+// degrees of refLon
+func NearestLongitude(lon, refLon float64) float64 {
+	return refLon + WrapLongitude(lon-refLon)
+}
+
+// LatLngToScreen returns the sub-pixel screen position of a location on the
+// world copy nearest the center of the view
+func (v Viewport) LatLngToScreen(lat, lon float64) (float32, float32) {
+	x, y := v.LatLngToScreen64(lat, lon)
+	return float32(x), float32(y)
+}
+
+// LatLngToScreen64 is LatLngToScreen at full precision
+func (v Viewport) LatLngToScreen64(lat, lon float64) (float64, float64) {
+	return v.Project(lat, NearestLongitude(lon, v.CenterLon))
+}
+
+// SegmentToScreen projects both ends of a segment onto the same world copy, so
+// a segment crossing the antimeridian takes the short way across it instead of
+// spanning the whole map
+func (v Viewport) SegmentToScreen(lat0, lon0, lat1, lon1 float64) (float32, float32, float32, float32) {
+	lon0 = NearestLongitude(lon0, v.CenterLon)
+	lon1 = NearestLongitude(lon1, lon0)
+	x0, y0 := v.Project(lat0, lon0)
+	x1, y1 := v.Project(lat1, lon1)
+	return float32(x0), float32(y0), float32(x1), float32(y1)
+}
+
+// Project converts a location to screen pixels without choosing a world copy,
+// longitudes past +-180 land on the copies either side of the main one
+func (v Viewport) Project(lat, lon float64) (float64, float64) {
+	worldSize := v.WorldSize()
+	centerX, centerY := ProjectMercator(v.CenterLat, v.CenterLon)
+	x, y := ProjectMercator(lat, lon)
+
+	// Offsets from the center are computed before scaling to keep precision at deep zooms
+	screenX := (x-centerX)*worldSize + float64(v.Width)/2
+	screenY := (y-centerY)*worldSize + float64(v.Height)/2
+	return screenX, screenY
+}
+
+// ScreenToLatLng returns the location under a screen position
+func (v Viewport) ScreenToLatLng(screenX, screenY float64) (float64, float64) {
+	worldSize := v.WorldSize()
+	centerX, centerY := ProjectMercator(v.CenterLat, v.CenterLon)
+
+	x := centerX + (screenX-float64(v.Width)/2)/worldSize
+	y := centerY + (screenY-float64(v.Height)/2)/worldSize
+	lat, lon := unprojectMercator(x, y)
+	return lat, WrapLongitude(lon)
+}
+
+// CenterForAnchor returns the center that puts a location at the given screen
+// position, used to keep the map under the cursor fixed while zooming or dragging
+func (v Viewport) CenterForAnchor(lat, lon, screenX, screenY float64) (float64, float64) {
+	worldSize := v.WorldSize()
+	x, y := ProjectMercator(lat, lon)
+
+	centerX := x - (screenX-float64(v.Width)/2)/worldSize
+	centerY := y - (screenY-float64(v.Height)/2)/worldSize
+	centerLat, centerLon := unprojectMercator(centerX, centerY)
+	return centerLat, WrapLongitude(centerLon)
+}
+
+// PointLineSegmentDistance returns the distance from a point to a line segment
+func PointLineSegmentDistance(x, y, x1, y1, x2, y2 float64) float64 {
+	projX, projY := ClosestPointOnSegment(x, y, x1, y1, x2, y2)
+
+	// Calculate the distance from the point to the projection point
+	return math.Sqrt(math.Pow(x-projX, 2) + math.Pow(y-projY, 2))
+}
+
+// ClosestPointOnSegment projects a point onto a line segment
+func ClosestPointOnSegment(x, y, x1, y1, x2, y2 float64) (float64, float64) {
+	// Calculate the squared length of the line segment
+	l2 := math.Pow(x2-x1, 2) + math.Pow(y2-y1, 2)
+	if l2 == 0 {
+		return x1, y1
+	}
+
+	// Calculate the projection of the point onto the line segment
+	t := ((x-x1)*(x2-x1) + (y-y1)*(y2-y1)) / l2
+	t = math.Max(0, math.Min(1, t))
+
+	// Calculate the projection point
+	return x1 + t*(x2-x1), y1 + t*(y2-y1)
+}
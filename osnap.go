@@ -0,0 +1,43 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/OpticalFlyer/FiberForge/editor"
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var snapColor = color.RGBA{255, 128, 0, 255}
+
+// drawSnapMarker draws the marker for the current snap, a square for
+// endpoints, a diamond for vertices, a triangle for midpoints, an X for
+// intersections and an hourglass for nearest
+func (g *Game) drawSnapMarker(screen *ebiten.Image, view model.Viewport) {
+	if !g.Snapped {
+		return
+	}
+
+	x, y := view.LatLngToScreen(g.Snap.Lat, g.Snap.Lon)
+	size := float32(6)
+	var corners [][2]float32
+	switch g.Snap.Mode {
+	case editor.SnapEndpoint:
+		corners = [][2]float32{{-size, -size}, {size, -size}, {size, size}, {-size, size}}
+	case editor.SnapVertex:
+		corners = [][2]float32{{0, -size}, {size, 0}, {0, size}, {-size, 0}}
+	case editor.SnapMidpoint:
+		corners = [][2]float32{{0, -size}, {size, size}, {-size, size}}
+	case editor.SnapIntersection:
+		vector.StrokeLine(screen, x-size, y-size, x+size, y+size, 2, snapColor, false)
+		vector.StrokeLine(screen, x-size, y+size, x+size, y-size, 2, snapColor, false)
+	case editor.SnapNearest:
+		corners = [][2]float32{{-size, -size}, {size, -size}, {-size, size}, {size, size}}
+	}
+
+	for i := range corners {
+		next := corners[(i+1)%len(corners)]
+		vector.StrokeLine(screen, x+corners[i][0], y+corners[i][1], x+next[0], y+next[1], 2, snapColor, false)
+	}
+}
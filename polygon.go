@@ -6,6 +6,7 @@ import (
 	"log"
 	"math"
 
+	"github.com/OpticalFlyer/FiberForge/model"
 	"github.com/flywave/go-earcut"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
@@ -19,6 +20,19 @@ var (
 	whiteImage = ebiten.NewImage(3, 3)
 )
 
+// polygonScreenPoints projects a polygon ring keeping each vertex on the same
+// world copy as the one before it, so rings crossing the antimeridian stay whole
+func polygonScreenPoints(view model.Viewport, points []model.PolyPoint) []struct{ x, y float64 } {
+	screenPoints := make([]struct{ x, y float64 }, len(points))
+	lon := view.CenterLon
+	for i, pt := range points {
+		lon = model.NearestLongitude(pt.Lon, lon)
+		x, y := view.Project(pt.Lat, lon)
+		screenPoints[i] = struct{ x, y float64 }{x, y}
+	}
+	return screenPoints
+}
+
 func isPolygonTooSmall(points []struct{ x, y float64 }) bool {
 	if len(points) < 2 {
 		return true
@@ -44,7 +58,8 @@ func isPolygonTooSmall(points []struct{ x, y float64 }) bool {
 	return width < MinPolygonSize || height < MinPolygonSize
 }
 
-func drawFilledPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, fillColor color.Color) {
+// drawFilledPolygon fills and outlines a polygon as its style says
+func drawFilledPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, style model.FeatureStyle) {
 	if len(points) < 3 {
 		log.Printf("Not enough points to form a polygon: %+v", points)
 		return // A polygon must have at least 3 points
@@ -62,6 +77,19 @@ func drawFilledPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, fi
 	// Remove duplicate points
 	points = removeDuplicatePoints(points)
 
+	if style.Fill {
+		fillPolygon(screen, points, style.FillColor)
+	}
+
+	if style.Outline {
+		for i := 0; i < len(points); i++ {
+			next := (i + 1) % len(points)
+			vector.StrokeLine(screen, float32(points[i].x), float32(points[i].y), float32(points[next].x), float32(points[next].y), style.LineWidth, style.LineColor, false)
+		}
+	}
+}
+
+func fillPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, fillColor color.RGBA) {
 	// Convert points to vertices
 	vertices := make([]ebiten.Vertex, len(points))
 	for i, p := range points {
@@ -70,10 +98,10 @@ func drawFilledPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, fi
 			DstY:   float32(p.y),
 			SrcX:   1,
 			SrcY:   1,
-			ColorR: float32(fillColor.(color.RGBA).R) / 255,
-			ColorG: float32(fillColor.(color.RGBA).G) / 255,
-			ColorB: float32(fillColor.(color.RGBA).B) / 255,
-			ColorA: float32(fillColor.(color.RGBA).A) / 255,
+			ColorR: float32(fillColor.R) / 255,
+			ColorG: float32(fillColor.G) / 255,
+			ColorB: float32(fillColor.B) / 255,
+			ColorA: float32(fillColor.A) / 255,
 		}
 	}
 
@@ -86,12 +114,6 @@ func drawFilledPolygon(screen *ebiten.Image, points []struct{ x, y float64 }, fi
 
 	// Draw the filled polygon
 	screen.DrawTriangles(vertices, indices, whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image), &ebiten.DrawTrianglesOptions{})
-
-	// Optionally, draw the outline of the polygon
-	for i := 0; i < len(points); i++ {
-		next := (i + 1) % len(points)
-		vector.StrokeLine(screen, float32(points[i].x), float32(points[i].y), float32(points[next].x), float32(points[next].y), 2, color.RGBA{0x00, 0x00, 0x00, 0xff}, false)
-	}
 }
 
 // removeDuplicatePoints removes duplicate points from the polygon
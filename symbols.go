@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image/png"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed symbols/*.png
+var symbolFiles embed.FS
+
+// symbolImages holds the decoded symbols, loaded the first time each is used
+var symbolImages = map[string]*ebiten.Image{}
+
+// symbolImage returns the image of a symbol type, e.g. HANDHOLE
+func symbolImage(name string) (*ebiten.Image, error) {
+	if img, ok := symbolImages[name]; ok {
+		return img, nil
+	}
+
+	data, err := symbolFiles.ReadFile("symbols/" + strings.ToLower(name) + ".png")
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("symbol %s: %v", name, err)
+	}
+	img := ebiten.NewImageFromImage(decoded)
+	symbolImages[name] = img
+	return img, nil
+}
+
+// pointSymbolImage returns the image of the symbol PO is placing, nil for
+// plain points or when icons are not loaded
+func (g *Game) pointSymbolImage() (*ebiten.Image, error) {
+	if g.PointSymbol == nil || !g.Doc.LoadIcons {
+		return nil, nil
+	}
+	return symbolImage(g.PointSymbol.Name)
+}
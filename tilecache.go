@@ -0,0 +1,206 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	DefaultTileCacheMaxTiles = 2048
+	DefaultTileCacheMaxBytes = 256 * 1024 * 1024 // GPU memory budget for decoded tiles
+)
+
+// TileKey identifies a single tile image across all basemaps
+type TileKey struct {
+	Basemap string
+	Zoom    int
+	X, Y    int
+}
+
+type tileCacheEntry struct {
+	key  TileKey
+	img  *ebiten.Image
+	size int64
+}
+
+type TileCacheStats struct {
+	Tiles     int
+	Bytes     int64
+	MaxBytes  int64
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// TileImageCache is a size-bounded LRU of decoded tile images. Every basemap
+// keeps its own LRU and, when the cache is full, the basemap holding the most
+// tiles gives up its least recently used one. Browsing one basemap can use the
+// whole budget, but switching basemaps only evicts the previous basemap down to
+// an even share, so toggling back is still instant.
+type TileImageCache struct {
+	basemaps  map[string]*tileLRU
+	tiles     int
+	maxTiles  int
+	maxBytes  int64
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+	evicted   []*ebiten.Image // Waiting to be disposed on the main thread
+	mu        sync.Mutex
+}
+
+// tileLRU holds the cached tiles of a single basemap
+type tileLRU struct {
+	entries map[TileKey]*list.Element
+	lru     *list.List // Front is most recently used
+	bytes   int64
+}
+
+func NewTileImageCache(maxTiles int, maxBytes int64) *TileImageCache {
+	return &TileImageCache{
+		basemaps: make(map[string]*tileLRU),
+		maxTiles: maxTiles,
+		maxBytes: maxBytes,
+	}
+}
+
+func (cache *TileImageCache) Set(key TileKey, img *ebiten.Image) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	basemap, ok := cache.basemaps[key.Basemap]
+	if !ok {
+		basemap = &tileLRU{entries: make(map[TileKey]*list.Element), lru: list.New()}
+		cache.basemaps[key.Basemap] = basemap
+	}
+
+	size := imageBytes(img)
+	if elem, ok := basemap.entries[key]; ok {
+		// Replace an existing tile
+		entry := elem.Value.(*tileCacheEntry)
+		if entry.img != img {
+			cache.evicted = append(cache.evicted, entry.img)
+		}
+		basemap.bytes += size - entry.size
+		cache.bytes += size - entry.size
+		entry.img = img
+		entry.size = size
+		basemap.lru.MoveToFront(elem)
+	} else {
+		entry := &tileCacheEntry{key: key, img: img, size: size}
+		basemap.entries[key] = basemap.lru.PushFront(entry)
+		basemap.bytes += size
+		cache.bytes += size
+		cache.tiles++
+	}
+
+	cache.evict()
+}
+
+func (cache *TileImageCache) Get(key TileKey) (*ebiten.Image, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	basemap, ok := cache.basemaps[key.Basemap]
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	elem, ok := basemap.entries[key]
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+	basemap.lru.MoveToFront(elem)
+	return elem.Value.(*tileCacheEntry).img, true
+}
+
+// Peek returns a cached tile without counting a hit or miss or making it more
+// recently used, for probing fallback tiles while the wanted one loads
+func (cache *TileImageCache) Peek(key TileKey) (*ebiten.Image, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	basemap, ok := cache.basemaps[key.Basemap]
+	if !ok {
+		return nil, false
+	}
+	elem, ok := basemap.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*tileCacheEntry).img, true
+}
+
+// evict drops least recently used tiles of the largest basemap until the cache
+// is within its limits. The caller must hold cache.mu.
+func (cache *TileImageCache) evict() {
+	for cache.tiles > 0 && (cache.tiles > cache.maxTiles || cache.bytes > cache.maxBytes) {
+		basemap := cache.largestBasemap()
+		elem := basemap.lru.Back()
+		entry := elem.Value.(*tileCacheEntry)
+		basemap.lru.Remove(elem)
+		delete(basemap.entries, entry.key)
+		basemap.bytes -= entry.size
+		cache.bytes -= entry.size
+		cache.tiles--
+		cache.evictions++
+
+		// The image may still be referenced by the frame being drawn, so it is
+		// only disposed later from the main thread
+		cache.evicted = append(cache.evicted, entry.img)
+	}
+}
+
+// largestBasemap returns the basemap using the most memory, breaking ties by
+// tile count and then name so eviction doesn't depend on map order. The caller
+// must hold cache.mu and the cache must not be empty.
+func (cache *TileImageCache) largestBasemap() *tileLRU {
+	var largest *tileLRU
+	var largestName string
+	for name, basemap := range cache.basemaps {
+		if largest == nil || basemap.bytes > largest.bytes ||
+			(basemap.bytes == largest.bytes && (basemap.lru.Len() > largest.lru.Len() ||
+				(basemap.lru.Len() == largest.lru.Len() && name < largestName))) {
+			largest = basemap
+			largestName = name
+		}
+	}
+	return largest
+}
+
+// DisposeEvicted frees the GPU memory of evicted tiles. It must be called from
+// the main thread between frames.
+func (cache *TileImageCache) DisposeEvicted() {
+	cache.mu.Lock()
+	evicted := cache.evicted
+	cache.evicted = nil
+	cache.mu.Unlock()
+
+	for _, img := range evicted {
+		img.Dispose()
+	}
+}
+
+func (cache *TileImageCache) Stats() TileCacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return TileCacheStats{
+		Tiles:     cache.tiles,
+		Bytes:     cache.bytes,
+		MaxBytes:  cache.maxBytes,
+		Hits:      cache.hits,
+		Misses:    cache.misses,
+		Evictions: cache.evictions,
+	}
+}
+
+func imageBytes(img *ebiten.Image) int64 {
+	bounds := img.Bounds()
+	return int64(bounds.Dx()) * int64(bounds.Dy()) * 4
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func osmTile(x int) TileKey {
+	return TileKey{Basemap: "OSM", Zoom: 10, X: x, Y: 0}
+}
+
+func TestTileImageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewTileImageCache(3, DefaultTileCacheMaxBytes)
+	for x := 0; x < 3; x++ {
+		cache.Set(osmTile(x), ebiten.NewImage(1, 1))
+	}
+	// Touch the oldest tile so the second one becomes the least recently used
+	if _, ok := cache.Get(osmTile(0)); !ok {
+		t.Fatal("tile 0 missing before eviction")
+	}
+	cache.Set(osmTile(3), ebiten.NewImage(1, 1))
+
+	for x, want := range []bool{true, false, true, true} {
+		if _, ok := cache.Get(osmTile(x)); ok != want {
+			t.Errorf("tile %d cached = %v, want %v", x, ok, want)
+		}
+	}
+	stats := cache.Stats()
+	if stats.Tiles != 3 || stats.Evictions != 1 {
+		t.Errorf("stats = %+v, want 3 tiles and 1 eviction", stats)
+	}
+	if stats.Hits != 4 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 4 hits and 1 miss", stats)
+	}
+}
+
+func TestTileImageCacheEvictsOverMaxBytes(t *testing.T) {
+	// Each 2x2 tile takes 16 bytes, so only two fit
+	cache := NewTileImageCache(DefaultTileCacheMaxTiles, 40)
+	for x := 0; x < 3; x++ {
+		cache.Set(osmTile(x), ebiten.NewImage(2, 2))
+	}
+
+	if _, ok := cache.Get(osmTile(0)); ok {
+		t.Error("oldest tile still cached over the byte limit")
+	}
+	stats := cache.Stats()
+	if stats.Tiles != 2 || stats.Bytes != 32 {
+		t.Errorf("stats = %+v, want 2 tiles and 32 bytes", stats)
+	}
+}
+
+func TestTileImageCacheKeepsShareOfPreviousBasemap(t *testing.T) {
+	cache := NewTileImageCache(4, DefaultTileCacheMaxBytes)
+	for x := 0; x < 4; x++ {
+		cache.Set(osmTile(x), ebiten.NewImage(1, 1))
+	}
+	// Browsing another basemap evicts OSM down to half the cache, not to nothing
+	for x := 0; x < 6; x++ {
+		cache.Set(TileKey{Basemap: "BINGAERIAL", Zoom: 10, X: x, Y: 0}, ebiten.NewImage(1, 1))
+	}
+
+	for x, want := range []bool{false, false, true, true} {
+		if _, ok := cache.Get(osmTile(x)); ok != want {
+			t.Errorf("OSM tile %d cached = %v, want %v", x, ok, want)
+		}
+	}
+	for x, want := range []bool{false, false, false, false, true, true} {
+		if _, ok := cache.Get(TileKey{Basemap: "BINGAERIAL", Zoom: 10, X: x, Y: 0}); ok != want {
+			t.Errorf("BINGAERIAL tile %d cached = %v, want %v", x, ok, want)
+		}
+	}
+	if stats := cache.Stats(); stats.Tiles != 4 {
+		t.Errorf("cache holds %d tiles, want 4", stats.Tiles)
+	}
+}
+
+func TestTileImageCacheDisposeEvicted(t *testing.T) {
+	cache := NewTileImageCache(1, DefaultTileCacheMaxBytes)
+	first := ebiten.NewImage(1, 1)
+	second := ebiten.NewImage(1, 1)
+	third := ebiten.NewImage(1, 1)
+
+	cache.Set(osmTile(0), first)
+	cache.Set(osmTile(0), first) // Setting the same image again keeps it
+	cache.Set(osmTile(0), second)
+	cache.Set(osmTile(1), third)
+
+	cache.mu.Lock()
+	evicted := append([]*ebiten.Image(nil), cache.evicted...)
+	cache.mu.Unlock()
+	if len(evicted) != 2 || evicted[0] != first || evicted[1] != second {
+		t.Fatalf("evicted %v, want the replaced and then the evicted image", evicted)
+	}
+
+	cache.DisposeEvicted()
+	cache.mu.Lock()
+	remaining := len(cache.evicted)
+	cache.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("%d images left to dispose after DisposeEvicted", remaining)
+	}
+	if img, ok := cache.Get(osmTile(1)); !ok || img != third {
+		t.Error("cached tile lost by DisposeEvicted")
+	}
+}
+
+func TestTileImageCachePeekLeavesOrderAndStats(t *testing.T) {
+	cache := NewTileImageCache(2, DefaultTileCacheMaxBytes)
+	cache.Set(osmTile(0), ebiten.NewImage(1, 1))
+	cache.Set(osmTile(1), ebiten.NewImage(1, 1))
+
+	if _, ok := cache.Peek(osmTile(0)); !ok {
+		t.Fatal("Peek missed a cached tile")
+	}
+	if _, ok := cache.Peek(osmTile(5)); ok {
+		t.Fatal("Peek found a tile never cached")
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Peek counted hits or misses: %+v", stats)
+	}
+
+	// Peeking didn't make tile 0 recently used, so it is still evicted first
+	cache.Set(osmTile(2), ebiten.NewImage(1, 1))
+	if _, ok := cache.Peek(osmTile(0)); ok {
+		t.Error("peeked tile was promoted in the LRU")
+	}
+	if _, ok := cache.Peek(osmTile(1)); !ok {
+		t.Error("wrong tile evicted after Peek")
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	tileRetryMinBackoff = 1 * time.Second
+	tileRetryMaxBackoff = 5 * time.Minute
+)
+
+type tileRequest struct {
+	key      TileKey
+	priority float64 // Lower is more urgent, e.g. distance from the view center in tiles
+	frame    int     // Last frame the tile was wanted in
+}
+
+type tileFailure struct {
+	attempts  int
+	nextRetry time.Time
+	frame     int // Last frame the tile was wanted in
+}
+
+type TileSchedulerStats struct {
+	Pending  int
+	InFlight int
+	Failed   int
+}
+
+// TileScheduler downloads missing tiles in the background. Requests never block
+// the caller, tiles already queued or downloading are not requested twice, the
+// most urgent tile is always fetched first, tiles that leave the view are
+// cancelled and failed tiles are retried with exponential backoff.
+type TileScheduler struct {
+	cache    *TileImageCache
+	download func(ctx context.Context, x, y, zoom int, basemap string) (*ebiten.Image, error)
+	pending  map[TileKey]*tileRequest
+	inFlight map[TileKey]*inFlightTile
+	failed   map[TileKey]*tileFailure
+	frame    int
+	closed   bool
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+type inFlightTile struct {
+	frame  int
+	cancel context.CancelFunc
+}
+
+func NewTileScheduler(cache *TileImageCache) *TileScheduler {
+	s := &TileScheduler{
+		cache:    cache,
+		download: downloadTileImage,
+		pending:  make(map[TileKey]*tileRequest),
+		inFlight: make(map[TileKey]*inFlightTile),
+		failed:   make(map[TileKey]*tileFailure),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *TileScheduler) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go s.worker()
+	}
+}
+
+func (s *TileScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, tile := range s.inFlight {
+		tile.cancel()
+	}
+	s.cond.Broadcast()
+}
+
+// BeginFrame starts a new set of wanted tiles. Every tile still visible must be
+// requested again before EndFrame or it is dropped.
+func (s *TileScheduler) BeginFrame() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.frame++
+}
+
+// EndFrame drops queued tiles and cancels downloads for tiles that were not
+// requested since BeginFrame, e.g. after panning or zooming away from them.
+// Failures of tiles no longer wanted are forgotten too, so a long session
+// over unreachable tiles doesn't keep every one of them.
+func (s *TileScheduler) EndFrame() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, req := range s.pending {
+		if req.frame != s.frame {
+			delete(s.pending, key)
+		}
+	}
+	for key, tile := range s.inFlight {
+		if tile.frame != s.frame {
+			tile.cancel()
+			delete(s.inFlight, key)
+		}
+	}
+	for key, failure := range s.failed {
+		if failure.frame != s.frame {
+			delete(s.failed, key)
+		}
+	}
+}
+
+// Request queues a tile for download if it isn't already queued, downloading
+// or waiting out a retry backoff. It never blocks.
+func (s *TileScheduler) Request(key TileKey, priority float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tile, ok := s.inFlight[key]; ok {
+		tile.frame = s.frame
+		return
+	}
+	if req, ok := s.pending[key]; ok {
+		req.priority = priority
+		req.frame = s.frame
+		return
+	}
+	if failure, ok := s.failed[key]; ok {
+		failure.frame = s.frame
+		if time.Now().Before(failure.nextRetry) {
+			return
+		}
+	}
+
+	s.pending[key] = &tileRequest{key: key, priority: priority, frame: s.frame}
+	s.cond.Signal()
+}
+
+func (s *TileScheduler) Stats() TileSchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return TileSchedulerStats{
+		Pending:  len(s.pending),
+		InFlight: len(s.inFlight),
+		Failed:   len(s.failed),
+	}
+}
+
+// next removes and returns the most urgent pending request. The caller must
+// hold s.mu and there must be at least one pending request.
+func (s *TileScheduler) next() *tileRequest {
+	var best *tileRequest
+	for _, req := range s.pending {
+		if best == nil || req.priority < best.priority {
+			best = req
+		}
+	}
+	delete(s.pending, best.key)
+	return best
+}
+
+func (s *TileScheduler) worker() {
+	for {
+		s.mu.Lock()
+		for !s.closed && len(s.pending) == 0 {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+
+		req := s.next()
+		ctx, cancel := context.WithCancel(context.Background())
+		tile := &inFlightTile{frame: req.frame, cancel: cancel}
+		s.inFlight[req.key] = tile
+		s.mu.Unlock()
+
+		img, err := s.download(ctx, req.key.X, req.key.Y, req.key.Zoom, req.key.Basemap)
+
+		s.mu.Lock()
+		// Only forget the tile if it wasn't cancelled and requested again meanwhile
+		if s.inFlight[req.key] == tile {
+			delete(s.inFlight, req.key)
+		}
+		if err == nil {
+			s.cache.Set(req.key, img)
+			delete(s.failed, req.key)
+		} else if ctx.Err() == nil {
+			// Failed for real rather than cancelled, so back off before retrying
+			failure, ok := s.failed[req.key]
+			if !ok {
+				failure = &tileFailure{}
+				s.failed[req.key] = failure
+			}
+			failure.attempts++
+			// The tile may have been requested again while downloading
+			failure.frame = tile.frame
+			backoff := tileRetryMinBackoff << (failure.attempts - 1)
+			if backoff > tileRetryMaxBackoff || backoff <= 0 {
+				backoff = tileRetryMaxBackoff
+			}
+			failure.nextRetry = time.Now().Add(backoff)
+			log.Printf("Tile %s %d/%d/%d failed (attempt %d), retrying in %s: %v\n", req.key.Basemap, req.key.Zoom, req.key.X, req.key.Y, failure.attempts, backoff, err)
+		}
+		s.mu.Unlock()
+		cancel()
+	}
+}
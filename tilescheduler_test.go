@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// waitFor polls until cond holds or fails the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTileSchedulerKeepsBackoffAcrossFrames(t *testing.T) {
+	release := make(chan struct{})
+	var downloads int32
+	s := NewTileScheduler(NewTileImageCache(DefaultTileCacheMaxTiles, DefaultTileCacheMaxBytes))
+	s.download = func(ctx context.Context, x, y, zoom int, basemap string) (*ebiten.Image, error) {
+		atomic.AddInt32(&downloads, 1)
+		<-release
+		return nil, errors.New("server unreachable")
+	}
+	s.Start(1)
+	defer s.Stop()
+
+	key := TileKey{Basemap: "OSM", Zoom: 10, X: 1, Y: 2}
+	s.BeginFrame()
+	s.Request(key, 0)
+	s.EndFrame()
+	waitFor(t, "the download to start", func() bool { return s.Stats().InFlight == 1 })
+
+	// The tile is still wanted in the next frame when its download fails
+	s.BeginFrame()
+	s.Request(key, 0)
+	close(release)
+	waitFor(t, "the download to fail", func() bool { return s.Stats().Failed == 1 })
+	s.EndFrame()
+	if stats := s.Stats(); stats.Failed != 1 {
+		t.Fatalf("failure of a tile still wanted was forgotten: %+v", stats)
+	}
+
+	// Requests during the backoff don't download the tile again
+	for i := 0; i < 3; i++ {
+		s.BeginFrame()
+		s.Request(key, 0)
+		s.EndFrame()
+	}
+	if stats := s.Stats(); stats.Pending != 0 || stats.Failed != 1 {
+		t.Errorf("tile requeued during its backoff: %+v", stats)
+	}
+	if n := atomic.LoadInt32(&downloads); n != 1 {
+		t.Errorf("tile downloaded %d times during its backoff, want 1", n)
+	}
+
+	// Once the tile leaves the view its failure is forgotten
+	s.BeginFrame()
+	s.EndFrame()
+	if stats := s.Stats(); stats.Failed != 0 {
+		t.Errorf("failure of a tile no longer wanted kept: %+v", stats)
+	}
+}
+
+func TestTileSchedulerRequestsEachTileOnce(t *testing.T) {
+	s := NewTileScheduler(NewTileImageCache(DefaultTileCacheMaxTiles, DefaultTileCacheMaxBytes))
+
+	s.BeginFrame()
+	s.Request(TileKey{Basemap: "OSM", Zoom: 10, X: 1, Y: 2}, 2)
+	s.Request(TileKey{Basemap: "OSM", Zoom: 10, X: 1, Y: 2}, 1)
+	s.Request(TileKey{Basemap: "OSM", Zoom: 10, X: 3, Y: 4}, 0)
+	s.EndFrame()
+	if stats := s.Stats(); stats.Pending != 2 {
+		t.Fatalf("%d tiles pending, want 2", stats.Pending)
+	}
+
+	s.mu.Lock()
+	first := s.next()
+	s.mu.Unlock()
+	if first.key.X != 3 {
+		t.Errorf("most urgent tile is %+v, want X 3", first.key)
+	}
+
+	// Tiles not requested again in the next frame are dropped
+	s.BeginFrame()
+	s.EndFrame()
+	if stats := s.Stats(); stats.Pending != 0 {
+		t.Errorf("%d tiles pending after leaving the view, want 0", stats.Pending)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/OpticalFlyer/FiberForge/model"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// drawTrack draws the track being recorded as a thin line
+func (g *Game) drawTrack(screen *ebiten.Image, view model.Viewport) {
+	for _, points := range g.Track.Segments() {
+		for i := 0; i+1 < len(points); i++ {
+			x1, y1, x2, y2 := view.SegmentToScreen(points[i].Lat, points[i].Lon, points[i+1].Lat, points[i+1].Lon)
+			vector.StrokeLine(screen, x1, y1, x2, y2, g.Track.Line.Width, g.Track.Line.Color, false)
+		}
+	}
+}
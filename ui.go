@@ -3,6 +3,7 @@ package main
 import (
 	"image/color"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -19,16 +20,32 @@ func (g *Game) handleTextInput() {
 	// Get input characters
 	buffer = ebiten.AppendInputChars(buffer)
 
-	// Process printable characters
+	// Process printable characters, spaces only separate command arguments
 	for _, char := range buffer {
-		g.TextBoxText += strings.ToUpper(string(char))
-		g.TextBoxText = strings.TrimSpace(g.TextBoxText)
+		g.TextBoxText += string(char)
+		g.TextBoxText = strings.TrimLeft(g.TextBoxText, " ")
 	}
 
 	// Process backspace key
 	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
 		if len(g.TextBoxText) > 0 {
-			g.TextBoxText = g.TextBoxText[:len(g.TextBoxText)-1]
+			_, size := utf8.DecodeLastRuneInString(g.TextBoxText)
+			g.TextBoxText = g.TextBoxText[:len(g.TextBoxText)-size]
+		}
+	}
+
+	// Autocomplete command names
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		g.completeCommand()
+	}
+
+	// Command history, the map pans up and down with shift held instead
+	if !ebiten.IsKeyPressed(ebiten.KeyShift) {
+		if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+			g.TextBoxText = g.commandHistory.Browse(-1, g.TextBoxText)
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+			g.TextBoxText = g.commandHistory.Browse(1, g.TextBoxText)
 		}
 	}
 }
@@ -74,6 +91,8 @@ func (g *Game) DrawTextbox(screen *ebiten.Image, screenWidth, screenHeight int)
 
 	textColor := color.White
 	g.drawText(screen, textX, textY, textColor, g.TextBoxText)
+
+	g.drawCommandOutput(screen, boxX, boxY, boxWidth)
 }
 
 func drawCrosshair(screen *ebiten.Image, x, y, size float32, clr color.Color) {
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	zoomAnimationRate = 0.25  // Fraction of the remaining zoom covered each frame
+	zoomSnapThreshold = 0.001 // Close enough to the target zoom to stop animating
+)
+
+// handleZoom moves the target zoom from the mouse wheel, trackpad or a pinch
+// gesture and animates the current zoom towards it, keeping the location under
+// the cursor or pinch center fixed on screen
+func (g *Game) handleZoom() {
+	// A wheel notch is one whole level, trackpads scroll in fractions of one
+	_, scrollY := ebiten.Wheel()
+	if scrollY != 0 {
+		g.input.targetZoom += math.Max(-1, math.Min(1, scrollY))
+		g.input.targetZoom = math.Max(0, math.Min(MaxZoom, g.input.targetZoom))
+		g.input.zoomAnchorX, g.input.zoomAnchorY = ebiten.CursorPosition()
+	}
+
+	g.handlePinchZoom()
+
+	if g.View.Zoom != g.input.targetZoom {
+		zoom := g.View.Zoom + (g.input.targetZoom-g.View.Zoom)*zoomAnimationRate
+		if math.Abs(g.input.targetZoom-zoom) < zoomSnapThreshold {
+			zoom = g.input.targetZoom
+		}
+		g.zoomAround(zoom, g.input.zoomAnchorX, g.input.zoomAnchorY)
+	}
+}
+
+// handlePinchZoom sets the target zoom from the spread of a two finger gesture
+func (g *Game) handlePinchZoom() {
+	g.input.touchIDs = ebiten.AppendTouchIDs(g.input.touchIDs[:0])
+	if len(g.input.touchIDs) != 2 {
+		g.input.pinchStartDistance = 0
+		return
+	}
+
+	x0, y0 := ebiten.TouchPosition(g.input.touchIDs[0])
+	x1, y1 := ebiten.TouchPosition(g.input.touchIDs[1])
+	distance := math.Hypot(float64(x1-x0), float64(y1-y0))
+	if distance == 0 {
+		return
+	}
+
+	if g.input.pinchStartDistance == 0 {
+		g.input.pinchStartDistance = distance
+		g.input.pinchStartZoom = g.View.Zoom
+		return
+	}
+
+	// Doubling the finger spread zooms in one level
+	g.input.targetZoom = g.input.pinchStartZoom + math.Log2(distance/g.input.pinchStartDistance)
+	g.input.targetZoom = math.Max(0, math.Min(MaxZoom, g.input.targetZoom))
+	g.input.zoomAnchorX, g.input.zoomAnchorY = (x0+x1)/2, (y0+y1)/2
+}
+
+// zoomAround changes the zoom level while keeping the world coordinates at the
+// given screen position locked in place
+func (g *Game) zoomAround(zoom float64, screenX, screenY int) {
+	// Calculate the world coordinates before zooming
+	anchorLat, anchorLon := g.viewport().ScreenToLatLng(float64(screenX), float64(screenY))
+
+	g.View.Zoom = math.Max(0, math.Min(MaxZoom, zoom))
+
+	// Adjust the center latitude and longitude to keep the world coordinates at the anchor locked
+	g.View.CenterLat, g.View.CenterLon = g.viewport().CenterForAnchor(anchorLat, anchorLon, float64(screenX), float64(screenY))
+}